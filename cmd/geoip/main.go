@@ -16,9 +16,17 @@ import (
 
 const (
 	datafile = "" // path to txt data file; one line per IP
+	geodb    = "" // path to 'GeoLite2-City.mmdb'
 )
 
 func main() {
+	// Open the GeoIP database
+	reader, err := geoip.Open(geodb)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer reader.Close()
+
 	// Sanatinize the file path
 	fqpn := path.Clean(datafile)
 
@@ -39,7 +47,7 @@ func main() {
 		ip := net.ParseIP(line)
 
 		// Get the geoip data
-		data, err := geoip.Lookup(ip)
+		data, err := reader.Lookup(ip)
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -1,69 +1,40 @@
 package main
 
 import (
-	"context"
-	"encoding/json"
 	"fmt"
-	"net"
+	"os"
 	"strconv"
 	"strings"
-	"time"
 
-	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/rmrfslashbin/aws-cf-rtl/pkg/geoip"
+	"github.com/rmrfslashbin/aws-cf-rtl/pkg/rtl"
+	"github.com/rmrfslashbin/aws-cf-rtl/pkg/transform"
+	"github.com/rmrfslashbin/aws-cf-rtl/pkg/useragent"
 	"github.com/sirupsen/logrus"
-	"github.com/ua-parser/uap-go/uaparser"
 )
 
 var (
 	// Global logger
 	log *logrus.Logger
+
+	// processor parses and enriches the log lines, caching user-agents across invocations
+	processor *transform.Config
+
+	// geoReader is set when a GeoIP database was loaded
+	geoReader *geoip.Reader
 )
 
-// Record represents a single log entry
-type Record struct {
-	Timestamp                int64   `json:"timestamp"`
-	ClientIP                 net.IP  `json:"client_ip"`
-	Status                   int     `json:"status"`
-	Bytes                    int64   `json:"bytes"`
-	Method                   string  `json:"method"`
-	Protocol                 string  `json:"protocol"`
-	Host                     string  `json:"host"`
-	URIStem                  string  `json:"uri_stem"`
-	EdgeLocation             string  `json:"edge_location"`
-	EdgeRequestId            string  `json:"edge_request_id"`
-	HostHeader               string  `json:"host_header"`
-	TimeTaken                float64 `json:"time_taken"`
-	ProtoVersion             string  `json:"proto_version"`
-	IPVersion                string  `json:"ip_version"`
-	UserAgent                string  `json:"user_agent"`
-	Referer                  string  `json:"referer"`
-	Cookie                   string  `json:"cookie"`
-	URIQuery                 string  `json:"uri_query"`
-	EdgeResponseResultType   string  `json:"edge_response_result_type"`
-	SSLProtocol              string  `json:"ssl_protocol"`
-	SSLCipher                string  `json:"ssl_cipher"`
-	EdgeResultType           string  `json:"edge_result_type"`
-	ContentType              string  `json:"content_type"`
-	ContentLength            int64   `json:"content_length"`
-	EdgeDetailedResultType   string  `json:"edge_detailed_result_type"`
-	Country                  string  `json:"country"`
-	CacheBehaviorPathPattern string  `json:"cache_behavior_path_pattern"`
-	UserAgentDeviceFamily    string  `json:"user_agent_device_family"`
-	UserAgentDeviceBrand     string  `json:"user_agent_device_brand"`
-	UserAgentDeviceModel     string  `json:"user_agent_device_model"`
-	UserAgentOSFamily        string  `json:"user_agent_os_family"`
-	UserAgentOSMajor         string  `json:"user_agent_os_major"`
-	UserAgentOSMinor         string  `json:"user_agent_os_minor"`
-	UserAgentOSPatch         string  `json:"user_agent_os_patch"`
-	UserAgentOSPatchMinor    string  `json:"user_agent_os_patch_minor"`
-	UserAgentFamily          string  `json:"user_agent_family"`
-	UserAgentMajor           string  `json:"user_agent_major"`
-	UserAgentMinor           string  `json:"user_agent_minor"`
-	UserAgentPatch           string  `json:"user_agent_patch"`
+// defaultGeoIPDBs are where GeoIP databases shipped in a Lambda layer are found when RTL_GEOIP_DB is not set.
+var defaultGeoIPDBs = []string{
+	"/opt/GeoLite2-City.mmdb",
+	"/opt/GeoLite2-ASN.mmdb",
+	"/opt/GeoIP2-ISP.mmdb",
+	"/opt/GeoIP2-Connection-Type.mmdb",
 }
 
-/* Fields mapped from Cloudfront Real-Time Logs configuration.
+/* Fields mapped from the default Cloudfront Real-Time Logs configuration.
+Set RTL_FIELDS to the comma separated field list of your configuration if it differs.
 
 00 timestamp (string) (len=14) "1642349408.581",
 01 c-ip	(string) (len=14) "123.123.123.123",
@@ -94,60 +65,89 @@ type Record struct {
 26 cache-behavior-path-pattern (string) (len=1) "*"
 */
 
-// handler is the Lambda function handler
-func handler(ctx context.Context, kinesisFirehoseEvent events.KinesisFirehoseEvent) (*events.KinesisFirehoseResponse, error) {
-	// Struct to hold the response
-	output := &events.KinesisFirehoseResponse{}
+// init the logger and other things as needed
+func init() {
+	log = logrus.New()
+	log.SetLevel(logrus.InfoLevel)
+	log.SetFormatter(&logrus.JSONFormatter{})
 
-	// Loop through each record from Kinesis Firehose
-	for _, record := range kinesisFirehoseEvent.Records {
-		// Split the data by tabs
-		parts := strings.Split(string(record.Data), "\t")
+	schema, err := schemaFromEnv()
+	if err != nil {
+		log.WithFields(logrus.Fields{
+			"error": err,
+		}).Fatal("invalid field list")
+	}
 
-		// Marshal the data into a Record struct
-		data, err := marshal(&parts)
-		if err != nil {
-			log.WithFields(logrus.Fields{
-				"error": err,
-			}).Error("marshal failed")
-			continue
+	cacheSize, err := cacheSizeFromEnv()
+	if err != nil {
+		log.WithFields(logrus.Fields{
+			"error": err,
+		}).Fatal("invalid RTL_UA_CACHE_SIZE")
+	}
+
+	// User-agent definitions: the bundled overrides, then RTL_UA_OVERRIDES files,
+	// ahead of RTL_UA_REGEXES or the compiled-in definitions
+	uaOpts := []useragent.Option{
+		useragent.SetOverrides(transform.UAOverrides),
+		useragent.SetLogger(log),
+	}
+	if env := os.Getenv("RTL_UA_OVERRIDES"); env != "" {
+		for _, path := range strings.Split(env, ",") {
+			uaOpts = append(uaOpts, useragent.SetOverridesFile(path))
 		}
+	}
+	if env := os.Getenv("RTL_UA_REGEXES"); env != "" {
+		uaOpts = append(uaOpts, useragent.SetRegexesFile(env))
+	}
+	if _, err := useragent.Load(uaOpts...); err != nil {
+		log.WithFields(logrus.Fields{
+			"error": err,
+		}).Fatal("useragent definitions failed to load")
+	}
 
-		// Convert to JSON
-		jsonData, err := json.Marshal(data)
-		if err != nil {
+	// GeoIP enrichment is optional; only a configured but unusable database is fatal
+	var geodbs []string
+	if env := os.Getenv("RTL_GEOIP_DB"); env != "" {
+		geodbs = strings.Split(env, ",")
+	} else {
+		for _, path := range defaultGeoIPDBs {
+			if _, err := os.Stat(path); err == nil {
+				geodbs = append(geodbs, path)
+			}
+		}
+	}
+	if len(geodbs) > 0 {
+		includeBuild, _ := strconv.ParseBool(os.Getenv("RTL_GEOIP_BUILD"))
+		if geoReader, err = geoip.OpenAll(geodbs, geoip.SetIncludeBuild(includeBuild), geoip.SetLogger(log)); err != nil {
 			log.WithFields(logrus.Fields{
 				"error": err,
-			}).Error("marshal failed")
-			continue
+				"paths": geodbs,
+			}).Fatal("geoip database failed to load")
 		}
-
-		// Add partition keys to the response
-		partitionKeys := make(map[string]string)
-		timeMili := time.UnixMilli(data.Timestamp)
-		partitionKeys["year"] = fmt.Sprintf("%d", timeMili.Year())
-		partitionKeys["month"] = fmt.Sprintf("%d", timeMili.Month())
-		partitionKeys["day"] = fmt.Sprintf("%d", timeMili.Day())
-
-		// Create the response
-		output.Records = append(output.Records, events.KinesisFirehoseResponseRecord{
-			RecordID: record.RecordID,
-			Result:   events.KinesisFirehoseTransformedStateOk,
-			Data:     jsonData,
-			Metadata: events.KinesisFirehoseResponseRecordMetadata{
-				PartitionKeys: partitionKeys,
-			},
-		})
-	}
-	// Return the response to Kinesis Firehose
-	return output, nil
-}
-
-// init the logger and other things as needed
-func init() {
-	log = logrus.New()
-	log.SetLevel(logrus.InfoLevel)
-	log.SetFormatter(&logrus.JSONFormatter{})
+		for _, meta := range geoReader.Metadata() {
+			log.WithFields(logrus.Fields{
+				"path":          meta.Path,
+				"database_type": meta.DatabaseType,
+				"build_time":    meta.BuildTime,
+			}).Info("geoip database loaded")
+		}
+	}
+	log.WithFields(logrus.Fields{
+		"enabled": geoReader != nil,
+		"paths":   geodbs,
+	}).Info("geoip enrichment")
+
+	processor, err = transform.New(
+		transform.SetLogger(log),
+		transform.SetSchema(schema),
+		transform.SetUACache(useragent.NewCache(cacheSize)),
+		transform.SetGeoIP(geoReader),
+	)
+	if err != nil {
+		log.WithFields(logrus.Fields{
+			"error": err,
+		}).Fatal("transform setup failed")
+	}
 }
 
 // main is the entry point
@@ -163,64 +163,64 @@ func main() {
 	}()
 
 	// Run the lambda function
-	lambda.Start(handler)
+	lambda.Start(processor.Handler)
+}
+
+// cacheSizeFromEnv returns the user-agent cache size from RTL_UA_CACHE_SIZE,
+// falling back to transform.DefaultUACacheSize.
+func cacheSizeFromEnv() (int, error) {
+	env := os.Getenv("RTL_UA_CACHE_SIZE")
+	if env == "" {
+		return transform.DefaultUACacheSize, nil
+	}
+	size, err := strconv.Atoi(env)
+	if err != nil {
+		return 0, err
+	}
+	if size < 1 {
+		return 0, fmt.Errorf("cache size must be at least 1: %d", size)
+	}
+	return size, nil
 }
 
-// marshal the log fields into a Record
-func marshal(parts *[]string) (*Record, error) {
-	record := &Record{}
+// schemaFromEnv builds a Schema from the comma separated RTL_FIELDS env var,
+// falling back to rtl.DefaultFields.
+// RTL_PARSE_MODE sets the parse mode of every field and RTL_FIELD_MODES
+// overrides individual fields, e.g. "timestamp=strict,c-ip=lenient".
+func schemaFromEnv() (*transform.Schema, error) {
+	fields := rtl.DefaultFields
+	if env := os.Getenv("RTL_FIELDS"); env != "" {
+		fields = strings.Split(env, ",")
+	}
 
-	if tstampfloat, err := strconv.ParseFloat((*parts)[0], 64); err == nil {
-		record.Timestamp = int64(tstampfloat * 1000)
-	} else {
-		logrus.WithFields(logrus.Fields{
-			"error":     err,
-			"timestamp": (*parts)[0],
-		}).Error("timestamp failed")
-	}
-
-	record.ClientIP = net.ParseIP((*parts)[1])
-	record.Status, _ = strconv.Atoi((*parts)[2])
-	record.Bytes, _ = strconv.ParseInt((*parts)[3], 10, 64)
-	record.Method = (*parts)[4]
-	record.Protocol = (*parts)[5]
-	record.Host = (*parts)[6]
-	record.URIStem = (*parts)[7]
-	record.EdgeLocation = (*parts)[8]
-	record.EdgeRequestId = (*parts)[9]
-	record.HostHeader = (*parts)[10]
-	record.TimeTaken, _ = strconv.ParseFloat((*parts)[11], 64)
-	record.ProtoVersion = (*parts)[12]
-	record.IPVersion = (*parts)[13]
-	record.UserAgent = (*parts)[14]
-	record.Referer = (*parts)[15]
-	record.Cookie = (*parts)[16]
-	record.URIQuery = (*parts)[17]
-	record.EdgeResponseResultType = (*parts)[18]
-	record.SSLProtocol = (*parts)[19]
-	record.SSLCipher = (*parts)[20]
-	record.EdgeResultType = (*parts)[21]
-	record.ContentType = (*parts)[22]
-	record.ContentLength, _ = strconv.ParseInt((*parts)[23], 10, 64)
-	record.EdgeDetailedResultType = (*parts)[24]
-	record.Country = (*parts)[25]
-	record.CacheBehaviorPathPattern = (*parts)[26]
-
-	// Add user agent parsing data
-	parser := uaparser.NewFromSaved()
-	client := parser.Parse(record.UserAgent)
-	record.UserAgentDeviceFamily = client.Device.Family
-	record.UserAgentDeviceBrand = client.Device.Brand
-	record.UserAgentDeviceModel = client.Device.Model
-	record.UserAgentOSFamily = client.Os.Family
-	record.UserAgentOSMajor = client.Os.Major
-	record.UserAgentOSMinor = client.Os.Minor
-	record.UserAgentOSPatch = client.Os.Patch
-	record.UserAgentOSPatchMinor = client.Os.PatchMinor
-	record.UserAgentFamily = client.UserAgent.Family
-	record.UserAgentMajor = client.UserAgent.Major
-	record.UserAgentMinor = client.UserAgent.Minor
-	record.UserAgentPatch = client.UserAgent.Patch
-
-	return record, nil
+	schema, err := transform.NewSchema(fields)
+	if err != nil {
+		return nil, err
+	}
+
+	if env := os.Getenv("RTL_PARSE_MODE"); env != "" {
+		mode, err := transform.ParseModeFromString(env)
+		if err != nil {
+			return nil, err
+		}
+		schema.SetAllModes(mode)
+	}
+
+	if env := os.Getenv("RTL_FIELD_MODES"); env != "" {
+		for _, pair := range strings.Split(env, ",") {
+			name, value, ok := strings.Cut(pair, "=")
+			if !ok {
+				return nil, fmt.Errorf("malformed field mode: %q", pair)
+			}
+			mode, err := transform.ParseModeFromString(value)
+			if err != nil {
+				return nil, err
+			}
+			if err := schema.SetMode(strings.TrimSpace(name), mode); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return schema, nil
 }
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/rmrfslashbin/aws-cf-rtl/pkg/rtl"
+	"github.com/rmrfslashbin/aws-cf-rtl/pkg/transform"
+	"github.com/rmrfslashbin/aws-cf-rtl/pkg/useragent"
+)
+
+func TestSchemaFromEnvFields(t *testing.T) {
+	tests := []struct {
+		env    string
+		fields []string
+		ok     bool
+	}{
+		{"", rtl.DefaultFields, true},
+		{"timestamp,c-ip,sc-status", []string{"timestamp", "c-ip", "sc-status"}, true},
+		{"timestamp, c-ip ,sc-status", []string{"timestamp", "c-ip", "sc-status"}, true},
+		{"sc-status,timestamp", []string{"sc-status", "timestamp"}, true},
+		{"timestamp,c-ip,", nil, false},
+		{"timestamp,cs-bogus", nil, false},
+	}
+	for _, tt := range tests {
+		t.Setenv("RTL_FIELDS", tt.env)
+		schema, err := schemaFromEnv()
+		if !tt.ok {
+			if err == nil {
+				t.Errorf("RTL_FIELDS=%q was accepted", tt.env)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("RTL_FIELDS=%q: %s", tt.env, err)
+			continue
+		}
+		if got, want := strings.Join(schema.Fields(), ","), strings.Join(tt.fields, ","); got != want {
+			t.Errorf("RTL_FIELDS=%q gave fields %s; want %s", tt.env, got, want)
+		}
+	}
+}
+
+func TestCacheSizeFromEnv(t *testing.T) {
+	t.Setenv("RTL_UA_CACHE_SIZE", "")
+	if size, err := cacheSizeFromEnv(); err != nil || size != transform.DefaultUACacheSize {
+		t.Errorf("unset RTL_UA_CACHE_SIZE gave %d, %v; want the default", size, err)
+	}
+	for _, env := range []string{"many", "0", "-5"} {
+		t.Setenv("RTL_UA_CACHE_SIZE", env)
+		if _, err := cacheSizeFromEnv(); err == nil {
+			t.Errorf("RTL_UA_CACHE_SIZE=%s was accepted", env)
+		}
+	}
+
+	// The cache holds no more user-agents than the setting
+	t.Setenv("RTL_UA_CACHE_SIZE", "3")
+	size, err := cacheSizeFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache := useragent.NewCache(size)
+	for i := 0; i < 5; i++ {
+		cache.Parse(fmt.Sprintf("curl/8.%d.0", i))
+	}
+	if stats := cache.Stats(); stats.Len != 3 || stats.Size != 3 {
+		t.Errorf("cache stats %+v; want 3 entries of 3", stats)
+	}
+}
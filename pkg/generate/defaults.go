@@ -0,0 +1,161 @@
+package generate
+
+// DefaultStatuses is the status code mix of a healthy site.
+var DefaultStatuses = Weights{
+	"200": 82,
+	"206": 1,
+	"301": 2,
+	"302": 1,
+	"304": 6,
+	"403": 1,
+	"404": 5,
+	"500": 0.5,
+	"502": 0.3,
+	"503": 0.2,
+}
+
+// DefaultEdgeLocations spreads requests over a few edges on each continent.
+var DefaultEdgeLocations = Weights{
+	"IAD89-C1": 14,
+	"IAD12-P2": 8,
+	"JFK50-C1": 10,
+	"ORD58-P3": 8,
+	"DFW55-C3": 6,
+	"SFO53-C1": 8,
+	"SEA19-C2": 5,
+	"LHR61-C2": 9,
+	"FRA56-P7": 7,
+	"CDG52-P3": 5,
+	"AMS1-C1":  4,
+	"NRT57-P2": 5,
+	"SIN2-C1":  4,
+	"SYD1-C1":  3,
+	"GRU50-C1": 3,
+	"BOM78-P1": 3,
+	"JNB50-C1": 1,
+	"YUL62-C1": 2,
+	"MAD53-C1": 2,
+	"ICN57-P1": 3,
+}
+
+// DefaultCountries are the client countries.
+var DefaultCountries = Weights{
+	"US": 45,
+	"GB": 8,
+	"DE": 7,
+	"FR": 5,
+	"CA": 5,
+	"JP": 5,
+	"IN": 5,
+	"BR": 4,
+	"AU": 3,
+	"NL": 3,
+	"SG": 2,
+	"KR": 2,
+	"ES": 2,
+	"ZA": 1,
+	"MX": 3,
+}
+
+// DefaultUserAgents are browsers, with bots and libraries making up about an eighth.
+var DefaultUserAgents = Weights{
+	"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/118.0.0.0 Safari/537.36":                         30,
+	"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/118.0.0.0 Safari/537.36":                   12,
+	"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.0 Safari/605.1.15":                   8,
+	"Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.0 Mobile/15E148 Safari/604.1": 14,
+	"Mozilla/5.0 (Linux; Android 10; K) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/118.0.0.0 Mobile Safari/537.36":                         12,
+	"Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:118.0) Gecko/20100101 Firefox/118.0":                                                        5,
+	"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/118.0.0.0 Safari/537.36 Edg/118.0.2088.46":       6,
+	"Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)":                                                                4,
+	"Mozilla/5.0 (compatible; bingbot/2.0; +http://www.bing.com/bingbot.htm)":                                                                 2,
+	"Mozilla/5.0 (compatible; SemrushBot/7~bl; +http://www.semrush.com/bot.html)":                                                             1.5,
+	"Mozilla/5.0 (compatible; AhrefsBot/7.0; +http://ahrefs.com/robot/)":                                                                      1.5,
+	"curl/8.1.2":             1,
+	"python-requests/2.31.0": 1,
+	"Go-http-client/2.0":     0.5,
+	"UptimeRobot/2.0 (http://www.uptimerobot.com/)": 1,
+}
+
+// DefaultCacheResults are the edge result types of successful responses.
+// Errors are always reported as Error.
+var DefaultCacheResults = Weights{
+	"Hit":        70,
+	"RefreshHit": 5,
+	"Miss":       25,
+}
+
+// DefaultHosts are the Host headers requested.
+var DefaultHosts = Weights{
+	"www.example.com":    70,
+	"api.example.com":    20,
+	"static.example.com": 10,
+}
+
+// DefaultClientCIDRs are the documentation ranges (RFC 5737 and RFC 3849), so
+// generated lines never carry a real client address.
+var DefaultClientCIDRs = []string{
+	"192.0.2.0/24",
+	"198.51.100.0/24",
+	"203.0.113.0/24",
+	"2001:db8::/32",
+}
+
+// path is a resource that may be requested, with its content type and typical size.
+type path struct {
+	stem        string
+	query       string
+	contentType string
+	bytes       float64
+	weight      float64
+}
+
+// paths are the resources of the generated site.
+var paths = []path{
+	{"/", "-", "text/html", 24000, 20},
+	{"/index.html", "-", "text/html", 24000, 5},
+	{"/news/today/", "-", "text/html", 31000, 8},
+	{"/search", "q=cloudfront", "text/html", 18000, 3},
+	{"/static/app.js", "v=3", "application/javascript", 210000, 14},
+	{"/static/site.css", "v=3", "text/css", 48000, 12},
+	{"/images/hero.jpg", "-", "image/jpeg", 180000, 10},
+	{"/images/logo.svg", "-", "image/svg+xml", 4200, 10},
+	{"/api/v1/items", "page=1", "application/json", 3500, 10},
+	{"/favicon.ico", "-", "image/x-icon", 1100, 5},
+	{"/robots.txt", "-", "text/plain", 120, 2},
+	{"/video/intro.mp4", "-", "video/mp4", 4500000, 1},
+}
+
+// referers are picked for page views; the rest are direct.
+var referers = Weights{
+	"-":                        50,
+	"https://www.google.com/":  25,
+	"https://www.bing.com/":    5,
+	"https://www.example.com/": 15,
+	"https://t.co/":            5,
+}
+
+// protocolVersions are the HTTP versions negotiated.
+var protocolVersions = Weights{
+	"HTTP/2.0": 65,
+	"HTTP/1.1": 20,
+	"HTTP/3.0": 15,
+}
+
+// sslProtocols and their ciphers.
+var sslProtocols = Weights{
+	"TLSv1.3": 85,
+	"TLSv1.2": 15,
+}
+
+var sslCiphers = map[string]string{
+	"TLSv1.3": "TLS_AES_128_GCM_SHA256",
+	"TLSv1.2": "ECDHE-RSA-AES128-GCM-SHA256",
+}
+
+// methods of the requests.
+var methods = Weights{
+	"GET":     93,
+	"HEAD":    2,
+	"POST":    4,
+	"OPTIONS": 1,
+}
@@ -0,0 +1,515 @@
+// Package generate produces synthetic Cloudfront real-time log lines for load
+// testing and demos. The mix of status codes, edge locations, countries,
+// user-agents, cache results, address families and latency is tunable, and the
+// same seed and start always give the same lines.
+package generate
+
+import (
+	"bufio"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rmrfslashbin/aws-cf-rtl/pkg/rtl"
+	"github.com/rmrfslashbin/aws-cf-rtl/pkg/transform"
+	"github.com/rmrfslashbin/aws-cf-rtl/pkg/useragent"
+)
+
+// Defaults used unless set with an Option.
+const (
+	DefaultSeed         = 1
+	DefaultRate         = 100.0
+	DefaultIPv6Share    = 0.2
+	DefaultLatency      = 25 * time.Millisecond
+	DefaultLatencySigma = 0.8
+)
+
+// DefaultStart is the timestamp of the first line unless SetStart is given.
+var DefaultStart = time.Date(2022, 11, 15, 0, 0, 0, 0, time.UTC)
+
+// distributionDomain is the cs-host of every line.
+const distributionDomain = "d111111abcdef8.cloudfront.net"
+
+type Option func(config *Config)
+
+// Config is a generator of log lines. It is not safe for concurrent use.
+type Config struct {
+	seed          int64
+	fields        []string
+	start         time.Time
+	rate          float64
+	statuses      Weights
+	edgeLocations Weights
+	countries     Weights
+	userAgents    Weights
+	botShare      float64
+	cacheResults  Weights
+	hosts         Weights
+	ipv6Share     float64
+	latency       time.Duration
+	latencySigma  float64
+	clientCIDRs   []string
+
+	rand    *rand.Rand
+	now     time.Time
+	pickers map[string]*picker
+	paths   *picker
+	v4      []*net.IPNet
+	v6      []*net.IPNet
+	values  []func(r *request) string
+}
+
+// request is the generated request a line describes.
+type request struct {
+	timestamp    time.Time
+	clientIP     net.IP
+	ipv6         bool
+	port         int
+	status       int
+	method       string
+	protocol     string
+	host         string
+	path         path
+	edgeLocation string
+	requestID    string
+	timeTaken    float64
+	ttfb         float64
+	protoVersion string
+	userAgent    string
+	referer      string
+	resultType   string
+	sslProtocol  string
+	bytes        int64
+	contentLen   int64
+	requestBytes int64
+	country      string
+}
+
+// New returns a generator of lines with the rtl.DefaultFields unless SetFields is given.
+func New(opts ...Option) (*Config, error) {
+	cfg := &Config{
+		seed:          DefaultSeed,
+		fields:        rtl.DefaultFields,
+		start:         DefaultStart,
+		rate:          DefaultRate,
+		statuses:      DefaultStatuses,
+		edgeLocations: DefaultEdgeLocations,
+		countries:     DefaultCountries,
+		userAgents:    DefaultUserAgents,
+		botShare:      -1,
+		cacheResults:  DefaultCacheResults,
+		hosts:         DefaultHosts,
+		ipv6Share:     DefaultIPv6Share,
+		latency:       DefaultLatency,
+		latencySigma:  DefaultLatencySigma,
+		clientCIDRs:   DefaultClientCIDRs,
+	}
+
+	// apply the list of options to Config
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	// The Lambda must be able to parse every field generated
+	if _, err := transform.NewSchema(cfg.fields); err != nil {
+		return nil, err
+	}
+	if cfg.rate <= 0 {
+		return nil, errors.New("rate must be positive")
+	}
+	if cfg.ipv6Share < 0 || cfg.ipv6Share > 1 {
+		return nil, errors.New("IPv6 share must be between 0 and 1")
+	}
+
+	userAgents, err := withBotShare(cfg.userAgents, cfg.botShare)
+	if err != nil {
+		return nil, err
+	}
+	cfg.pickers = map[string]*picker{}
+	for name, weights := range map[string]Weights{
+		"status":           cfg.statuses,
+		"edge location":    cfg.edgeLocations,
+		"country":          cfg.countries,
+		"user-agent":       userAgents,
+		"cache result":     cfg.cacheResults,
+		"host":             cfg.hosts,
+		"referer":          referers,
+		"protocol version": protocolVersions,
+		"ssl protocol":     sslProtocols,
+		"method":           methods,
+	} {
+		if cfg.pickers[name], err = newPicker(name, weights); err != nil {
+			return nil, err
+		}
+	}
+	for status := range cfg.statuses {
+		if _, err := strconv.Atoi(status); err != nil {
+			return nil, fmt.Errorf("invalid status code %q", status)
+		}
+	}
+
+	pathWeights := Weights{}
+	for i, p := range paths {
+		pathWeights[strconv.Itoa(i)] = p.weight
+	}
+	if cfg.paths, err = newPicker("path", pathWeights); err != nil {
+		return nil, err
+	}
+
+	for _, cidr := range cfg.clientCIDRs {
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid client CIDR: %w", err)
+		}
+		if n.IP.To4() != nil {
+			cfg.v4 = append(cfg.v4, n)
+		} else {
+			cfg.v6 = append(cfg.v6, n)
+		}
+	}
+	if len(cfg.v4) == 0 && len(cfg.v6) == 0 {
+		return nil, errors.New("no client CIDRs")
+	}
+
+	cfg.values = make([]func(r *request) string, len(cfg.fields))
+	for i, field := range cfg.fields {
+		value, ok := fieldValues[strings.TrimSpace(field)]
+		if !ok {
+			value = func(r *request) string { return "-" }
+		}
+		cfg.values[i] = value
+	}
+
+	cfg.Reset()
+	return cfg, nil
+}
+
+// SetSeed sets the seed of the random source.
+func SetSeed(seed int64) Option {
+	return func(config *Config) {
+		config.seed = seed
+	}
+}
+
+// SetFields sets the ordered field list of the lines. Fields with nothing to
+// generate from, such as the CMCD fields, are logged as "-".
+func SetFields(fields []string) Option {
+	return func(config *Config) {
+		config.fields = fields
+	}
+}
+
+// SetStart sets the timestamp of the first line.
+func SetStart(start time.Time) Option {
+	return func(config *Config) {
+		config.start = start
+	}
+}
+
+// SetRate sets the average requests per second; timestamps advance by random
+// intervals averaging 1/rate.
+func SetRate(rate float64) Option {
+	return func(config *Config) {
+		config.rate = rate
+	}
+}
+
+// SetStatuses sets the status code distribution, e.g. {"200": 95, "503": 5}.
+func SetStatuses(weights Weights) Option {
+	return func(config *Config) {
+		config.statuses = weights
+	}
+}
+
+// SetEdgeLocations sets the edge location distribution.
+func SetEdgeLocations(weights Weights) Option {
+	return func(config *Config) {
+		config.edgeLocations = weights
+	}
+}
+
+// SetCountries sets the client country distribution.
+func SetCountries(weights Weights) Option {
+	return func(config *Config) {
+		config.countries = weights
+	}
+}
+
+// SetUserAgents sets the user-agent distribution.
+func SetUserAgents(weights Weights) Option {
+	return func(config *Config) {
+		config.userAgents = weights
+	}
+}
+
+// SetBotShare rescales the user-agent weights so that bots, as classified by
+// useragent.Classify, make up share of the requests.
+func SetBotShare(share float64) Option {
+	return func(config *Config) {
+		config.botShare = share
+	}
+}
+
+// SetCacheResults sets the distribution of edge results of successful
+// responses, e.g. {"Hit": 80, "Miss": 20}.
+func SetCacheResults(weights Weights) Option {
+	return func(config *Config) {
+		config.cacheResults = weights
+	}
+}
+
+// SetHosts sets the Host header distribution.
+func SetHosts(weights Weights) Option {
+	return func(config *Config) {
+		config.hosts = weights
+	}
+}
+
+// SetIPv6Share sets the share of requests from IPv6 clients.
+func SetIPv6Share(share float64) Option {
+	return func(config *Config) {
+		config.ipv6Share = share
+	}
+}
+
+// SetLatency sets the median time taken by cache hits and the spread of the
+// log-normal latency distribution. Misses and errors take several times longer.
+func SetLatency(median time.Duration, sigma float64) Option {
+	return func(config *Config) {
+		config.latency = median
+		config.latencySigma = sigma
+	}
+}
+
+// SetClientCIDRs sets the blocks client addresses are drawn from, defaulting
+// to DefaultClientCIDRs.
+func SetClientCIDRs(cidrs []string) Option {
+	return func(config *Config) {
+		config.clientCIDRs = cidrs
+	}
+}
+
+// Reset restarts the lines from the seed and start.
+func (config *Config) Reset() {
+	config.rand = rand.New(rand.NewSource(config.seed))
+	config.now = config.start
+}
+
+// Line returns the next log line, without a trailing newline.
+func (config *Config) Line() string {
+	r := config.next()
+	values := make([]string, len(config.values))
+	for i, value := range config.values {
+		values[i] = value(r)
+	}
+	return strings.Join(values, "\t")
+}
+
+// Write writes n lines to w, each ending in a newline as Cloudfront sends them.
+func (config *Config) Write(w io.Writer, n int) error {
+	bw := bufio.NewWriter(w)
+	for i := 0; i < n; i++ {
+		if _, err := bw.WriteString(config.Line() + "\n"); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// next generates the next request.
+func (config *Config) next() *request {
+	rnd := config.rand
+	config.now = config.now.Add(time.Duration(rnd.ExpFloat64() / config.rate * float64(time.Second)))
+
+	r := &request{
+		timestamp:    config.now,
+		method:       config.pickers["method"].pick(rnd),
+		protocol:     "https",
+		host:         config.pickers["host"].pick(rnd),
+		edgeLocation: config.pickers["edge location"].pick(rnd),
+		requestID:    config.requestID(),
+		protoVersion: config.pickers["protocol version"].pick(rnd),
+		userAgent:    config.pickers["user-agent"].pick(rnd),
+		referer:      config.pickers["referer"].pick(rnd),
+		sslProtocol:  config.pickers["ssl protocol"].pick(rnd),
+		country:      config.pickers["country"].pick(rnd),
+		port:         1024 + rnd.Intn(64512),
+		requestBytes: int64(250 + rnd.Intn(700)),
+	}
+	if rnd.Float64() < 0.03 {
+		r.protocol = "http"
+		r.protoVersion = "HTTP/1.1"
+		r.sslProtocol = "-"
+	}
+	i, _ := strconv.Atoi(config.paths.pick(rnd))
+	r.path = paths[i]
+	r.status, _ = strconv.Atoi(config.pickers["status"].pick(rnd))
+	r.clientIP, r.ipv6 = config.clientIP()
+
+	// Latency: hits are served from the edge, misses and errors go to the origin
+	r.resultType = "Error"
+	slowdown := 5.0
+	if r.status < 400 {
+		r.resultType = config.pickers["cache result"].pick(rnd)
+		if r.resultType == "Hit" {
+			slowdown = 1
+		}
+	}
+	median := config.latency.Seconds() * slowdown
+	r.timeTaken = math.Max(0.001, median*math.Exp(config.latencySigma*rnd.NormFloat64()))
+	r.ttfb = math.Max(0.001, r.timeTaken*(0.5+0.45*rnd.Float64()))
+
+	// Sizes
+	switch {
+	case r.method == "HEAD" || r.status == 304 || r.status == 301 || r.status == 302:
+		r.contentLen = 0
+	case r.status >= 400:
+		r.contentLen = int64(900 + rnd.Intn(600))
+	case r.status == 206:
+		r.contentLen = int64(r.path.bytes * 0.25)
+	default:
+		r.contentLen = int64(r.path.bytes * math.Exp(0.3*rnd.NormFloat64()))
+	}
+	r.bytes = r.contentLen + int64(350+rnd.Intn(300))
+	return r
+}
+
+// requestID returns a random edge request ID like Cloudfront's.
+func (config *Config) requestID() string {
+	b := make([]byte, 40)
+	config.rand.Read(b)
+	return base64.URLEncoding.EncodeToString(b)
+}
+
+// clientIP returns a random address from the client CIDRs.
+func (config *Config) clientIP() (net.IP, bool) {
+	nets, ipv6 := config.v4, false
+	if len(config.v6) > 0 && (len(config.v4) == 0 || config.rand.Float64() < config.ipv6Share) {
+		nets, ipv6 = config.v6, true
+	}
+	n := nets[config.rand.Intn(len(nets))]
+
+	ip := make(net.IP, len(n.IP))
+	copy(ip, n.IP)
+	for i := range ip {
+		ip[i] |= byte(config.rand.Intn(256)) &^ n.Mask[i]
+	}
+	return ip, ipv6
+}
+
+// withBotShare rescales the user-agent weights so bots make up share of the total.
+// A negative share leaves the weights as they are.
+func withBotShare(weights Weights, share float64) (Weights, error) {
+	if share < 0 {
+		return weights, nil
+	}
+	if share > 1 {
+		return nil, errors.New("bot share must be between 0 and 1")
+	}
+	var bots, others float64
+	for ua, w := range weights {
+		if useragent.Classify(ua).IsBot {
+			bots += w
+		} else {
+			others += w
+		}
+	}
+	if (share > 0 && bots == 0) || (share < 1 && others == 0) {
+		return nil, fmt.Errorf("cannot make bots %.0f%% of the user-agents given", share*100)
+	}
+
+	scaled := make(Weights, len(weights))
+	for ua, w := range weights {
+		if useragent.Classify(ua).IsBot {
+			scaled[ua] = w / bots * share
+		} else {
+			scaled[ua] = w / others * (1 - share)
+		}
+	}
+	return scaled, nil
+}
+
+// encode percent-encodes a value the way Cloudfront logs it: spaces, tabs and
+// other characters outside a safe set are escaped.
+func encode(value string) string {
+	const safe = "-._/:;()+,=&?*!'@$[]"
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.IndexByte(safe, c) >= 0 {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
+
+// fieldValues render each log field of a request.
+var fieldValues = map[string]func(r *request) string{
+	"timestamp": func(r *request) string {
+		return fmt.Sprintf("%d.%03d", r.timestamp.Unix(), r.timestamp.Nanosecond()/int(time.Millisecond))
+	},
+	"c-ip":      func(r *request) string { return r.clientIP.String() },
+	"sc-status": func(r *request) string { return strconv.Itoa(r.status) },
+	"sc-bytes":  func(r *request) string { return strconv.FormatInt(r.bytes, 10) },
+	"cs-method": func(r *request) string { return r.method },
+	"cs-protocol": func(r *request) string {
+		return r.protocol
+	},
+	"cs-host":           func(r *request) string { return distributionDomain },
+	"cs-uri-stem":       func(r *request) string { return r.path.stem },
+	"x-edge-location":   func(r *request) string { return r.edgeLocation },
+	"x-edge-request-id": func(r *request) string { return r.requestID },
+	"x-host-header":     func(r *request) string { return r.host },
+	"time-taken":        func(r *request) string { return strconv.FormatFloat(r.timeTaken, 'f', 3, 64) },
+	"cs-protocol-version": func(r *request) string {
+		return r.protoVersion
+	},
+	"c-ip-version": func(r *request) string {
+		if r.ipv6 {
+			return "IPv6"
+		}
+		return "IPv4"
+	},
+	"cs-user-agent": func(r *request) string { return encode(r.userAgent) },
+	"cs-referer":    func(r *request) string { return r.referer },
+	"cs-cookie":     func(r *request) string { return "-" },
+	"cs-uri-query":  func(r *request) string { return r.path.query },
+	"x-edge-response-result-type": func(r *request) string {
+		return r.resultType
+	},
+	"ssl-protocol": func(r *request) string { return r.sslProtocol },
+	"ssl-cipher": func(r *request) string {
+		if cipher, ok := sslCiphers[r.sslProtocol]; ok {
+			return cipher
+		}
+		return "-"
+	},
+	"x-edge-result-type": func(r *request) string { return r.resultType },
+	"sc-content-type":    func(r *request) string { return r.path.contentType },
+	"sc-content-len":     func(r *request) string { return strconv.FormatInt(r.contentLen, 10) },
+	"x-edge-detailed-result-type": func(r *request) string {
+		if r.resultType == "Error" && r.status >= 500 {
+			return "OriginError"
+		}
+		return r.resultType
+	},
+	"c-country":                   func(r *request) string { return r.country },
+	"cache-behavior-path-pattern": func(r *request) string { return "*" },
+	"time-to-first-byte":          func(r *request) string { return strconv.FormatFloat(r.ttfb, 'f', 3, 64) },
+	"cs-bytes":                    func(r *request) string { return strconv.FormatInt(r.requestBytes, 10) },
+	"c-port":                      func(r *request) string { return strconv.Itoa(r.port) },
+	"cs-accept-encoding":          func(r *request) string { return encode("gzip, deflate, br") },
+	"cs-accept":                   func(r *request) string { return encode("*/*") },
+	"sr-reason": func(r *request) string {
+		return "-"
+	},
+}
@@ -0,0 +1,132 @@
+package generate
+
+import (
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rmrfslashbin/aws-cf-rtl/pkg/rtl"
+	"github.com/rmrfslashbin/aws-cf-rtl/pkg/transform"
+	"github.com/rmrfslashbin/aws-cf-rtl/pkg/useragent"
+	"github.com/sirupsen/logrus"
+)
+
+// lines returns the first n lines of a generator built with opts.
+func lines(t *testing.T, n int, opts ...Option) []string {
+	t.Helper()
+	g, err := New(opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := make([]string, n)
+	for i := range out {
+		out[i] = g.Line()
+	}
+	return out
+}
+
+func TestDeterministic(t *testing.T) {
+	start := time.Date(2023, 3, 1, 12, 0, 0, 0, time.UTC)
+	a := lines(t, 500, SetSeed(7), SetStart(start))
+	b := lines(t, 500, SetSeed(7), SetStart(start))
+	for i := range a {
+		if a[i] != b[i] {
+			t.Fatalf("line %d differs with the same seed and start:\n%s\n%s", i+1, a[i], b[i])
+		}
+	}
+
+	g, err := New(SetSeed(7), SetStart(start))
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.Line()
+	g.Reset()
+	if line := g.Line(); line != a[0] {
+		t.Errorf("first line after Reset differs:\n%s\n%s", line, a[0])
+	}
+
+	if c := lines(t, 1, SetSeed(8), SetStart(start)); c[0] == a[0] {
+		t.Error("a different seed gave the same first line")
+	}
+	if d := lines(t, 1); !strings.HasPrefix(d[0], "1668470400.") {
+		t.Errorf("default first line %q does not start at DefaultStart", d[0][:20])
+	}
+}
+
+func TestWithBotShare(t *testing.T) {
+	isBot := func(ua string) bool { return useragent.Classify(ua).IsBot }
+	for _, share := range []float64{0, 0.05, 0.2, 0.5, 1} {
+		scaled, err := withBotShare(DefaultUserAgents, share)
+		if err != nil {
+			t.Fatalf("share %v: %s", share, err)
+		}
+		var bots, total float64
+		for ua, w := range scaled {
+			total += w
+			if isBot(ua) {
+				bots += w
+			}
+		}
+		if math.Abs(bots/total-share) > 1e-9 {
+			t.Errorf("share %v: bots weigh %v of the total", share, bots/total)
+		}
+	}
+
+	if same, err := withBotShare(DefaultUserAgents, -1); err != nil || len(same) != len(DefaultUserAgents) {
+		t.Errorf("negative share changed the weights: %v", err)
+	}
+	if _, err := withBotShare(DefaultUserAgents, 1.5); err == nil {
+		t.Error("share over 1 was accepted")
+	}
+	browsers := Weights{"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/118.0.0.0 Safari/537.36": 1}
+	if _, err := withBotShare(browsers, 0.2); err == nil {
+		t.Error("bot share was accepted with no bot user-agents")
+	}
+}
+
+func TestBotShareLines(t *testing.T) {
+	const n, share = 2000, 0.3
+	ua := rtl.FieldIndex(rtl.DefaultFields, "cs-user-agent")
+	bots := 0
+	for _, line := range lines(t, n, SetBotShare(share)) {
+		if useragent.Classify(strings.Split(line, "\t")[ua]).IsBot {
+			bots++
+		}
+	}
+	if got := float64(bots) / n; math.Abs(got-share) > 0.03 {
+		t.Errorf("bots are %.3f of the lines; want about %v", got, share)
+	}
+}
+
+func TestParseStrict(t *testing.T) {
+	// Every field the generator knows, as well as the default list
+	all := make([]string, 0, len(fieldValues))
+	for field := range fieldValues {
+		all = append(all, field)
+	}
+	sort.Strings(all)
+
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	for name, fields := range map[string][]string{"default": rtl.DefaultFields, "all": all} {
+		t.Run(name, func(t *testing.T) {
+			schema, err := transform.NewSchema(fields)
+			if err != nil {
+				t.Fatal(err)
+			}
+			schema.SetAllModes(transform.Strict)
+			parser, err := transform.New(transform.SetSchema(schema), transform.SetLogger(log))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i, line := range lines(t, 2000, SetFields(fields)) {
+				if _, perr := parser.Parse(line); perr != nil {
+					t.Fatalf("line %d: %s\n%s", i+1, perr, line)
+				}
+			}
+		})
+	}
+}
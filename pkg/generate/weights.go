@@ -0,0 +1,91 @@
+package generate
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Weights is a distribution over values: each value is picked in proportion to its weight.
+type Weights map[string]float64
+
+// ParseWeights parses a distribution written as comma separated value=weight
+// pairs, e.g. "200=90,404=8,503=2". A value without a weight has weight 1.
+func ParseWeights(s string) (Weights, error) {
+	weights := Weights{}
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		value, weight, ok := strings.Cut(pair, "=")
+		w := 1.0
+		if ok {
+			var err error
+			if w, err = strconv.ParseFloat(strings.TrimSpace(weight), 64); err != nil || w < 0 {
+				return nil, fmt.Errorf("invalid weight in %q", pair)
+			}
+		}
+		weights[strings.TrimSpace(value)] += w
+	}
+	if len(weights) == 0 {
+		return nil, fmt.Errorf("no values in %q", s)
+	}
+	return weights, nil
+}
+
+// String formats the weights as ParseWeights reads them, in value order.
+func (weights Weights) String() string {
+	values := weights.values()
+	pairs := make([]string, len(values))
+	for i, value := range values {
+		pairs[i] = value + "=" + strconv.FormatFloat(weights[value], 'f', -1, 64)
+	}
+	return strings.Join(pairs, ",")
+}
+
+// values returns the values in order, so a seed always picks the same ones.
+func (weights Weights) values() []string {
+	values := make([]string, 0, len(weights))
+	for value := range weights {
+		values = append(values, value)
+	}
+	sort.Strings(values)
+	return values
+}
+
+// picker draws values from Weights.
+type picker struct {
+	values []string
+	cum    []float64
+}
+
+// newPicker returns a picker for weights, failing when no value has a positive weight.
+func newPicker(name string, weights Weights) (*picker, error) {
+	p := &picker{}
+	total := 0.0
+	for _, value := range weights.values() {
+		if weights[value] <= 0 {
+			continue
+		}
+		total += weights[value]
+		p.values = append(p.values, value)
+		p.cum = append(p.cum, total)
+	}
+	if total == 0 {
+		return nil, fmt.Errorf("%s: no value has a positive weight", name)
+	}
+	return p, nil
+}
+
+// pick draws a value.
+func (p *picker) pick(r *rand.Rand) string {
+	x := r.Float64() * p.cum[len(p.cum)-1]
+	i := sort.SearchFloat64s(p.cum, x)
+	if i == len(p.values) {
+		i--
+	}
+	return p.values[i]
+}
@@ -1,22 +1,72 @@
 package geoip
 
 import (
-	"log"
+	"errors"
+	"fmt"
 	"net"
+	"os"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/fsnotify/fsnotify"
 	"github.com/oschwald/maxminddb-golang"
+	"github.com/sirupsen/logrus"
 )
 
+// ErrClosed is returned by Lookup after the Reader has been closed.
+var ErrClosed = errors.New("geoip reader is closed")
+
+// Kind is the type of data a MaxMind database provides.
+type Kind int
+
 const (
-	geodb = "" // path to 'GeoLite2-City.mmdb'
+	// KindCity covers the City and Country databases.
+	KindCity Kind = iota
+	// KindASN covers the GeoLite2-ASN database.
+	KindASN
+	// KindISP covers the GeoIP2-ISP database.
+	KindISP
+	// KindConnectionType covers the GeoIP2-Connection-Type database.
+	KindConnectionType
 )
 
-var (
-	db *maxminddb.Reader
-)
+// Option configures a Reader.
+type Option func(reader *Reader)
+
+// Reader looks up GeoIP data in one or more MaxMind databases.
+// It is safe for concurrent use.
+type Reader struct {
+	mu           sync.RWMutex
+	inMemory     bool
+	watch        bool
+	includeBuild bool
+	log          *logrus.Logger
+	dbs          []*database
+	build        string
+	closed       bool
+
+	watcher  *fsnotify.Watcher
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// Metadata describes an open database.
+type Metadata struct {
+	Path         string    `json:"path"`
+	DatabaseType string    `json:"database_type"`
+	BuildEpoch   uint      `json:"build_epoch"`
+	BuildTime    time.Time `json:"build_time"`
+}
+
+// database is a single open .mmdb file.
+type database struct {
+	path string
+	kind Kind
+	db   *maxminddb.Reader
+}
 
-// Record defines the fields to fetch from the GeoIP database.
+// Record defines the fields to fetch from the GeoIP City database.
 type Record struct {
 	City struct {
 		Names map[string]string `maxminddb:"names"`
@@ -47,52 +97,264 @@ type Record struct {
 	} `maxminddb:"subdivisions"`
 }
 
+// ISPRecord defines the fields to fetch from the ASN and ISP databases.
+type ISPRecord struct {
+	AutonomousSystemNumber       uint   `maxminddb:"autonomous_system_number"`
+	AutonomousSystemOrganization string `maxminddb:"autonomous_system_organization"`
+	ISP                          string `maxminddb:"isp"`
+	Organization                 string `maxminddb:"organization"`
+}
+
+// ConnectionTypeRecord defines the fields to fetch from the Connection-Type database.
+type ConnectionTypeRecord struct {
+	ConnectionType string `maxminddb:"connection_type"`
+}
+
 // GeoIPData represents the data returned.
 type GeoIPData struct {
-	IP          net.IP  `json:"ip"`
-	City        string  `json:"city_name"`
-	Continent   string  `json:"continent_code"`
-	Country     string  `json:"country_code"`
-	Latitude    float64 `json:"latitude"`
-	Longitude   float64 `json:"longitude"`
-	MetroCode   uint    `json:"metro_code"`
-	TimeZone    string  `json:"time_zone"`
-	PostalCode  string  `json:"postal_code"`
-	Subdivision string  `json:"subdivision_code"`
-}
-
-// init sets up the database connection
-func init() {
-	db, err := maxminddb.Open(geodb)
-	if err != nil {
-		log.Fatal(err)
-	}
-	defer db.Close()
+	IP             net.IP  `json:"ip"`
+	City           string  `json:"city_name"`
+	Continent      string  `json:"continent_code"`
+	Country        string  `json:"country_code"`
+	Latitude       float64 `json:"latitude"`
+	Longitude      float64 `json:"longitude"`
+	MetroCode      uint    `json:"metro_code"`
+	TimeZone       string  `json:"time_zone"`
+	PostalCode     string  `json:"postal_code"`
+	Subdivision    string  `json:"subdivision_code"`
+	ASN            uint    `json:"asn"`
+	ASOrganization string  `json:"as_organization"`
+	ISP            string  `json:"isp"`
+	Organization   string  `json:"organization"`
+	ConnectionType string  `json:"connection_type"`
+	DatabaseBuild  string  `json:"database_build,omitempty"`
+}
+
+// Open opens the GeoIP database (e.g. 'GeoLite2-City.mmdb') at path.
+// The database is memory-mapped unless SetInMemory(true) is given.
+func Open(path string, opts ...Option) (*Reader, error) {
+	return OpenAll([]string{path}, opts...)
 }
 
-// Lookip GeoIP data for the given IP address.
-func Lookup(ip net.IP) (*GeoIPData, error) {
-	var data Record = Record{}
+// OpenAll opens several GeoIP databases (e.g. 'GeoLite2-City.mmdb' and 'GeoLite2-ASN.mmdb')
+// and combines them in a single lookup. The database kind is detected from its metadata.
+func OpenAll(paths []string, opts ...Option) (*Reader, error) {
+	if len(paths) == 0 {
+		return nil, errors.New("no geoip database specified")
+	}
 
-	err := db.Lookup(ip, &data)
+	reader := &Reader{}
+
+	// apply the list of options to Reader
+	for _, opt := range opts {
+		opt(reader)
+	}
+
+	if reader.log == nil {
+		reader.log = logrus.New()
+	}
+
+	for _, path := range paths {
+		db, err := openDatabase(path, reader.inMemory)
+		if err != nil {
+			reader.Close()
+			return nil, err
+		}
+		reader.dbs = append(reader.dbs, db)
+	}
+	reader.build = buildString(reader.dbs)
+
+	if reader.watch {
+		if err := reader.startWatcher(); err != nil {
+			reader.Close()
+			return nil, err
+		}
+	}
+	return reader, nil
+}
+
+// openDatabase opens a single .mmdb file and detects its kind.
+func openDatabase(path string, inMemory bool) (*database, error) {
+	var db *maxminddb.Reader
+	var err error
+	if inMemory {
+		var buf []byte
+		if buf, err = os.ReadFile(path); err != nil {
+			return nil, err
+		}
+		db, err = maxminddb.FromBytes(buf)
+	} else {
+		db, err = maxminddb.Open(path)
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	subdivs := []string{}
-	for _, s := range data.Subdivisions {
-		subdivs = append(subdivs, s.IsoCode)
+	kind, err := kindOf(db.Metadata.DatabaseType)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("%s: %w", path, err)
 	}
+	return &database{path: path, kind: kind, db: db}, nil
+}
 
-	return &GeoIPData{
-		City:        data.City.Names["en"],
-		Continent:   data.Continent.Code,
-		Country:     data.Country.IsoCode,
-		Latitude:    data.Location.Latitude,
-		Longitude:   data.Location.Longitude,
-		MetroCode:   data.Location.MetroCode,
-		TimeZone:    data.Location.TimeZone,
-		PostalCode:  data.Postal.Code,
-		Subdivision: strings.Join(subdivs, ";"),
-	}, nil
+// kindOf maps a MaxMind database type (e.g. "GeoLite2-City") to a Kind.
+func kindOf(databaseType string) (Kind, error) {
+	switch {
+	case strings.HasSuffix(databaseType, "-City"), strings.HasSuffix(databaseType, "-Country"):
+		return KindCity, nil
+	case strings.HasSuffix(databaseType, "-ASN"):
+		return KindASN, nil
+	case strings.HasSuffix(databaseType, "-ISP"):
+		return KindISP, nil
+	case strings.HasSuffix(databaseType, "-Connection-Type"):
+		return KindConnectionType, nil
+	default:
+		return 0, fmt.Errorf("unsupported database type: %q", databaseType)
+	}
+}
+
+// buildString describes the database builds, e.g. "GeoLite2-City:2022-11-15,GeoLite2-ASN:2022-11-15".
+func buildString(dbs []*database) string {
+	builds := make([]string, len(dbs))
+	for i, db := range dbs {
+		builds[i] = fmt.Sprintf("%s:%s", db.db.Metadata.DatabaseType, buildTime(db.db.Metadata.BuildEpoch).Format("2006-01-02"))
+	}
+	return strings.Join(builds, ",")
+}
+
+// buildTime converts a database build epoch to a UTC time.
+func buildTime(epoch uint) time.Time {
+	return time.Unix(int64(epoch), 0).UTC()
+}
+
+// SetInMemory loads the whole database into memory instead of memory-mapping the file.
+func SetInMemory(inMemory bool) Option {
+	return func(reader *Reader) {
+		reader.inMemory = inMemory
+	}
+}
+
+// SetWatch reloads a database whenever its file changes on disk.
+func SetWatch(watch bool) Option {
+	return func(reader *Reader) {
+		reader.watch = watch
+	}
+}
+
+// SetIncludeBuild adds the database builds to every GeoIPData returned by Lookup.
+func SetIncludeBuild(includeBuild bool) Option {
+	return func(reader *Reader) {
+		reader.includeBuild = includeBuild
+	}
+}
+
+// SetLogger sets the logger used to report reloads.
+func SetLogger(log *logrus.Logger) Option {
+	return func(reader *Reader) {
+		reader.log = log
+	}
+}
+
+// Metadata returns the type and build of every open database.
+func (reader *Reader) Metadata() []Metadata {
+	reader.mu.RLock()
+	defer reader.mu.RUnlock()
+
+	meta := make([]Metadata, len(reader.dbs))
+	for i, db := range reader.dbs {
+		meta[i] = Metadata{
+			Path:         db.path,
+			DatabaseType: db.db.Metadata.DatabaseType,
+			BuildEpoch:   db.db.Metadata.BuildEpoch,
+			BuildTime:    buildTime(db.db.Metadata.BuildEpoch),
+		}
+	}
+	return meta
+}
+
+// Close releases the databases and stops any watcher. Lookups after Close
+// return ErrClosed. It is safe to call more than once, and concurrently.
+func (reader *Reader) Close() error {
+	reader.stopWatcher()
+
+	reader.mu.Lock()
+	defer reader.mu.Unlock()
+
+	var err error
+	for _, db := range reader.dbs {
+		if cerr := db.db.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	reader.dbs = nil
+	reader.closed = true
+	return err
+}
+
+// Lookup GeoIP data for the given IP address in every open database.
+func (reader *Reader) Lookup(ip net.IP) (*GeoIPData, error) {
+	reader.mu.RLock()
+	defer reader.mu.RUnlock()
+
+	if reader.closed {
+		return nil, ErrClosed
+	}
+
+	data := &GeoIPData{IP: ip}
+	if reader.includeBuild {
+		data.DatabaseBuild = reader.build
+	}
+	for _, db := range reader.dbs {
+		if err := db.lookup(ip, data); err != nil {
+			return nil, fmt.Errorf("%s: %w", db.path, err)
+		}
+	}
+	return data, nil
+}
+
+// lookup decodes the database's record for ip into data.
+func (db *database) lookup(ip net.IP, data *GeoIPData) error {
+	switch db.kind {
+	case KindCity:
+		var record Record
+		if err := db.db.Lookup(ip, &record); err != nil {
+			return err
+		}
+
+		subdivs := []string{}
+		for _, s := range record.Subdivisions {
+			subdivs = append(subdivs, s.IsoCode)
+		}
+
+		data.City = record.City.Names["en"]
+		data.Continent = record.Continent.Code
+		data.Country = record.Country.IsoCode
+		data.Latitude = record.Location.Latitude
+		data.Longitude = record.Location.Longitude
+		data.MetroCode = record.Location.MetroCode
+		data.TimeZone = record.Location.TimeZone
+		data.PostalCode = record.Postal.Code
+		data.Subdivision = strings.Join(subdivs, ";")
+
+	case KindASN, KindISP:
+		var record ISPRecord
+		if err := db.db.Lookup(ip, &record); err != nil {
+			return err
+		}
+		data.ASN = record.AutonomousSystemNumber
+		data.ASOrganization = record.AutonomousSystemOrganization
+		if db.kind == KindISP {
+			data.ISP = record.ISP
+			data.Organization = record.Organization
+		}
+
+	case KindConnectionType:
+		var record ConnectionTypeRecord
+		if err := db.db.Lookup(ip, &record); err != nil {
+			return err
+		}
+		data.ConnectionType = record.ConnectionType
+	}
+	return nil
 }
@@ -0,0 +1,164 @@
+package geoip
+
+import (
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/sirupsen/logrus"
+)
+
+// reloadDelay lets a database finish writing before it is reopened.
+const reloadDelay = 2 * time.Second
+
+// Reload reopens every database from disk and swaps them in atomically.
+// Lookups keep using the previous databases until the swap.
+func (reader *Reader) Reload() error {
+	reader.mu.RLock()
+	paths := make([]string, len(reader.dbs))
+	for i, db := range reader.dbs {
+		paths[i] = db.path
+	}
+	reader.mu.RUnlock()
+
+	for _, path := range paths {
+		if err := reader.reloadPath(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reloadPath reopens the database at path and replaces the open one.
+func (reader *Reader) reloadPath(path string) error {
+	// Open outside the lock; this is the slow part
+	db, err := openDatabase(path, reader.inMemory)
+	if err != nil {
+		return err
+	}
+
+	reader.mu.Lock()
+	defer reader.mu.Unlock()
+
+	if reader.closed {
+		return db.db.Close()
+	}
+
+	for i, old := range reader.dbs {
+		if old.path == path {
+			reader.dbs[i] = db
+			reader.build = buildString(reader.dbs)
+			// No lookup holds the read lock now, so the old database can go
+			return old.db.Close()
+		}
+	}
+	return db.db.Close()
+}
+
+// startWatcher watches the directory of every database for changes.
+// Directories are watched rather than files since updates usually replace the file.
+func (reader *Reader) startWatcher() error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+
+	paths := make(map[string]string, len(reader.dbs))
+	for _, db := range reader.dbs {
+		abs, err := filepath.Abs(db.path)
+		if err != nil {
+			watcher.Close()
+			return err
+		}
+		paths[abs] = db.path
+	}
+
+	dirs := make(map[string]bool)
+	for abs := range paths {
+		dir := filepath.Dir(abs)
+		if dirs[dir] {
+			continue
+		}
+		if err := watcher.Add(dir); err != nil {
+			watcher.Close()
+			return err
+		}
+		dirs[dir] = true
+	}
+
+	reader.watcher = watcher
+	reader.done = make(chan struct{})
+	go reader.watchLoop(watcher, paths)
+	return nil
+}
+
+// watchLoop reloads databases as their files change until the reader is closed.
+func (reader *Reader) watchLoop(watcher *fsnotify.Watcher, paths map[string]string) {
+	pending := make(map[string]*time.Timer)
+	defer func() {
+		for _, timer := range pending {
+			timer.Stop()
+		}
+	}()
+
+	for {
+		select {
+		case <-reader.done:
+			return
+
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			if !event.Has(fsnotify.Write) && !event.Has(fsnotify.Create) {
+				continue
+			}
+			abs, err := filepath.Abs(event.Name)
+			if err != nil {
+				continue
+			}
+			path, ok := paths[abs]
+			if !ok {
+				continue
+			}
+
+			// Debounce: large files arrive as many writes
+			if timer, ok := pending[path]; ok {
+				timer.Reset(reloadDelay)
+				continue
+			}
+			pending[path] = time.AfterFunc(reloadDelay, func() {
+				if err := reader.reloadPath(path); err != nil {
+					reader.log.WithFields(logrus.Fields{
+						"error": err,
+						"path":  path,
+					}).Error("geoip reload failed")
+					return
+				}
+				reader.log.WithFields(logrus.Fields{
+					"path": path,
+				}).Info("geoip database reloaded")
+			})
+
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			reader.log.WithFields(logrus.Fields{
+				"error": err,
+			}).Error("geoip watcher failed")
+		}
+	}
+}
+
+// stopWatcher stops watching for changes, if watching. Only the first call
+// does anything, so Close may be called more than once, and concurrently.
+func (reader *Reader) stopWatcher() {
+	reader.stopOnce.Do(func() {
+		if reader.watcher == nil {
+			return
+		}
+		close(reader.done)
+		reader.watcher.Close()
+	})
+}
@@ -0,0 +1,37 @@
+package geoip
+
+import (
+	"io"
+	"sync"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestCloseConcurrent(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	reader := &Reader{log: log}
+	if err := reader.startWatcher(); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := reader.Close(); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if err := reader.Close(); err != nil {
+		t.Errorf("second Close: %s", err)
+	}
+	if _, err := reader.Lookup(nil); err != ErrClosed {
+		t.Errorf("Lookup after Close returned %v; want ErrClosed", err)
+	}
+}
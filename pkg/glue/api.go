@@ -0,0 +1,34 @@
+package glue
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/service/glue"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// GlueAPI is the subset of the Glue client used by this package.
+// *glue.Client satisfies it; gluetest.Fake is an in-memory stand-in.
+type GlueAPI interface {
+	GetCrawler(ctx context.Context, params *glue.GetCrawlerInput, optFns ...func(*glue.Options)) (*glue.GetCrawlerOutput, error)
+	ListCrawlers(ctx context.Context, params *glue.ListCrawlersInput, optFns ...func(*glue.Options)) (*glue.ListCrawlersOutput, error)
+	StartCrawler(ctx context.Context, params *glue.StartCrawlerInput, optFns ...func(*glue.Options)) (*glue.StartCrawlerOutput, error)
+	StopCrawler(ctx context.Context, params *glue.StopCrawlerInput, optFns ...func(*glue.Options)) (*glue.StopCrawlerOutput, error)
+	GetCrawlerMetrics(ctx context.Context, params *glue.GetCrawlerMetricsInput, optFns ...func(*glue.Options)) (*glue.GetCrawlerMetricsOutput, error)
+	UpdateCrawlerSchedule(ctx context.Context, params *glue.UpdateCrawlerScheduleInput, optFns ...func(*glue.Options)) (*glue.UpdateCrawlerScheduleOutput, error)
+	ListCrawls(ctx context.Context, params *glue.ListCrawlsInput, optFns ...func(*glue.Options)) (*glue.ListCrawlsOutput, error)
+	GetTable(ctx context.Context, params *glue.GetTableInput, optFns ...func(*glue.Options)) (*glue.GetTableOutput, error)
+	GetPartitions(ctx context.Context, params *glue.GetPartitionsInput, optFns ...func(*glue.Options)) (*glue.GetPartitionsOutput, error)
+	BatchCreatePartition(ctx context.Context, params *glue.BatchCreatePartitionInput, optFns ...func(*glue.Options)) (*glue.BatchCreatePartitionOutput, error)
+}
+
+// S3API is the subset of the S3 client used to find partitions and read their format.
+type S3API interface {
+	ListObjectsV2(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error)
+	GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error)
+}
+
+var (
+	_ GlueAPI = (*glue.Client)(nil)
+	_ S3API   = (*s3.Client)(nil)
+)
@@ -3,14 +3,43 @@ package glue
 import (
 	"context"
 	"errors"
+	"fmt"
 	"os"
+	"sync"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/config"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/glue"
+	"github.com/aws/aws-sdk-go-v2/service/glue/types"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/sirupsen/logrus"
 )
 
+var (
+	// ErrCrawlerNameNotSet is returned by calls that act on a single crawler.
+	ErrCrawlerNameNotSet = errors.New("crawler name is not set")
+
+	// ErrCrawlFailed is returned by WaitForCrawler when the crawl did not succeed.
+	ErrCrawlFailed = errors.New("crawl failed")
+
+	// ErrNeverRun is returned by WaitForCrawler when the crawler is READY and has never crawled.
+	ErrNeverRun = errors.New("crawler has never run")
+
+	// ErrTableNotSet is returned by the partition calls when the database or table is not set.
+	ErrTableNotSet = errors.New("database or table is not set")
+)
+
+// Crawler states.
+const (
+	StateReady    = string(types.CrawlerStateReady)
+	StateRunning  = string(types.CrawlerStateRunning)
+	StateStopping = string(types.CrawlerStateStopping)
+)
+
+// DefaultPollInterval is used by WaitForCrawler when no interval is given.
+const DefaultPollInterval = 15 * time.Second
+
 type Option func(config *Config)
 
 // Configuration structure.
@@ -19,10 +48,19 @@ type Config struct {
 	profile     string
 	log         *logrus.Logger
 	crawlerName string
-	glue        *glue.Client
+	database    string
+	table       string
+	glue        GlueAPI
+	s3          S3API
+	s3Once      sync.Once
+	s3Err       error
 }
 
-func NewCrawler(opts ...func(*Config)) *Config {
+// NewCrawler returns a Config for the Glue crawler and partition calls. Clients not
+// injected with SetClient or SetS3Client are built from the default AWS configuration.
+// With only the Glue client injected, no AWS configuration is loaded until a
+// partition call first needs S3.
+func NewCrawler(opts ...Option) (*Config, error) {
 	cfg := &Config{}
 
 	// apply the list of options to Config
@@ -34,18 +72,47 @@ func NewCrawler(opts ...func(*Config)) *Config {
 		cfg.region = os.Getenv("AWS_REGION")
 	}
 
-	c, err := config.LoadDefaultConfig(context.TODO(), func(o *config.LoadOptions) error {
-		o.Region = cfg.region
-		if cfg.profile != "" {
-			o.SharedConfigProfile = cfg.profile
-		}
-		return nil
-	})
+	if cfg.glue != nil {
+		return cfg, nil
+	}
+
+	c, err := cfg.loadAWSConfig(context.TODO())
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 	cfg.glue = glue.NewFromConfig(c)
-	return cfg
+	if cfg.s3 == nil {
+		cfg.s3 = s3.NewFromConfig(c)
+	}
+	return cfg, nil
+}
+
+// loadAWSConfig loads the default AWS configuration for the region and profile.
+func (config *Config) loadAWSConfig(ctx context.Context) (aws.Config, error) {
+	return awsconfig.LoadDefaultConfig(ctx, func(o *awsconfig.LoadOptions) error {
+		o.Region = config.region
+		if config.profile != "" {
+			o.SharedConfigProfile = config.profile
+		}
+		return nil
+	})
+}
+
+// s3Client returns the S3 client, building it from the default AWS configuration
+// the first time it is needed when none was injected.
+func (config *Config) s3Client(ctx context.Context) (S3API, error) {
+	config.s3Once.Do(func() {
+		if config.s3 != nil {
+			return
+		}
+		c, err := config.loadAWSConfig(ctx)
+		if err != nil {
+			config.s3Err = err
+			return
+		}
+		config.s3 = s3.NewFromConfig(c)
+	})
+	return config.s3, config.s3Err
 }
 
 func SetProfile(profile string) Option {
@@ -72,33 +139,307 @@ func SetCrawlerName(crawlerName string) Option {
 	}
 }
 
-func (config *Config) GetCrawlerData() (*glue.GetCrawlerOutput, error) {
+// SetClient injects the Glue client, e.g. the in-memory fake from gluetest.
+func SetClient(client GlueAPI) Option {
+	return func(config *Config) {
+		config.glue = client
+	}
+}
+
+// SetS3Client injects the S3 client used to find partitions.
+func SetS3Client(client S3API) Option {
+	return func(config *Config) {
+		config.s3 = client
+	}
+}
+
+// SetDatabase sets the Glue database of the table.
+func SetDatabase(database string) Option {
+	return func(config *Config) {
+		config.database = database
+	}
+}
+
+// SetTable sets the Glue table whose partitions are managed.
+func SetTable(table string) Option {
+	return func(config *Config) {
+		config.table = table
+	}
+}
+
+func (config *Config) GetCrawlerData(ctx context.Context) (*glue.GetCrawlerOutput, error) {
 	if config.crawlerName == "" {
-		return nil, errors.New("crawler name is not set")
+		return nil, ErrCrawlerNameNotSet
 	}
 
-	return config.glue.GetCrawler(context.TODO(), &glue.GetCrawlerInput{
+	return config.glue.GetCrawler(ctx, &glue.GetCrawlerInput{
 		Name: aws.String(config.crawlerName),
 	})
 }
 
-func (config *Config) ListCrawlers() ([]string, error) {
-	if ret, err := config.glue.ListCrawlers(context.TODO(), &glue.ListCrawlersInput{}); err != nil {
-		return nil, err
-	} else {
-		return ret.CrawlerNames, nil
+func (config *Config) ListCrawlers(ctx context.Context) ([]string, error) {
+	names := []string{}
+	paginator := glue.NewListCrawlersPaginator(config.glue, &glue.ListCrawlersInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, page.CrawlerNames...)
 	}
+	return names, nil
 }
 
-func (config *Config) StartCrawler() error {
+func (config *Config) StartCrawler(ctx context.Context) error {
 	if config.crawlerName == "" {
-		return errors.New("crawler name is not set")
+		return ErrCrawlerNameNotSet
 	}
 
-	if _, err := config.glue.StartCrawler(context.TODO(), &glue.StartCrawlerInput{
+	if _, err := config.glue.StartCrawler(ctx, &glue.StartCrawlerInput{
 		Name: aws.String(config.crawlerName),
 	}); err != nil {
 		return err
 	}
 	return nil
 }
+
+// CrawlerStatus is the state of a crawler and its last crawl.
+type CrawlerStatus struct {
+	Name            string        `json:"name"`
+	State           string        `json:"state"`
+	DatabaseName    string        `json:"database_name"`
+	Elapsed         time.Duration `json:"elapsed"`
+	LastCrawlStatus string        `json:"last_crawl_status"`
+	LastCrawlStart  time.Time     `json:"last_crawl_start"`
+	LastCrawlError  string        `json:"last_crawl_error,omitempty"`
+	LastUpdated     time.Time     `json:"last_updated"`
+	Schedule        string        `json:"schedule,omitempty"`
+	ScheduleState   string        `json:"schedule_state,omitempty"`
+}
+
+// CrawlerMetrics are the table counts and runtimes of a crawler.
+type CrawlerMetrics struct {
+	Name            string        `json:"name"`
+	TablesCreated   int           `json:"tables_created"`
+	TablesUpdated   int           `json:"tables_updated"`
+	TablesDeleted   int           `json:"tables_deleted"`
+	LastRuntime     time.Duration `json:"last_runtime"`
+	MedianRuntime   time.Duration `json:"median_runtime"`
+	TimeLeft        time.Duration `json:"time_left"`
+	StillEstimating bool          `json:"still_estimating"`
+}
+
+// CrawlerSchedule is the cron schedule of a crawler.
+type CrawlerSchedule struct {
+	Expression string `json:"expression"`
+	State      string `json:"state"`
+}
+
+// newCrawlerStatus converts the SDK crawler to a CrawlerStatus.
+func newCrawlerStatus(crawler *types.Crawler) *CrawlerStatus {
+	status := &CrawlerStatus{
+		Name:         aws.ToString(crawler.Name),
+		State:        string(crawler.State),
+		DatabaseName: aws.ToString(crawler.DatabaseName),
+		Elapsed:      time.Duration(crawler.CrawlElapsedTime) * time.Millisecond,
+		LastUpdated:  aws.ToTime(crawler.LastUpdated),
+	}
+	if crawler.LastCrawl != nil {
+		status.LastCrawlStatus = string(crawler.LastCrawl.Status)
+		status.LastCrawlStart = aws.ToTime(crawler.LastCrawl.StartTime)
+		status.LastCrawlError = aws.ToString(crawler.LastCrawl.ErrorMessage)
+	}
+	if crawler.Schedule != nil {
+		status.Schedule = aws.ToString(crawler.Schedule.ScheduleExpression)
+		status.ScheduleState = string(crawler.Schedule.State)
+	}
+	return status
+}
+
+// GetCrawlerStatus returns the state of the crawler and its last crawl.
+func (config *Config) GetCrawlerStatus(ctx context.Context) (*CrawlerStatus, error) {
+	out, err := config.GetCrawlerData(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return newCrawlerStatus(out.Crawler), nil
+}
+
+// StopCrawler stops a running crawler.
+func (config *Config) StopCrawler(ctx context.Context) error {
+	if config.crawlerName == "" {
+		return ErrCrawlerNameNotSet
+	}
+
+	_, err := config.glue.StopCrawler(ctx, &glue.StopCrawlerInput{
+		Name: aws.String(config.crawlerName),
+	})
+	return err
+}
+
+// StartCrawlerAndWait starts the crawler and blocks until the crawl finishes.
+func (config *Config) StartCrawlerAndWait(ctx context.Context, interval time.Duration) (*CrawlerStatus, error) {
+	started := time.Now()
+	if err := config.StartCrawler(ctx); err != nil {
+		return nil, err
+	}
+	return config.waitForCrawler(ctx, interval, started)
+}
+
+// WaitForCrawler polls the crawler every interval until it is READY again or ctx is done.
+// ErrCrawlFailed is returned along with the status when the last crawl did not succeed,
+// and ErrNeverRun when there is no last crawl.
+func (config *Config) WaitForCrawler(ctx context.Context, interval time.Duration) (*CrawlerStatus, error) {
+	return config.waitForCrawler(ctx, interval, time.Time{})
+}
+
+// waitForCrawler waits until the crawler is READY with a crawl that started after since.
+func (config *Config) waitForCrawler(ctx context.Context, interval time.Duration, since time.Time) (*CrawlerStatus, error) {
+	if interval <= 0 {
+		interval = DefaultPollInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		status, err := config.GetCrawlerStatus(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		if status.State == StateReady {
+			switch {
+			case status.LastCrawlStatus == "" && since.IsZero():
+				return status, ErrNeverRun
+			case status.LastCrawlStatus == "" || status.LastCrawlStart.Before(since.Add(-time.Minute)):
+				// Right after StartCrawler the previous crawl, or none, may still be reported; keep waiting for the new one.
+			case status.LastCrawlStatus != string(types.LastCrawlStatusSucceeded):
+				return status, fmt.Errorf("%w: %s %s", ErrCrawlFailed, status.LastCrawlStatus, status.LastCrawlError)
+			default:
+				return status, nil
+			}
+		}
+
+		if config.log != nil {
+			config.log.WithFields(logrus.Fields{
+				"crawler": status.Name,
+				"state":   status.State,
+				"elapsed": status.Elapsed,
+			}).Debug("waiting for crawler")
+		}
+
+		select {
+		case <-ctx.Done():
+			return status, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// GetCrawlerMetrics returns the table counts and runtimes of the crawler.
+func (config *Config) GetCrawlerMetrics(ctx context.Context) (*CrawlerMetrics, error) {
+	if config.crawlerName == "" {
+		return nil, ErrCrawlerNameNotSet
+	}
+
+	out, err := config.glue.GetCrawlerMetrics(ctx, &glue.GetCrawlerMetricsInput{
+		CrawlerNameList: []string{config.crawlerName},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(out.CrawlerMetricsList) == 0 {
+		return nil, fmt.Errorf("no metrics for crawler %s", config.crawlerName)
+	}
+
+	m := out.CrawlerMetricsList[0]
+	return &CrawlerMetrics{
+		Name:            aws.ToString(m.CrawlerName),
+		TablesCreated:   int(m.TablesCreated),
+		TablesUpdated:   int(m.TablesUpdated),
+		TablesDeleted:   int(m.TablesDeleted),
+		LastRuntime:     seconds(m.LastRuntimeSeconds),
+		MedianRuntime:   seconds(m.MedianRuntimeSeconds),
+		TimeLeft:        seconds(m.TimeLeftSeconds),
+		StillEstimating: m.StillEstimating,
+	}, nil
+}
+
+// GetCrawlerSchedule returns the cron schedule of the crawler.
+func (config *Config) GetCrawlerSchedule(ctx context.Context) (*CrawlerSchedule, error) {
+	status, err := config.GetCrawlerStatus(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &CrawlerSchedule{
+		Expression: status.Schedule,
+		State:      status.ScheduleState,
+	}, nil
+}
+
+// UpdateCrawlerSchedule sets the cron schedule of the crawler, e.g. "cron(15 12 * * ? *)".
+func (config *Config) UpdateCrawlerSchedule(ctx context.Context, expression string) error {
+	if config.crawlerName == "" {
+		return ErrCrawlerNameNotSet
+	}
+
+	_, err := config.glue.UpdateCrawlerSchedule(ctx, &glue.UpdateCrawlerScheduleInput{
+		CrawlerName: aws.String(config.crawlerName),
+		Schedule:    aws.String(expression),
+	})
+	return err
+}
+
+// seconds converts fractional seconds to a Duration.
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+// CrawlerRun is a single past or current crawl.
+type CrawlerRun struct {
+	ID           string    `json:"id"`
+	State        string    `json:"state"`
+	StartTime    time.Time `json:"start_time"`
+	EndTime      time.Time `json:"end_time"`
+	DPUHour      float64   `json:"dpu_hour"`
+	Summary      string    `json:"summary,omitempty"`
+	ErrorMessage string    `json:"error_message,omitempty"`
+}
+
+// GetCrawlerHistory returns up to limit of the most recent crawls, newest first.
+// A limit of zero returns every crawl Glue has kept.
+func (config *Config) GetCrawlerHistory(ctx context.Context, limit int) ([]CrawlerRun, error) {
+	if config.crawlerName == "" {
+		return nil, ErrCrawlerNameNotSet
+	}
+
+	runs := []CrawlerRun{}
+	input := &glue.ListCrawlsInput{
+		CrawlerName: aws.String(config.crawlerName),
+	}
+	for {
+		out, err := config.glue.ListCrawls(ctx, input)
+		if err != nil {
+			return nil, err
+		}
+		for _, crawl := range out.Crawls {
+			runs = append(runs, CrawlerRun{
+				ID:           aws.ToString(crawl.CrawlId),
+				State:        string(crawl.State),
+				StartTime:    aws.ToTime(crawl.StartTime),
+				EndTime:      aws.ToTime(crawl.EndTime),
+				DPUHour:      crawl.DPUHour,
+				Summary:      aws.ToString(crawl.Summary),
+				ErrorMessage: aws.ToString(crawl.ErrorMessage),
+			})
+			if limit > 0 && len(runs) >= limit {
+				return runs, nil
+			}
+		}
+		if out.NextToken == nil {
+			return runs, nil
+		}
+		input.NextToken = out.NextToken
+	}
+}
@@ -0,0 +1,228 @@
+package glue_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/glue/types"
+	"github.com/rmrfslashbin/aws-cf-rtl/pkg/glue"
+	"github.com/rmrfslashbin/aws-cf-rtl/pkg/glue/gluetest"
+)
+
+const testCrawler = "rtl-crawler"
+
+// newCrawler returns a Config for testCrawler on a fake holding it.
+func newCrawler(t *testing.T, opts ...gluetest.Option) (*glue.Config, *gluetest.Fake) {
+	t.Helper()
+	fake := gluetest.NewFake(opts...)
+	fake.AddCrawler(testCrawler, "rtl")
+	config, err := glue.NewCrawler(
+		glue.SetClient(fake),
+		glue.SetS3Client(gluetest.NewFakeS3()),
+		glue.SetCrawlerName(testCrawler),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return config, fake
+}
+
+// states polls the crawler until it is READY and returns every state seen.
+func states(t *testing.T, config *glue.Config) ([]string, *glue.CrawlerStatus) {
+	t.Helper()
+	seen := []string{}
+	for i := 0; i < 10; i++ {
+		status, err := config.GetCrawlerStatus(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		seen = append(seen, status.State)
+		if status.State == glue.StateReady {
+			return seen, status
+		}
+	}
+	t.Fatalf("crawler never READY; saw %v", seen)
+	return nil, nil
+}
+
+func TestCrawlStates(t *testing.T) {
+	config, _ := newCrawler(t, gluetest.SetRunPolls(2), gluetest.SetStopPolls(2))
+	if err := config.StartCrawler(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	seen, status := states(t, config)
+	want := []string{glue.StateRunning, glue.StateRunning, glue.StateStopping, glue.StateStopping, glue.StateReady}
+	if !reflect.DeepEqual(seen, want) {
+		t.Errorf("states %v; want %v", seen, want)
+	}
+	if status.LastCrawlStatus != string(types.LastCrawlStatusSucceeded) {
+		t.Errorf("last crawl %s; want SUCCEEDED", status.LastCrawlStatus)
+	}
+}
+
+func TestStartCrawler(t *testing.T) {
+	ctx := context.Background()
+	config, fake := newCrawler(t)
+	if err := config.StartCrawler(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if state := fake.State(testCrawler); state != glue.StateRunning {
+		t.Errorf("state %s after StartCrawler; want RUNNING", state)
+	}
+
+	var running *types.CrawlerRunningException
+	if err := config.StartCrawler(ctx); !errors.As(err, &running) {
+		t.Errorf("second StartCrawler returned %v; want CrawlerRunningException", err)
+	}
+
+	unnamed, err := glue.NewCrawler(glue.SetClient(fake), glue.SetS3Client(gluetest.NewFakeS3()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := unnamed.StartCrawler(ctx); !errors.Is(err, glue.ErrCrawlerNameNotSet) {
+		t.Errorf("StartCrawler without a name returned %v; want ErrCrawlerNameNotSet", err)
+	}
+}
+
+func TestStopCrawler(t *testing.T) {
+	ctx := context.Background()
+	config, _ := newCrawler(t, gluetest.SetRunPolls(5))
+
+	var notRunning *types.CrawlerNotRunningException
+	if err := config.StopCrawler(ctx); !errors.As(err, &notRunning) {
+		t.Errorf("StopCrawler of a READY crawler returned %v; want CrawlerNotRunningException", err)
+	}
+
+	if err := config.StartCrawler(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := config.StopCrawler(ctx); err != nil {
+		t.Fatal(err)
+	}
+	var stopping *types.CrawlerStoppingException
+	if err := config.StopCrawler(ctx); !errors.As(err, &stopping) {
+		t.Errorf("second StopCrawler returned %v; want CrawlerStoppingException", err)
+	}
+
+	seen, status := states(t, config)
+	if want := []string{glue.StateStopping, glue.StateReady}; !reflect.DeepEqual(seen, want) {
+		t.Errorf("states %v; want %v", seen, want)
+	}
+	if status.LastCrawlStatus != string(types.LastCrawlStatusCancelled) {
+		t.Errorf("last crawl %s; want CANCELLED", status.LastCrawlStatus)
+	}
+}
+
+func TestWaitForCrawler(t *testing.T) {
+	ctx := context.Background()
+	config, fake := newCrawler(t, gluetest.SetRunPolls(3))
+	if err := config.StartCrawler(ctx); err != nil {
+		t.Fatal(err)
+	}
+	status, err := config.WaitForCrawler(ctx, time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.State != glue.StateReady || status.LastCrawlStatus != string(types.LastCrawlStatusSucceeded) {
+		t.Errorf("crawler %s, last crawl %s; want READY and SUCCEEDED", status.State, status.LastCrawlStatus)
+	}
+	// Three polls RUNNING, one STOPPING and one READY
+	if calls := fake.Calls("GetCrawler"); calls != 5 {
+		t.Errorf("%d GetCrawler calls; want 5", calls)
+	}
+}
+
+func TestWaitForCrawlerNeverRun(t *testing.T) {
+	config, fake := newCrawler(t)
+	status, err := config.WaitForCrawler(context.Background(), time.Millisecond)
+	if !errors.Is(err, glue.ErrNeverRun) {
+		t.Fatalf("WaitForCrawler returned %v; want ErrNeverRun", err)
+	}
+	if status == nil || status.State != glue.StateReady || status.LastCrawlStatus != "" {
+		t.Errorf("status %+v; want the READY crawler with no last crawl", status)
+	}
+	if calls := fake.Calls("GetCrawler"); calls != 1 {
+		t.Errorf("%d GetCrawler calls; want 1", calls)
+	}
+}
+
+func TestWaitForCrawlerFailed(t *testing.T) {
+	ctx := context.Background()
+	config, fake := newCrawler(t)
+	fake.FailNextCrawl(testCrawler, "Internal Service Exception")
+
+	status, err := config.StartCrawlerAndWait(ctx, time.Millisecond)
+	if !errors.Is(err, glue.ErrCrawlFailed) {
+		t.Fatalf("StartCrawlerAndWait returned %v; want ErrCrawlFailed", err)
+	}
+	if status.LastCrawlStatus != string(types.LastCrawlStatusFailed) || status.LastCrawlError != "Internal Service Exception" {
+		t.Errorf("last crawl %s %q; want FAILED with the crawl error", status.LastCrawlStatus, status.LastCrawlError)
+	}
+
+	// The failure only applies to one crawl
+	if _, err := config.StartCrawlerAndWait(ctx, time.Millisecond); err != nil {
+		t.Errorf("next crawl returned %v", err)
+	}
+}
+
+func TestWaitForCrawlerCancelled(t *testing.T) {
+	config, _ := newCrawler(t, gluetest.SetRunPolls(1000))
+	if err := config.StartCrawler(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	status, err := config.WaitForCrawler(ctx, time.Millisecond)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("WaitForCrawler returned %v; want the context error", err)
+	}
+	if status == nil || status.State != glue.StateRunning {
+		t.Errorf("status %+v; want the RUNNING crawler", status)
+	}
+}
+
+func TestSetClientWithoutAWS(t *testing.T) {
+	// Point the AWS configuration at empty files and a profile they do not hold,
+	// so loading it fails.
+	dir := t.TempDir()
+	empty := filepath.Join(dir, "empty")
+	if err := os.WriteFile(empty, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("AWS_CONFIG_FILE", empty)
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", empty)
+	t.Setenv("AWS_PROFILE", "no-such-profile")
+
+	fake := gluetest.NewFake()
+	fake.AddCrawler(testCrawler, "rtl")
+	fake.AddTable("rtl", types.Table{
+		Name:              aws.String("rtl"),
+		StorageDescriptor: &types.StorageDescriptor{Location: aws.String("s3://rtl-bucket/processed/rtl/")},
+	})
+	config, err := glue.NewCrawler(
+		glue.SetClient(fake),
+		glue.SetCrawlerName(testCrawler),
+		glue.SetDatabase("rtl"),
+		glue.SetTable("rtl"),
+	)
+	if err != nil {
+		t.Fatalf("NewCrawler with only a Glue client: %v", err)
+	}
+	if err := config.StartCrawler(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := config.GetCrawlerStatus(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// The S3 client is only built when a partition call needs it
+	if _, err := config.ListS3Partitions(context.Background()); err == nil {
+		t.Error("ListS3Partitions built an S3 client with no AWS configuration")
+	}
+}
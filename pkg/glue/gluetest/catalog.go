@@ -0,0 +1,138 @@
+package gluetest
+
+import (
+	"context"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/glue"
+	"github.com/aws/aws-sdk-go-v2/service/glue/types"
+)
+
+// table is a fake catalog table and its partitions, keyed on their joined values.
+type table struct {
+	def        types.Table
+	partitions map[string]types.Partition
+	order      []string
+}
+
+// tableKey identifies a table across databases.
+func tableKey(database, name string) string {
+	return database + "." + name
+}
+
+// AddTable adds a table to database, replacing any table of the same name.
+func (fake *Fake) AddTable(database string, def types.Table) {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	def.DatabaseName = aws.String(database)
+	fake.tables[tableKey(database, aws.ToString(def.Name))] = &table{
+		def:        def,
+		partitions: make(map[string]types.Partition),
+	}
+}
+
+// Partitions returns the values of the partitions of a table in the order they were created.
+func (fake *Fake) Partitions(database, name string) [][]string {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	t, ok := fake.tables[tableKey(database, name)]
+	if !ok {
+		return nil
+	}
+	values := make([][]string, len(t.order))
+	for i, key := range t.order {
+		values[i] = t.partitions[key].Values
+	}
+	return values
+}
+
+// GetTable returns the table.
+func (fake *Fake) GetTable(ctx context.Context, params *glue.GetTableInput, optFns ...func(*glue.Options)) (*glue.GetTableOutput, error) {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	t, err := fake.beginTable("GetTable", aws.ToString(params.DatabaseName), aws.ToString(params.Name))
+	if err != nil {
+		return nil, err
+	}
+	def := t.def
+	return &glue.GetTableOutput{Table: &def}, nil
+}
+
+// GetPartitions returns the partitions of the table, honouring MaxResults and NextToken.
+// Expression and Segment are ignored.
+func (fake *Fake) GetPartitions(ctx context.Context, params *glue.GetPartitionsInput, optFns ...func(*glue.Options)) (*glue.GetPartitionsOutput, error) {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	t, err := fake.beginTable("GetPartitions", aws.ToString(params.DatabaseName), aws.ToString(params.TableName))
+	if err != nil {
+		return nil, err
+	}
+
+	page, next, err := paginate(len(t.order), params.NextToken, params.MaxResults)
+	if err != nil {
+		return nil, err
+	}
+	out := &glue.GetPartitionsOutput{NextToken: next}
+	for _, key := range t.order[page[0]:page[1]] {
+		out.Partitions = append(out.Partitions, t.partitions[key])
+	}
+	return out, nil
+}
+
+// BatchCreatePartition creates the partitions, reporting existing ones as AlreadyExistsException.
+func (fake *Fake) BatchCreatePartition(ctx context.Context, params *glue.BatchCreatePartitionInput, optFns ...func(*glue.Options)) (*glue.BatchCreatePartitionOutput, error) {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	database, name := aws.ToString(params.DatabaseName), aws.ToString(params.TableName)
+	t, err := fake.beginTable("BatchCreatePartition", database, name)
+	if err != nil {
+		return nil, err
+	}
+	if len(params.PartitionInputList) > 100 {
+		return nil, &types.InvalidInputException{Message: aws.String("at most 100 partitions per call")}
+	}
+
+	out := &glue.BatchCreatePartitionOutput{}
+	now := aws.Time(fake.now())
+	for _, input := range params.PartitionInputList {
+		key := strings.Join(input.Values, "/")
+		if _, ok := t.partitions[key]; ok {
+			out.Errors = append(out.Errors, types.PartitionError{
+				PartitionValues: input.Values,
+				ErrorDetail: &types.ErrorDetail{
+					ErrorCode:    aws.String("AlreadyExistsException"),
+					ErrorMessage: aws.String("Partition already exists."),
+				},
+			})
+			continue
+		}
+		t.partitions[key] = types.Partition{
+			DatabaseName:      aws.String(database),
+			TableName:         aws.String(name),
+			Values:            input.Values,
+			StorageDescriptor: input.StorageDescriptor,
+			Parameters:        input.Parameters,
+			CreationTime:      now,
+		}
+		t.order = append(t.order, key)
+	}
+	return out, nil
+}
+
+// beginTable counts the call, returns any injected error and looks up the table.
+func (fake *Fake) beginTable(operation, database, name string) (*table, error) {
+	if _, err := fake.begin(operation, ""); err != nil {
+		return nil, err
+	}
+	t, ok := fake.tables[tableKey(database, name)]
+	if !ok {
+		return nil, &types.EntityNotFoundException{Message: aws.String("Table " + name + " not found.")}
+	}
+	return t, nil
+}
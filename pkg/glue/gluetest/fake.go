@@ -0,0 +1,421 @@
+// Package gluetest provides an in-memory Glue client for exercising pkg/glue without AWS.
+package gluetest
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/glue"
+	"github.com/aws/aws-sdk-go-v2/service/glue/types"
+	rtlglue "github.com/rmrfslashbin/aws-cf-rtl/pkg/glue"
+)
+
+var _ rtlglue.GlueAPI = (*Fake)(nil)
+
+// Option configures a Fake.
+type Option func(fake *Fake)
+
+// Fake is an in-memory GlueAPI holding crawlers, tables and partitions.
+// As in Glue, a crawl goes READY → RUNNING → STOPPING → READY whether it
+// finishes or is stopped. Time only moves forward when GetCrawler is called: a
+// crawl stays RUNNING for RunPolls calls and STOPPING for StopPolls calls, so a
+// WaitForCrawler loop sees every state.
+type Fake struct {
+	mu        sync.Mutex
+	runPolls  int
+	stopPolls int
+	now       func() time.Time
+	crawlers  map[string]*crawler
+	tables    map[string]*table
+	errs      map[string]error
+	calls     map[string]int
+}
+
+// crawler is the state of one fake crawler.
+type crawler struct {
+	def       types.Crawler
+	polls     int
+	started   time.Time
+	fail      string
+	cancelled bool
+	history   []types.CrawlerHistory
+	metrics   types.CrawlerMetrics
+}
+
+// NewFake returns an empty Fake. Add crawlers with AddCrawler and tables with AddTable.
+func NewFake(opts ...Option) *Fake {
+	fake := &Fake{
+		runPolls:  1,
+		stopPolls: 1,
+		now:       time.Now,
+		crawlers:  make(map[string]*crawler),
+		tables:    make(map[string]*table),
+		errs:      make(map[string]error),
+		calls:     make(map[string]int),
+	}
+
+	// apply the list of options to Fake
+	for _, opt := range opts {
+		opt(fake)
+	}
+	return fake
+}
+
+// SetRunPolls sets how many GetCrawler calls report a started crawl as RUNNING.
+func SetRunPolls(polls int) Option {
+	return func(fake *Fake) {
+		fake.runPolls = polls
+	}
+}
+
+// SetStopPolls sets how many GetCrawler calls report a stopped crawl as STOPPING.
+func SetStopPolls(polls int) Option {
+	return func(fake *Fake) {
+		fake.stopPolls = polls
+	}
+}
+
+// SetClock replaces time.Now for crawl start and end times.
+func SetClock(now func() time.Time) Option {
+	return func(fake *Fake) {
+		fake.now = now
+	}
+}
+
+// AddCrawler adds a READY crawler writing to database.
+func (fake *Fake) AddCrawler(name, database string) {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	now := fake.now()
+	fake.crawlers[name] = &crawler{
+		def: types.Crawler{
+			Name:         aws.String(name),
+			DatabaseName: aws.String(database),
+			State:        types.CrawlerStateReady,
+			CreationTime: aws.Time(now),
+			LastUpdated:  aws.Time(now),
+		},
+		metrics: types.CrawlerMetrics{
+			CrawlerName: aws.String(name),
+		},
+	}
+}
+
+// FailNextCrawl makes the next crawl of the named crawler finish FAILED with message.
+func (fake *Fake) FailNextCrawl(name, message string) {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	if c, ok := fake.crawlers[name]; ok {
+		c.fail = message
+	}
+}
+
+// SetError makes every call to operation, e.g. "StartCrawler", return err.
+// A nil err clears it.
+func (fake *Fake) SetError(operation string, err error) {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	if err == nil {
+		delete(fake.errs, operation)
+		return
+	}
+	fake.errs[operation] = err
+}
+
+// State returns the current state of the named crawler, or "" if there is none.
+func (fake *Fake) State(name string) string {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	if c, ok := fake.crawlers[name]; ok {
+		return string(c.def.State)
+	}
+	return ""
+}
+
+// Calls returns how many times operation has been called.
+func (fake *Fake) Calls(operation string) int {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	return fake.calls[operation]
+}
+
+// GetCrawler returns the crawler, advancing a running or stopping crawl by one poll.
+func (fake *Fake) GetCrawler(ctx context.Context, params *glue.GetCrawlerInput, optFns ...func(*glue.Options)) (*glue.GetCrawlerOutput, error) {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	c, err := fake.begin("GetCrawler", aws.ToString(params.Name))
+	if err != nil {
+		return nil, err
+	}
+
+	fake.advance(c)
+	def := c.def
+	if def.State != types.CrawlerStateReady {
+		def.CrawlElapsedTime = fake.now().Sub(c.started).Milliseconds()
+	}
+	return &glue.GetCrawlerOutput{Crawler: &def}, nil
+}
+
+// ListCrawlers returns the crawler names in order, honouring MaxResults and NextToken.
+func (fake *Fake) ListCrawlers(ctx context.Context, params *glue.ListCrawlersInput, optFns ...func(*glue.Options)) (*glue.ListCrawlersOutput, error) {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	if _, err := fake.begin("ListCrawlers", ""); err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(fake.crawlers))
+	for name := range fake.crawlers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	page, next, err := paginate(len(names), params.NextToken, params.MaxResults)
+	if err != nil {
+		return nil, err
+	}
+	return &glue.ListCrawlersOutput{
+		CrawlerNames: names[page[0]:page[1]],
+		NextToken:    next,
+	}, nil
+}
+
+// StartCrawler moves a READY crawler to RUNNING.
+func (fake *Fake) StartCrawler(ctx context.Context, params *glue.StartCrawlerInput, optFns ...func(*glue.Options)) (*glue.StartCrawlerOutput, error) {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	c, err := fake.begin("StartCrawler", aws.ToString(params.Name))
+	if err != nil {
+		return nil, err
+	}
+	if c.def.State != types.CrawlerStateReady {
+		return nil, &types.CrawlerRunningException{Message: aws.String("Crawler with name " + aws.ToString(params.Name) + " has already started")}
+	}
+
+	now := fake.now()
+	c.def.State = types.CrawlerStateRunning
+	c.polls = fake.runPolls
+	c.started = now
+	c.cancelled = false
+	c.history = append(c.history, types.CrawlerHistory{
+		CrawlId:   aws.String(strconv.Itoa(len(c.history) + 1)),
+		State:     types.CrawlerHistoryStateRunning,
+		StartTime: aws.Time(now),
+	})
+	return &glue.StartCrawlerOutput{}, nil
+}
+
+// StopCrawler moves a RUNNING crawler to STOPPING.
+func (fake *Fake) StopCrawler(ctx context.Context, params *glue.StopCrawlerInput, optFns ...func(*glue.Options)) (*glue.StopCrawlerOutput, error) {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	c, err := fake.begin("StopCrawler", aws.ToString(params.Name))
+	if err != nil {
+		return nil, err
+	}
+	switch c.def.State {
+	case types.CrawlerStateStopping:
+		return nil, &types.CrawlerStoppingException{Message: aws.String("Crawler is stopping")}
+	case types.CrawlerStateReady:
+		return nil, &types.CrawlerNotRunningException{Message: aws.String("Crawler is not running")}
+	}
+
+	c.def.State = types.CrawlerStateStopping
+	c.polls = fake.stopPolls
+	c.cancelled = true
+	return &glue.StopCrawlerOutput{}, nil
+}
+
+// GetCrawlerMetrics returns the metrics of the named crawlers, or of all of them.
+func (fake *Fake) GetCrawlerMetrics(ctx context.Context, params *glue.GetCrawlerMetricsInput, optFns ...func(*glue.Options)) (*glue.GetCrawlerMetricsOutput, error) {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	if _, err := fake.begin("GetCrawlerMetrics", ""); err != nil {
+		return nil, err
+	}
+
+	names := params.CrawlerNameList
+	if len(names) == 0 {
+		for name := range fake.crawlers {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+
+	out := &glue.GetCrawlerMetricsOutput{}
+	for _, name := range names {
+		if c, ok := fake.crawlers[name]; ok {
+			metrics := c.metrics
+			if c.def.State == types.CrawlerStateRunning {
+				metrics.StillEstimating = true
+			}
+			out.CrawlerMetricsList = append(out.CrawlerMetricsList, metrics)
+		}
+	}
+	return out, nil
+}
+
+// UpdateCrawlerSchedule sets the schedule of the crawler.
+func (fake *Fake) UpdateCrawlerSchedule(ctx context.Context, params *glue.UpdateCrawlerScheduleInput, optFns ...func(*glue.Options)) (*glue.UpdateCrawlerScheduleOutput, error) {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	c, err := fake.begin("UpdateCrawlerSchedule", aws.ToString(params.CrawlerName))
+	if err != nil {
+		return nil, err
+	}
+
+	state := types.ScheduleStateScheduled
+	if aws.ToString(params.Schedule) == "" {
+		state = types.ScheduleStateNotScheduled
+	}
+	c.def.Schedule = &types.Schedule{
+		ScheduleExpression: params.Schedule,
+		State:              state,
+	}
+	c.def.LastUpdated = aws.Time(fake.now())
+	return &glue.UpdateCrawlerScheduleOutput{}, nil
+}
+
+// ListCrawls returns the crawls of the crawler newest first, honouring MaxResults and NextToken.
+func (fake *Fake) ListCrawls(ctx context.Context, params *glue.ListCrawlsInput, optFns ...func(*glue.Options)) (*glue.ListCrawlsOutput, error) {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	c, err := fake.begin("ListCrawls", aws.ToString(params.CrawlerName))
+	if err != nil {
+		return nil, err
+	}
+
+	crawls := make([]types.CrawlerHistory, len(c.history))
+	for i, crawl := range c.history {
+		crawls[len(c.history)-1-i] = crawl
+	}
+
+	page, next, err := paginate(len(crawls), params.NextToken, params.MaxResults)
+	if err != nil {
+		return nil, err
+	}
+	return &glue.ListCrawlsOutput{
+		Crawls:    crawls[page[0]:page[1]],
+		NextToken: next,
+	}, nil
+}
+
+// begin counts the call, returns any injected error and looks up the named crawler.
+// An empty name skips the lookup.
+func (fake *Fake) begin(operation, name string) (*crawler, error) {
+	fake.calls[operation]++
+	if err := fake.errs[operation]; err != nil {
+		return nil, err
+	}
+	if name == "" {
+		return nil, nil
+	}
+	c, ok := fake.crawlers[name]
+	if !ok {
+		return nil, &types.EntityNotFoundException{Message: aws.String("Crawler entry with name " + name + " does not exist")}
+	}
+	return c, nil
+}
+
+// advance moves a running or stopping crawl on by one poll. A running crawl
+// whose polls run out starts stopping, and a stopping one finishes.
+func (fake *Fake) advance(c *crawler) {
+	if c.def.State == types.CrawlerStateReady {
+		return
+	}
+	if c.polls > 0 {
+		c.polls--
+		return
+	}
+	if c.def.State == types.CrawlerStateRunning {
+		// This call reports the first of the STOPPING polls
+		c.def.State = types.CrawlerStateStopping
+		c.polls = fake.stopPolls - 1
+		return
+	}
+
+	now := fake.now()
+	crawl := &c.history[len(c.history)-1]
+	crawl.EndTime = aws.Time(now)
+	crawl.DPUHour = now.Sub(c.started).Hours()
+
+	last := &types.LastCrawlInfo{StartTime: aws.Time(c.started)}
+	switch {
+	case c.cancelled:
+		last.Status = types.LastCrawlStatusCancelled
+		crawl.State = types.CrawlerHistoryStateStopped
+	case c.fail != "":
+		last.Status = types.LastCrawlStatusFailed
+		last.ErrorMessage = aws.String(c.fail)
+		crawl.State = types.CrawlerHistoryStateFailed
+		crawl.ErrorMessage = aws.String(c.fail)
+		c.fail = ""
+	default:
+		last.Status = types.LastCrawlStatusSucceeded
+		crawl.State = types.CrawlerHistoryStateCompleted
+		crawl.Summary = aws.String("{}")
+	}
+
+	c.def.State = types.CrawlerStateReady
+	c.def.LastCrawl = last
+	c.def.LastUpdated = aws.Time(now)
+	c.metrics.LastRuntimeSeconds = now.Sub(c.started).Seconds()
+	c.metrics.MedianRuntimeSeconds = medianRuntime(c.history)
+}
+
+// medianRuntime returns the median runtime in seconds of the finished crawls.
+func medianRuntime(history []types.CrawlerHistory) float64 {
+	runtimes := []float64{}
+	for _, crawl := range history {
+		if crawl.StartTime != nil && crawl.EndTime != nil {
+			runtimes = append(runtimes, crawl.EndTime.Sub(*crawl.StartTime).Seconds())
+		}
+	}
+	if len(runtimes) == 0 {
+		return 0
+	}
+	sort.Float64s(runtimes)
+	mid := len(runtimes) / 2
+	if len(runtimes)%2 == 0 {
+		return (runtimes[mid-1] + runtimes[mid]) / 2
+	}
+	return runtimes[mid]
+}
+
+// paginate returns the [start, end) bounds of the page at token and the token of the next page.
+func paginate(total int, token *string, maxResults *int32) ([2]int, *string, error) {
+	start := 0
+	if token != nil {
+		var err error
+		if start, err = strconv.Atoi(*token); err != nil || start < 0 || start > total {
+			return [2]int{}, nil, &types.InvalidInputException{Message: aws.String(fmt.Sprintf("invalid next token %q", *token))}
+		}
+	}
+
+	end := total
+	if maxResults != nil && *maxResults > 0 && start+int(*maxResults) < total {
+		end = start + int(*maxResults)
+	}
+
+	var next *string
+	if end < total {
+		next = aws.String(strconv.Itoa(end))
+	}
+	return [2]int{start, end}, next, nil
+}
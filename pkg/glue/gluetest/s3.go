@@ -0,0 +1,163 @@
+package gluetest
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	rtlglue "github.com/rmrfslashbin/aws-cf-rtl/pkg/glue"
+)
+
+var _ rtlglue.S3API = (*FakeS3)(nil)
+
+// FakeS3 is an in-memory S3API serving the keys added with AddObject and AddObjectBody.
+type FakeS3 struct {
+	mu      sync.Mutex
+	buckets map[string]map[string]object
+}
+
+// object is a fake S3 object; objects added with AddObject have a size but no body.
+type object struct {
+	size int64
+	body []byte
+}
+
+// NewFakeS3 returns an empty FakeS3.
+func NewFakeS3() *FakeS3 {
+	return &FakeS3{
+		buckets: make(map[string]map[string]object),
+	}
+}
+
+// AddObject adds a key of size bytes to bucket. GetObject returns it with an empty body.
+func (fake *FakeS3) AddObject(bucket, key string, size int64) {
+	fake.put(bucket, key, object{size: size})
+}
+
+// AddObjectBody adds a key holding body to bucket.
+func (fake *FakeS3) AddObjectBody(bucket, key string, body []byte) {
+	fake.put(bucket, key, object{size: int64(len(body)), body: body})
+}
+
+// put stores the object at key in bucket.
+func (fake *FakeS3) put(bucket, key string, obj object) {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	if fake.buckets[bucket] == nil {
+		fake.buckets[bucket] = make(map[string]object)
+	}
+	fake.buckets[bucket][key] = obj
+}
+
+// GetObject returns the body of the key, honouring a Range of the form bytes=first-last.
+func (fake *FakeS3) GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	objects, ok := fake.buckets[aws.ToString(params.Bucket)]
+	if !ok {
+		return nil, &types.NoSuchBucket{Message: aws.String("The specified bucket does not exist")}
+	}
+	obj, ok := objects[aws.ToString(params.Key)]
+	if !ok {
+		return nil, &types.NoSuchKey{Message: aws.String("The specified key does not exist.")}
+	}
+
+	body := obj.body
+	if r := aws.ToString(params.Range); r != "" {
+		var first, last int
+		if _, err := fmt.Sscanf(r, "bytes=%d-%d", &first, &last); err != nil || first > last {
+			return nil, fmt.Errorf("unsupported range %q", r)
+		}
+		if first > len(body) {
+			first = len(body)
+		}
+		if last >= len(body) {
+			last = len(body) - 1
+		}
+		body = body[first : last+1]
+	}
+	return &s3.GetObjectOutput{
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+	}, nil
+}
+
+// ListObjectsV2 lists keys in order, honouring Prefix, Delimiter, MaxKeys and ContinuationToken.
+func (fake *FakeS3) ListObjectsV2(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	bucket := aws.ToString(params.Bucket)
+	objects, ok := fake.buckets[bucket]
+	if !ok {
+		return nil, &types.NoSuchBucket{Message: aws.String("The specified bucket does not exist")}
+	}
+	prefix := aws.ToString(params.Prefix)
+	delimiter := aws.ToString(params.Delimiter)
+
+	// Collapse keys under the delimiter into common prefixes, as S3 does
+	entries := []string{}
+	common := map[string]bool{}
+	for key := range objects {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		if delimiter != "" {
+			if i := strings.Index(key[len(prefix):], delimiter); i >= 0 {
+				cp := key[:len(prefix)+i+len(delimiter)]
+				if !common[cp] {
+					common[cp] = true
+					entries = append(entries, cp)
+				}
+				continue
+			}
+		}
+		entries = append(entries, key)
+	}
+	sort.Strings(entries)
+
+	// The continuation token is the last entry returned
+	if token := aws.ToString(params.ContinuationToken); token != "" {
+		entries = entries[sort.SearchStrings(entries, token):]
+		if len(entries) > 0 && entries[0] == token {
+			entries = entries[1:]
+		}
+	}
+
+	maxKeys := int(params.MaxKeys)
+	if maxKeys <= 0 {
+		maxKeys = 1000
+	}
+	out := &s3.ListObjectsV2Output{
+		Name:      params.Bucket,
+		Prefix:    params.Prefix,
+		Delimiter: params.Delimiter,
+		MaxKeys:   int32(maxKeys),
+	}
+	if len(entries) > maxKeys {
+		entries = entries[:maxKeys]
+		out.IsTruncated = true
+		out.NextContinuationToken = aws.String(entries[len(entries)-1])
+	}
+	for _, entry := range entries {
+		if common[entry] {
+			out.CommonPrefixes = append(out.CommonPrefixes, types.CommonPrefix{Prefix: aws.String(entry)})
+			continue
+		}
+		out.Contents = append(out.Contents, types.Object{
+			Key:  aws.String(entry),
+			Size: objects[entry].size,
+		})
+	}
+	out.KeyCount = int32(len(entries))
+	return out, nil
+}
@@ -0,0 +1,417 @@
+package glue
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/glue"
+	"github.com/aws/aws-sdk-go-v2/service/glue/types"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/sirupsen/logrus"
+)
+
+// maxBatchCreatePartitions is the most partitions BatchCreatePartition accepts per call.
+const maxBatchCreatePartitions = 100
+
+// storageFormat describes a file format to the catalog.
+type storageFormat struct {
+	magic        string
+	serDe        string
+	inputFormat  string
+	outputFormat string
+}
+
+// storageFormats are the formats Firehose record format conversion writes, known by the
+// magic bytes that start each file.
+var storageFormats = []storageFormat{
+	{
+		magic:        "ORC",
+		serDe:        "org.apache.hadoop.hive.ql.io.orc.OrcSerde",
+		inputFormat:  "org.apache.hadoop.hive.ql.io.orc.OrcInputFormat",
+		outputFormat: "org.apache.hadoop.hive.ql.io.orc.OrcOutputFormat",
+	},
+	{
+		magic:        "PAR1",
+		serDe:        "org.apache.hadoop.hive.ql.io.parquet.serde.ParquetHiveSerDe",
+		inputFormat:  "org.apache.hadoop.hive.ql.io.parquet.MapredParquetInputFormat",
+		outputFormat: "org.apache.hadoop.hive.ql.io.parquet.MapredParquetOutputFormat",
+	},
+}
+
+// maxMagic is the longest magic in storageFormats.
+const maxMagic = 4
+
+// Partition is a table partition: its key values in order and its S3 location.
+type Partition struct {
+	Values   []string `json:"values"`
+	Location string   `json:"location"`
+}
+
+// PartitionSync reports the result of SyncPartitions.
+type PartitionSync struct {
+	Found    int         `json:"found"`
+	Existing int         `json:"existing"`
+	Created  int         `json:"created"`
+	Missing  []Partition `json:"missing"`
+	Errors   []string    `json:"errors,omitempty"`
+}
+
+// key identifies the partition by its values.
+func (partition Partition) key() string {
+	return strings.Join(partition.Values, "/")
+}
+
+// getTable returns the configured table.
+func (config *Config) getTable(ctx context.Context) (*types.Table, error) {
+	if config.database == "" || config.table == "" {
+		return nil, ErrTableNotSet
+	}
+
+	out, err := config.glue.GetTable(ctx, &glue.GetTableInput{
+		DatabaseName: aws.String(config.database),
+		Name:         aws.String(config.table),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if out.Table.StorageDescriptor == nil || aws.ToString(out.Table.StorageDescriptor.Location) == "" {
+		return nil, fmt.Errorf("table %s.%s has no location", config.database, config.table)
+	}
+	if len(out.Table.PartitionKeys) == 0 {
+		return nil, fmt.Errorf("table %s.%s is not partitioned", config.database, config.table)
+	}
+	return out.Table, nil
+}
+
+// ListPartitions returns the partitions registered in the catalog for the table.
+func (config *Config) ListPartitions(ctx context.Context) ([]Partition, error) {
+	if config.database == "" || config.table == "" {
+		return nil, ErrTableNotSet
+	}
+
+	partitions := []Partition{}
+	paginator := glue.NewGetPartitionsPaginator(config.glue, &glue.GetPartitionsInput{
+		DatabaseName:        aws.String(config.database),
+		TableName:           aws.String(config.table),
+		ExcludeColumnSchema: aws.Bool(true),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range page.Partitions {
+			partition := Partition{Values: p.Values}
+			if p.StorageDescriptor != nil {
+				partition.Location = aws.ToString(p.StorageDescriptor.Location)
+			}
+			partitions = append(partitions, partition)
+		}
+	}
+	return partitions, nil
+}
+
+// ListS3Partitions walks the table location for Hive style key=value prefixes,
+// e.g. processed/rtl/year=2022/month=11/day=5/, matching the table partition keys.
+func (config *Config) ListS3Partitions(ctx context.Context) ([]Partition, error) {
+	table, err := config.getTable(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return config.listS3Partitions(ctx, table)
+}
+
+// listS3Partitions walks the location of table for its partitions.
+func (config *Config) listS3Partitions(ctx context.Context, table *types.Table) ([]Partition, error) {
+	bucket, prefix, err := splitS3URL(aws.ToString(table.StorageDescriptor.Location))
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, len(table.PartitionKeys))
+	for i, column := range table.PartitionKeys {
+		keys[i] = aws.ToString(column.Name)
+	}
+
+	partitions := []Partition{}
+	if err := config.walkPartitions(ctx, bucket, prefix, keys, nil, &partitions); err != nil {
+		return nil, err
+	}
+	return partitions, nil
+}
+
+// walkPartitions lists the prefixes for the next partition key and descends into each.
+func (config *Config) walkPartitions(ctx context.Context, bucket, prefix string, keys, values []string, partitions *[]Partition) error {
+	if len(keys) == 0 {
+		*partitions = append(*partitions, Partition{
+			Values:   append([]string{}, values...),
+			Location: fmt.Sprintf("s3://%s/%s", bucket, prefix),
+		})
+		return nil
+	}
+
+	client, err := config.s3Client(ctx)
+	if err != nil {
+		return err
+	}
+	paginator := s3.NewListObjectsV2Paginator(client, &s3.ListObjectsV2Input{
+		Bucket:    aws.String(bucket),
+		Prefix:    aws.String(prefix),
+		Delimiter: aws.String("/"),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return err
+		}
+		for _, common := range page.CommonPrefixes {
+			child := aws.ToString(common.Prefix)
+			name := strings.TrimSuffix(strings.TrimPrefix(child, prefix), "/")
+			key, value, ok := strings.Cut(name, "=")
+			if !ok || key != keys[0] || value == "" {
+				continue
+			}
+			if err := config.walkPartitions(ctx, bucket, child, keys[1:], append(values, value), partitions); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// SyncPartitions registers partitions found in S3 that are missing from the catalog.
+// With dryRun set, the missing partitions are reported but not created.
+func (config *Config) SyncPartitions(ctx context.Context, dryRun bool) (*PartitionSync, error) {
+	table, err := config.getTable(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	found, err := config.listS3Partitions(ctx, table)
+	if err != nil {
+		return nil, err
+	}
+	existing, err := config.ListPartitions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	registered := make(map[string]bool, len(existing))
+	for _, partition := range existing {
+		registered[partition.key()] = true
+	}
+
+	result := &PartitionSync{
+		Found:    len(found),
+		Existing: len(existing),
+		Missing:  []Partition{},
+	}
+	for _, partition := range found {
+		if !registered[partition.key()] {
+			result.Missing = append(result.Missing, partition)
+		}
+	}
+	sort.Slice(result.Missing, func(i, j int) bool {
+		return lessValues(result.Missing[i].Values, result.Missing[j].Values)
+	})
+
+	if dryRun {
+		return result, nil
+	}
+
+	for start := 0; start < len(result.Missing); start += maxBatchCreatePartitions {
+		end := start + maxBatchCreatePartitions
+		if end > len(result.Missing) {
+			end = len(result.Missing)
+		}
+		batch := result.Missing[start:end]
+
+		inputs := make([]types.PartitionInput, len(batch))
+		for i, partition := range batch {
+			format, err := config.partitionFormat(ctx, partition)
+			if err != nil {
+				return result, err
+			}
+			inputs[i] = partitionInput(table, partition, format)
+		}
+		out, err := config.glue.BatchCreatePartition(ctx, &glue.BatchCreatePartitionInput{
+			DatabaseName:       aws.String(config.database),
+			TableName:          aws.String(config.table),
+			PartitionInputList: inputs,
+		})
+		if err != nil {
+			return result, err
+		}
+
+		created := len(batch)
+		for _, perr := range out.Errors {
+			code, message := "", ""
+			if perr.ErrorDetail != nil {
+				code = aws.ToString(perr.ErrorDetail.ErrorCode)
+				message = aws.ToString(perr.ErrorDetail.ErrorMessage)
+			}
+			created--
+			// Another writer may have registered it since we listed
+			if code == "AlreadyExistsException" {
+				continue
+			}
+			result.Errors = append(result.Errors, fmt.Sprintf("%s: %s %s", strings.Join(perr.PartitionValues, "/"), code, message))
+		}
+		result.Created += created
+
+		if config.log != nil {
+			config.log.WithFields(logrus.Fields{
+				"table":   config.database + "." + config.table,
+				"created": created,
+				"errors":  len(out.Errors),
+			}).Debug("created partitions")
+		}
+	}
+	return result, nil
+}
+
+// partitionFormat reads the start of the first non-empty object in the partition and
+// returns its format, or nil when it is none of storageFormats (e.g. JSON) or the
+// partition holds no data.
+func (config *Config) partitionFormat(ctx context.Context, partition Partition) (*storageFormat, error) {
+	bucket, prefix, err := splitS3URL(partition.Location)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := config.s3Client(ctx)
+	if err != nil {
+		return nil, err
+	}
+	paginator := s3.NewListObjectsV2Paginator(client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(bucket),
+		Prefix: aws.String(prefix),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, object := range page.Contents {
+			if object.Size == 0 {
+				continue
+			}
+			key := aws.ToString(object.Key)
+			out, err := client.GetObject(ctx, &s3.GetObjectInput{
+				Bucket: aws.String(bucket),
+				Key:    aws.String(key),
+				Range:  aws.String(fmt.Sprintf("bytes=0-%d", maxMagic-1)),
+			})
+			if err != nil {
+				return nil, fmt.Errorf("s3://%s/%s: %w", bucket, key, err)
+			}
+			head := make([]byte, maxMagic)
+			n, err := io.ReadFull(out.Body, head)
+			out.Body.Close()
+			if err != nil && err != io.ErrUnexpectedEOF {
+				return nil, fmt.Errorf("s3://%s/%s: %w", bucket, key, err)
+			}
+			for i := range storageFormats {
+				if strings.HasPrefix(string(head[:n]), storageFormats[i].magic) {
+					return &storageFormats[i], nil
+				}
+			}
+			return nil, nil
+		}
+	}
+	return nil, nil
+}
+
+// partitionInput builds the partition from the table storage descriptor at the partition location.
+// The template table keeps the JSON SerDe, as Firehose format conversion reads its columns to
+// parse the records, but the files it writes under processed/ are ORC. With format set, the
+// partition is described by the format the files are actually in rather than by the table.
+func partitionInput(table *types.Table, partition Partition, format *storageFormat) types.PartitionInput {
+	sd := *table.StorageDescriptor
+	sd.Location = aws.String(partition.Location)
+	if format != nil {
+		sd.InputFormat = aws.String(format.inputFormat)
+		sd.OutputFormat = aws.String(format.outputFormat)
+		sd.SerdeInfo = &types.SerDeInfo{
+			SerializationLibrary: aws.String(format.serDe),
+			Parameters:           map[string]string{"serialization.format": "1"},
+		}
+	}
+	return types.PartitionInput{
+		Values:            partition.Values,
+		StorageDescriptor: &sd,
+	}
+}
+
+// PartitionProjection returns the Athena partition projection table properties for the table,
+// an alternative to registering partitions. Partition keys named year, month and day are
+// projected as unpadded integers, matching the Firehose prefix, with years from firstYear to lastYear.
+func (config *Config) PartitionProjection(ctx context.Context, firstYear, lastYear int) (map[string]string, error) {
+	table, err := config.getTable(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if lastYear < firstYear {
+		return nil, fmt.Errorf("last year %d is before first year %d", lastYear, firstYear)
+	}
+
+	location := strings.TrimSuffix(aws.ToString(table.StorageDescriptor.Location), "/")
+	properties := map[string]string{
+		"projection.enabled": "true",
+	}
+	for _, column := range table.PartitionKeys {
+		key := aws.ToString(column.Name)
+		var keyRange string
+		switch key {
+		case "year":
+			keyRange = fmt.Sprintf("%d,%d", firstYear, lastYear)
+		case "month":
+			keyRange = "1,12"
+		case "day":
+			keyRange = "1,31"
+		default:
+			return nil, fmt.Errorf("no projection for partition key %s", key)
+		}
+		properties["projection."+key+".type"] = "integer"
+		properties["projection."+key+".range"] = keyRange
+		location += fmt.Sprintf("/%s=${%s}", key, key)
+	}
+	properties["storage.location.template"] = location + "/"
+	return properties, nil
+}
+
+// splitS3URL splits s3://bucket/prefix into the bucket and a prefix ending in "/".
+func splitS3URL(location string) (string, string, error) {
+	if !strings.HasPrefix(location, "s3://") {
+		return "", "", fmt.Errorf("not an s3 location: %s", location)
+	}
+	bucket, prefix, _ := strings.Cut(strings.TrimPrefix(location, "s3://"), "/")
+	if bucket == "" {
+		return "", "", fmt.Errorf("no bucket in s3 location: %s", location)
+	}
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	return bucket, prefix, nil
+}
+
+// lessValues orders partition values numerically where they are numbers.
+func lessValues(a, b []string) bool {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] == b[i] {
+			continue
+		}
+		x, xerr := strconv.Atoi(a[i])
+		y, yerr := strconv.Atoi(b[i])
+		if xerr == nil && yerr == nil {
+			return x < y
+		}
+		return a[i] < b[i]
+	}
+	return len(a) < len(b)
+}
@@ -0,0 +1,114 @@
+package glue_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsglue "github.com/aws/aws-sdk-go-v2/service/glue"
+	"github.com/aws/aws-sdk-go-v2/service/glue/types"
+	"github.com/rmrfslashbin/aws-cf-rtl/pkg/glue"
+	"github.com/rmrfslashbin/aws-cf-rtl/pkg/glue/gluetest"
+	"github.com/rmrfslashbin/aws-cf-rtl/pkg/rtl"
+)
+
+const (
+	testBucket   = "rtl-bucket"
+	testDatabase = "cfrtl"
+	testTable    = "rtl"
+)
+
+// newCatalog returns a Config for a table described like the template's: the JSON
+// SerDe over processed/rtl/, partitioned by year, month and day.
+func newCatalog(t *testing.T) (*glue.Config, *gluetest.Fake, *gluetest.FakeS3) {
+	t.Helper()
+	keys := make([]types.Column, len(rtl.PartitionKeys))
+	for i, key := range rtl.PartitionKeys {
+		keys[i] = types.Column{Name: aws.String(key.Name), Type: aws.String(key.Type)}
+	}
+	fake := gluetest.NewFake()
+	fake.AddTable(testDatabase, types.Table{
+		Name:          aws.String(testTable),
+		PartitionKeys: keys,
+		StorageDescriptor: &types.StorageDescriptor{
+			Location:     aws.String("s3://" + testBucket + "/processed/rtl/"),
+			InputFormat:  aws.String("org.apache.hadoop.mapred.TextInputFormat"),
+			OutputFormat: aws.String("org.apache.hadoop.hive.ql.io.HiveIgnoreKeyTextOutputFormat"),
+			SerdeInfo: &types.SerDeInfo{
+				SerializationLibrary: aws.String(rtl.SerDeLibrary),
+				Parameters:           map[string]string{"paths": rtl.SerDePaths()},
+			},
+		},
+	})
+	fakeS3 := gluetest.NewFakeS3()
+	config, err := glue.NewCrawler(
+		glue.SetClient(fake),
+		glue.SetS3Client(fakeS3),
+		glue.SetDatabase(testDatabase),
+		glue.SetTable(testTable),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return config, fake, fakeS3
+}
+
+func TestSyncPartitionsFormat(t *testing.T) {
+	ctx := context.Background()
+	config, fake, fakeS3 := newCatalog(t)
+	prefix := "processed/rtl/year=2022/month=11/"
+	fakeS3.AddObjectBody(testBucket, prefix+"day=15/cf-rtl-logs-1", []byte("ORC\x0a\x03\x00"))
+	fakeS3.AddObjectBody(testBucket, prefix+"day=16/cf-rtl-logs-1", []byte("PAR1\x15\x04"))
+	fakeS3.AddObjectBody(testBucket, prefix+"day=17/cf-rtl-logs-1", []byte(`{"timestamp":1668643200000}`))
+	// Empty objects are skipped when reading the format
+	fakeS3.AddObject(testBucket, prefix+"day=18/cf-rtl-logs-0", 0)
+	fakeS3.AddObjectBody(testBucket, prefix+"day=18/cf-rtl-logs-1", []byte("ORC\x0a\x03\x00"))
+
+	result, err := config.SyncPartitions(ctx, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Found != 4 || result.Created != 4 || len(result.Errors) != 0 {
+		t.Fatalf("sync %+v; want 4 found and created", result)
+	}
+
+	out, err := fake.GetPartitions(ctx, &awsglue.GetPartitionsInput{
+		DatabaseName: aws.String(testDatabase),
+		TableName:    aws.String(testTable),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"15": "org.apache.hadoop.hive.ql.io.orc.OrcSerde",
+		"16": "org.apache.hadoop.hive.ql.io.parquet.serde.ParquetHiveSerDe",
+		"17": rtl.SerDeLibrary,
+		"18": "org.apache.hadoop.hive.ql.io.orc.OrcSerde",
+	}
+	for _, partition := range out.Partitions {
+		day := partition.Values[2]
+		sd := partition.StorageDescriptor
+		if serDe := aws.ToString(sd.SerdeInfo.SerializationLibrary); serDe != want[day] {
+			t.Errorf("day %s SerDe %s; want %s", day, serDe, want[day])
+		}
+		if location := aws.ToString(sd.Location); location != "s3://"+testBucket+"/"+prefix+"day="+day+"/" {
+			t.Errorf("day %s location %s", day, location)
+		}
+		if _, ok := sd.SerdeInfo.Parameters["paths"]; ok && want[day] != rtl.SerDeLibrary {
+			t.Errorf("day %s kept the JSON SerDe paths", day)
+		}
+	}
+	if day15 := out.Partitions[0].StorageDescriptor; aws.ToString(day15.InputFormat) != "org.apache.hadoop.hive.ql.io.orc.OrcInputFormat" ||
+		aws.ToString(day15.OutputFormat) != "org.apache.hadoop.hive.ql.io.orc.OrcOutputFormat" {
+		t.Errorf("ORC partition formats %s %s", aws.ToString(day15.InputFormat), aws.ToString(day15.OutputFormat))
+	}
+
+	// The table itself is left as it was
+	table, err := fake.GetTable(ctx, &awsglue.GetTableInput{DatabaseName: aws.String(testDatabase), Name: aws.String(testTable)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if serDe := aws.ToString(table.Table.StorageDescriptor.SerdeInfo.SerializationLibrary); serDe != rtl.SerDeLibrary {
+		t.Errorf("table SerDe changed to %s", serDe)
+	}
+}
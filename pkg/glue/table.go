@@ -0,0 +1,58 @@
+package glue
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/glue/types"
+)
+
+// Column is a Glue table column.
+type Column struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// TableSchema is the column layout of a Glue table.
+type TableSchema struct {
+	Columns       []Column          `json:"columns"`
+	PartitionKeys []Column          `json:"partition_keys"`
+	Location      string            `json:"location"`
+	SerDeLibrary  string            `json:"serde_library"`
+	SerDeParams   map[string]string `json:"serde_parameters"`
+}
+
+// GetTableSchema returns the columns, partition keys and SerDe of the table.
+func (config *Config) GetTableSchema(ctx context.Context) (*TableSchema, error) {
+	table, err := config.getTable(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	sd := table.StorageDescriptor
+	schema := &TableSchema{
+		Columns:       columns(sd.Columns),
+		PartitionKeys: columns(table.PartitionKeys),
+		Location:      aws.ToString(sd.Location),
+		SerDeParams:   map[string]string{},
+	}
+	if sd.SerdeInfo != nil {
+		schema.SerDeLibrary = aws.ToString(sd.SerdeInfo.SerializationLibrary)
+		for key, value := range sd.SerdeInfo.Parameters {
+			schema.SerDeParams[key] = value
+		}
+	}
+	return schema, nil
+}
+
+// columns converts the SDK columns.
+func columns(in []types.Column) []Column {
+	out := make([]Column, len(in))
+	for i, column := range in {
+		out[i] = Column{
+			Name: aws.ToString(column.Name),
+			Type: aws.ToString(column.Type),
+		}
+	}
+	return out
+}
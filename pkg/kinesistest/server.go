@@ -0,0 +1,306 @@
+// Package kinesistest runs an in-process HTTP server speaking the subset of the
+// Kinesis JSON API used by this project: PutRecord, PutRecords, ListShards,
+// GetShardIterator and GetRecords. Clients reach it by setting the base
+// endpoint to its URL, so the real SDK client, with its serialization, retries and error
+// handling, is exercised end to end without AWS.
+package kinesistest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kinesis"
+	"github.com/aws/aws-sdk-go-v2/service/kinesis/types"
+)
+
+// Per-shard write limits of Kinesis, the defaults of SetShardLimits.
+const (
+	DefaultShardRecordsPerSecond = 1000
+	DefaultShardBytesPerSecond   = 1 << 20
+)
+
+// Region and Account are those the server claims streams are in.
+const (
+	Region  = "us-east-1"
+	Account = "123456789012"
+)
+
+// targetPrefix prefixes the operation in the X-Amz-Target header.
+const targetPrefix = "Kinesis_20131202."
+
+// Option configures a Server.
+type Option func(server *Server)
+
+// Server is an in-process Kinesis endpoint holding streams in memory.
+// Every record put is kept, so a test can read back exactly what was delivered.
+type Server struct {
+	*httptest.Server
+
+	mu              sync.Mutex
+	now             func() time.Time
+	rand            *rand.Rand
+	recordsPerShard int
+	bytesPerShard   int
+	streams         map[string]*stream
+	sequence        int64
+	errs            map[string]string
+	failNext        map[string]*failure
+	recordFailShare float64
+	recordFailCode  string
+	calls           map[string]int
+	requests        int
+}
+
+// failure is an error injected into the next calls of an operation.
+type failure struct {
+	code  string
+	calls int
+}
+
+// NewServer starts a Server with no streams. Add streams with AddStream and
+// stop the server with Close.
+func NewServer(opts ...Option) *Server {
+	server := &Server{
+		now:             time.Now,
+		rand:            rand.New(rand.NewSource(1)),
+		recordsPerShard: DefaultShardRecordsPerSecond,
+		bytesPerShard:   DefaultShardBytesPerSecond,
+		streams:         make(map[string]*stream),
+		errs:            make(map[string]string),
+		failNext:        make(map[string]*failure),
+		calls:           make(map[string]int),
+	}
+
+	// apply the list of options to Server
+	for _, opt := range opts {
+		opt(server)
+	}
+
+	server.Server = httptest.NewServer(http.HandlerFunc(server.serveHTTP))
+	return server
+}
+
+// SetClock replaces time.Now for arrival timestamps and the per-second shard limits.
+func SetClock(now func() time.Time) Option {
+	return func(server *Server) {
+		server.now = now
+	}
+}
+
+// SetSeed seeds the random choice of the records failed by SetRecordFailures.
+func SetSeed(seed int64) Option {
+	return func(server *Server) {
+		server.rand = rand.New(rand.NewSource(seed))
+	}
+}
+
+// SetShardLimits sets the records and bytes each shard accepts per second.
+// Writes beyond them are throttled with ProvisionedThroughputExceededException,
+// as Kinesis does. Zero disables a limit.
+func SetShardLimits(recordsPerSecond, bytesPerSecond int) Option {
+	return func(server *Server) {
+		server.recordsPerShard = recordsPerSecond
+		server.bytesPerShard = bytesPerSecond
+	}
+}
+
+// Client returns a Kinesis client for the server. It signs with fixed
+// credentials, which the server does not check. optFns may change the client,
+// e.g. to turn off SDK retries with aws.NopRetryer.
+func (server *Server) Client(optFns ...func(*kinesis.Options)) *kinesis.Client {
+	opts := kinesis.Options{
+		Region:       Region,
+		BaseEndpoint: aws.String(server.URL),
+		Credentials: aws.CredentialsProviderFunc(func(ctx context.Context) (aws.Credentials, error) {
+			return aws.Credentials{AccessKeyID: "AKIDKINESISTEST", SecretAccessKey: "kinesistest", Source: "kinesistest"}, nil
+		}),
+	}
+	return kinesis.New(opts, optFns...)
+}
+
+// AddStream adds an empty stream with shards shards splitting the hash key
+// space evenly, replacing any stream of the same name.
+func (server *Server) AddStream(name string, shards int) {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+
+	if shards < 1 {
+		shards = 1
+	}
+	s := &stream{name: name, shards: make([]*shard, shards)}
+	step := new(big.Int).Div(maxHashKey, big.NewInt(int64(shards)))
+	for i := range s.shards {
+		start := new(big.Int).Mul(step, big.NewInt(int64(i)))
+		end := new(big.Int).Sub(new(big.Int).Add(start, step), big.NewInt(1))
+		if i == shards-1 {
+			end = new(big.Int).Sub(maxHashKey, big.NewInt(1))
+		}
+		s.shards[i] = &shard{
+			id:    fmt.Sprintf("shardId-%012d", i),
+			start: start,
+			end:   end,
+		}
+	}
+	server.streams[name] = s
+}
+
+// SetError makes every call to operation, e.g. "PutRecords", fail with the
+// error code, e.g. "InternalFailure". An empty code clears it.
+func (server *Server) SetError(operation, code string) {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+
+	if code == "" {
+		delete(server.errs, operation)
+		return
+	}
+	server.errs[operation] = code
+}
+
+// FailNext makes the next calls to operation fail with the error code, e.g.
+// "ProvisionedThroughputExceededException".
+func (server *Server) FailNext(operation, code string, calls int) {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+
+	server.failNext[operation] = &failure{code: code, calls: calls}
+}
+
+// SetRecordFailures makes PutRecords reject a share of the records, chosen at
+// random, with the error code: "ProvisionedThroughputExceededException" or
+// "InternalFailure". A zero share turns it off.
+func (server *Server) SetRecordFailures(share float64, code string) {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+
+	server.recordFailShare = share
+	server.recordFailCode = code
+}
+
+// Calls returns how many times operation has been called.
+func (server *Server) Calls(operation string) int {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	return server.calls[operation]
+}
+
+// Records returns every record put to the stream, over all shards, in the order they arrived.
+func (server *Server) Records(name string) []types.Record {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+
+	s, ok := server.streams[name]
+	if !ok {
+		return nil
+	}
+	records := []types.Record{}
+	for _, sh := range s.shards {
+		for _, r := range sh.records {
+			records = append(records, r.record())
+		}
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return aws.ToString(records[i].SequenceNumber) < aws.ToString(records[j].SequenceNumber)
+	})
+	return records
+}
+
+// apiError is an error response of the Kinesis JSON API.
+type apiError struct {
+	code    string
+	message string
+}
+
+func (e *apiError) Error() string {
+	return e.code + ": " + e.message
+}
+
+// status is the HTTP status Kinesis answers the error with.
+func (e *apiError) status() int {
+	switch e.code {
+	case "InternalFailure":
+		return http.StatusInternalServerError
+	case "ServiceUnavailable":
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusBadRequest
+}
+
+// errorf returns an apiError with a formatted message.
+func errorf(code, format string, args ...interface{}) *apiError {
+	return &apiError{code: code, message: fmt.Sprintf(format, args...)}
+}
+
+// serveHTTP dispatches a request on its X-Amz-Target header.
+func (server *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	operation := strings.TrimPrefix(r.Header.Get("X-Amz-Target"), targetPrefix)
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, errorf("SerializationException", "%s", err))
+		return
+	}
+
+	handle, ok := handlers[operation]
+	if r.Method != http.MethodPost || !ok {
+		writeError(w, errorf("UnknownOperationException", "operation %q is not supported", operation))
+		return
+	}
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	aerr := server.begin(operation)
+	w.Header().Set("X-Amzn-Requestid", fmt.Sprintf("00000000-0000-4000-8000-%012x", server.requests))
+	if aerr != nil {
+		writeError(w, aerr)
+		return
+	}
+	out, aerr := handle(server, body)
+	if aerr != nil {
+		writeError(w, aerr)
+		return
+	}
+	data, err := json.Marshal(out)
+	if err != nil {
+		writeError(w, errorf("InternalFailure", "%s", err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+	w.Write(data)
+}
+
+// begin counts the call and returns any injected error.
+func (server *Server) begin(operation string) *apiError {
+	server.requests++
+	server.calls[operation]++
+	if f := server.failNext[operation]; f != nil {
+		f.calls--
+		if f.calls <= 0 {
+			delete(server.failNext, operation)
+		}
+		return errorf(f.code, "injected failure of %s", operation)
+	}
+	if code := server.errs[operation]; code != "" {
+		return errorf(code, "injected failure of %s", operation)
+	}
+	return nil
+}
+
+// writeError writes an error response the way the SDK deserializes it.
+func writeError(w http.ResponseWriter, err *apiError) {
+	data, _ := json.Marshal(map[string]string{"__type": err.code, "message": err.message})
+	w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+	w.Header().Set("X-Amzn-ErrorType", err.code)
+	w.WriteHeader(err.status())
+	w.Write(data)
+}
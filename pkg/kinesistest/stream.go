@@ -0,0 +1,467 @@
+package kinesistest
+
+import (
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kinesis/types"
+)
+
+// Request limits of Kinesis.
+const (
+	maxRecordSize       = 1 << 20
+	maxPutRecords       = 500
+	maxPutRecordsSize   = 5 << 20
+	maxGetRecordsLimit  = 10000
+	maxListShardsResult = 10000
+)
+
+// maxHashKey is the size of the hash key space, 2^128.
+var maxHashKey = new(big.Int).Lsh(big.NewInt(1), 128)
+
+// stream is a fake stream and its shards.
+type stream struct {
+	name   string
+	shards []*shard
+}
+
+// shard holds the records written to one shard and its write rate in the current second.
+type shard struct {
+	id      string
+	start   *big.Int
+	end     *big.Int
+	records []*record
+	second  int64
+	written int
+	bytes   int
+}
+
+// record is a record stored in a shard.
+type record struct {
+	sequence     string
+	partitionKey string
+	data         []byte
+	arrival      time.Time
+}
+
+// record returns the record as GetRecords returns it.
+func (r *record) record() types.Record {
+	return types.Record{
+		SequenceNumber:              aws.String(r.sequence),
+		PartitionKey:                aws.String(r.partitionKey),
+		Data:                        r.data,
+		ApproximateArrivalTimestamp: aws.Time(r.arrival),
+	}
+}
+
+// handlers are the supported operations, decoding the request body and returning the response.
+var handlers = map[string]func(server *Server, body []byte) (interface{}, *apiError){
+	"PutRecord":        (*Server).putRecord,
+	"PutRecords":       (*Server).putRecords,
+	"ListShards":       (*Server).listShards,
+	"GetShardIterator": (*Server).getShardIterator,
+	"GetRecords":       (*Server).getRecords,
+}
+
+// putRecordsEntry is a record of a PutRecords or PutRecord request.
+type putRecordsEntry struct {
+	Data            []byte
+	PartitionKey    string
+	ExplicitHashKey string
+}
+
+// putRecordsResultEntry is the outcome of one record of a PutRecords request.
+type putRecordsResultEntry struct {
+	SequenceNumber string `json:",omitempty"`
+	ShardId        string `json:",omitempty"`
+	ErrorCode      string `json:",omitempty"`
+	ErrorMessage   string `json:",omitempty"`
+}
+
+// putRecord puts a single record. A throttled record fails the whole call.
+func (server *Server) putRecord(body []byte) (interface{}, *apiError) {
+	var in struct {
+		putRecordsEntry
+		StreamName string
+		StreamARN  string
+	}
+	if err := json.Unmarshal(body, &in); err != nil {
+		return nil, errorf("SerializationException", "%s", err)
+	}
+	s, aerr := server.stream(in.StreamName, in.StreamARN)
+	if aerr != nil {
+		return nil, aerr
+	}
+	if aerr := validateEntry(in.putRecordsEntry); aerr != nil {
+		return nil, aerr
+	}
+
+	result := server.put(s, in.putRecordsEntry)
+	if result.ErrorCode != "" {
+		return nil, errorf(result.ErrorCode, "%s", result.ErrorMessage)
+	}
+	return map[string]string{
+		"SequenceNumber": result.SequenceNumber,
+		"ShardId":        result.ShardId,
+		"EncryptionType": "NONE",
+	}, nil
+}
+
+// putRecords puts a batch of records. Records that are throttled or picked by
+// SetRecordFailures fail on their own and are counted in FailedRecordCount.
+func (server *Server) putRecords(body []byte) (interface{}, *apiError) {
+	var in struct {
+		Records    []putRecordsEntry
+		StreamName string
+		StreamARN  string
+	}
+	if err := json.Unmarshal(body, &in); err != nil {
+		return nil, errorf("SerializationException", "%s", err)
+	}
+	s, aerr := server.stream(in.StreamName, in.StreamARN)
+	if aerr != nil {
+		return nil, aerr
+	}
+	if len(in.Records) == 0 || len(in.Records) > maxPutRecords {
+		return nil, errorf("ValidationException", "1 validation error detected: Value at 'records' failed to satisfy constraint: Member must have length between 1 and %d", maxPutRecords)
+	}
+	size := 0
+	for _, entry := range in.Records {
+		if aerr := validateEntry(entry); aerr != nil {
+			return nil, aerr
+		}
+		size += len(entry.Data) + len(entry.PartitionKey)
+	}
+	if size > maxPutRecordsSize {
+		return nil, errorf("InvalidArgumentException", "Records size exceeds %d bytes", maxPutRecordsSize)
+	}
+
+	out := struct {
+		FailedRecordCount int
+		Records           []putRecordsResultEntry
+		EncryptionType    string
+	}{Records: make([]putRecordsResultEntry, len(in.Records)), EncryptionType: "NONE"}
+	for i, entry := range in.Records {
+		if server.recordFailShare > 0 && server.rand.Float64() < server.recordFailShare {
+			out.Records[i] = putRecordsResultEntry{ErrorCode: server.recordFailCode, ErrorMessage: "injected failure of the record"}
+		} else {
+			out.Records[i] = server.put(s, entry)
+		}
+		if out.Records[i].ErrorCode != "" {
+			out.FailedRecordCount++
+		}
+	}
+	return out, nil
+}
+
+// validateEntry checks a record against the Kinesis limits.
+func validateEntry(entry putRecordsEntry) *apiError {
+	if len(entry.PartitionKey) < 1 || len(entry.PartitionKey) > 256 {
+		return errorf("ValidationException", "1 validation error detected: Value at 'partitionKey' failed to satisfy constraint: Member must have length between 1 and 256")
+	}
+	if len(entry.Data)+len(entry.PartitionKey) > maxRecordSize {
+		return errorf("ValidationException", "1 validation error detected: Value at 'data' failed to satisfy constraint: Member must have length less than or equal to %d", maxRecordSize)
+	}
+	if entry.ExplicitHashKey != "" {
+		if key, ok := new(big.Int).SetString(entry.ExplicitHashKey, 10); !ok || key.Sign() < 0 || key.Cmp(maxHashKey) >= 0 {
+			return errorf("InvalidArgumentException", "Invalid ExplicitHashKey %s", entry.ExplicitHashKey)
+		}
+	}
+	return nil
+}
+
+// put stores a record in the shard its hash key falls in, unless the shard is
+// over its limits for the current second.
+func (server *Server) put(s *stream, entry putRecordsEntry) putRecordsResultEntry {
+	key, ok := new(big.Int).SetString(entry.ExplicitHashKey, 10)
+	if !ok {
+		sum := md5.Sum([]byte(entry.PartitionKey))
+		key = new(big.Int).SetBytes(sum[:])
+	}
+	sh := s.shards[len(s.shards)-1]
+	for _, candidate := range s.shards {
+		if key.Cmp(candidate.end) <= 0 {
+			sh = candidate
+			break
+		}
+	}
+
+	now := server.now()
+	if second := now.Unix(); second != sh.second {
+		sh.second, sh.written, sh.bytes = second, 0, 0
+	}
+	size := len(entry.Data) + len(entry.PartitionKey)
+	if (server.recordsPerShard > 0 && sh.written+1 > server.recordsPerShard) ||
+		(server.bytesPerShard > 0 && sh.bytes+size > server.bytesPerShard) {
+		return putRecordsResultEntry{
+			ErrorCode:    "ProvisionedThroughputExceededException",
+			ErrorMessage: fmt.Sprintf("Rate exceeded for shard %s in stream %s under account %s.", sh.id, s.name, Account),
+		}
+	}
+	sh.written++
+	sh.bytes += size
+
+	server.sequence++
+	r := &record{
+		sequence:     fmt.Sprintf("49%054d", server.sequence),
+		partitionKey: entry.PartitionKey,
+		data:         append([]byte(nil), entry.Data...),
+		arrival:      now,
+	}
+	sh.records = append(sh.records, r)
+	return putRecordsResultEntry{SequenceNumber: r.sequence, ShardId: sh.id}
+}
+
+// listShards returns the shards of a stream, honouring MaxResults, NextToken
+// and ExclusiveStartShardId.
+func (server *Server) listShards(body []byte) (interface{}, *apiError) {
+	var in struct {
+		StreamName            string
+		StreamARN             string
+		NextToken             string
+		MaxResults            int
+		ExclusiveStartShardId string
+	}
+	if err := json.Unmarshal(body, &in); err != nil {
+		return nil, errorf("SerializationException", "%s", err)
+	}
+
+	name, start := in.StreamName, 0
+	if in.NextToken != "" {
+		if in.StreamName != "" || in.StreamARN != "" {
+			return nil, errorf("InvalidArgumentException", "NextToken and StreamName cannot be provided together.")
+		}
+		token, _ := base64.StdEncoding.DecodeString(in.NextToken)
+		stream, index, _ := strings.Cut(string(token), "/")
+		var err error
+		if start, err = strconv.Atoi(index); err != nil || start < 0 {
+			return nil, errorf("InvalidArgumentException", "Invalid NextToken.")
+		}
+		name = stream
+	}
+	s, aerr := server.stream(name, in.StreamARN)
+	if aerr != nil {
+		return nil, aerr
+	}
+	if in.ExclusiveStartShardId != "" {
+		start = sort.Search(len(s.shards), func(i int) bool { return s.shards[i].id > in.ExclusiveStartShardId })
+	}
+	if start > len(s.shards) {
+		start = len(s.shards)
+	}
+
+	end := len(s.shards)
+	max := in.MaxResults
+	if max <= 0 || max > maxListShardsResult {
+		max = maxListShardsResult
+	}
+	if start+max < end {
+		end = start + max
+	}
+
+	type hashKeyRange struct {
+		StartingHashKey string
+		EndingHashKey   string
+	}
+	type sequenceNumberRange struct {
+		StartingSequenceNumber string
+	}
+	type shardOut struct {
+		ShardId             string
+		HashKeyRange        hashKeyRange
+		SequenceNumberRange sequenceNumberRange
+	}
+	out := struct {
+		Shards    []shardOut
+		NextToken string `json:",omitempty"`
+	}{Shards: []shardOut{}}
+	for _, sh := range s.shards[start:end] {
+		out.Shards = append(out.Shards, shardOut{
+			ShardId:             sh.id,
+			HashKeyRange:        hashKeyRange{StartingHashKey: sh.start.String(), EndingHashKey: sh.end.String()},
+			SequenceNumberRange: sequenceNumberRange{StartingSequenceNumber: fmt.Sprintf("49%054d", 0)},
+		})
+	}
+	if end < len(s.shards) {
+		out.NextToken = base64.StdEncoding.EncodeToString([]byte(s.name + "/" + strconv.Itoa(end)))
+	}
+	return out, nil
+}
+
+// iterator is the position a shard iterator points at: the index of the next
+// record to read in the shard.
+type iterator struct {
+	stream   string
+	shard    int
+	position int
+}
+
+// encode returns the opaque shard iterator string.
+func (it iterator) encode() string {
+	return base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("%s/%d/%d", it.stream, it.shard, it.position)))
+}
+
+// decodeIterator parses a shard iterator string.
+func decodeIterator(value string) (iterator, bool) {
+	data, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return iterator{}, false
+	}
+	parts := strings.Split(string(data), "/")
+	if len(parts) != 3 {
+		return iterator{}, false
+	}
+	shard, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return iterator{}, false
+	}
+	position, err := strconv.Atoi(parts[2])
+	if err != nil || position < 0 {
+		return iterator{}, false
+	}
+	return iterator{stream: parts[0], shard: shard, position: position}, true
+}
+
+// getShardIterator returns an iterator for TRIM_HORIZON, LATEST,
+// AT_SEQUENCE_NUMBER, AFTER_SEQUENCE_NUMBER or AT_TIMESTAMP.
+func (server *Server) getShardIterator(body []byte) (interface{}, *apiError) {
+	var in struct {
+		StreamName             string
+		StreamARN              string
+		ShardId                string
+		ShardIteratorType      string
+		StartingSequenceNumber string
+		Timestamp              *float64
+	}
+	if err := json.Unmarshal(body, &in); err != nil {
+		return nil, errorf("SerializationException", "%s", err)
+	}
+	s, aerr := server.stream(in.StreamName, in.StreamARN)
+	if aerr != nil {
+		return nil, aerr
+	}
+	index := -1
+	for i, sh := range s.shards {
+		if sh.id == in.ShardId {
+			index = i
+		}
+	}
+	if index < 0 {
+		return nil, errorf("ResourceNotFoundException", "Shard %s in stream %s under account %s does not exist", in.ShardId, s.name, Account)
+	}
+	records := s.shards[index].records
+
+	var position int
+	switch types.ShardIteratorType(in.ShardIteratorType) {
+	case types.ShardIteratorTypeTrimHorizon:
+		position = 0
+	case types.ShardIteratorTypeLatest:
+		position = len(records)
+	case types.ShardIteratorTypeAtSequenceNumber, types.ShardIteratorTypeAfterSequenceNumber:
+		if len(in.StartingSequenceNumber) != 56 {
+			return nil, errorf("InvalidArgumentException", "StartingSequenceNumber %s used in GetShardIterator on shard %s in stream %s under account %s is invalid.", in.StartingSequenceNumber, in.ShardId, s.name, Account)
+		}
+		position = sort.Search(len(records), func(i int) bool { return records[i].sequence >= in.StartingSequenceNumber })
+		if in.ShardIteratorType == string(types.ShardIteratorTypeAfterSequenceNumber) &&
+			position < len(records) && records[position].sequence == in.StartingSequenceNumber {
+			position++
+		}
+	case types.ShardIteratorTypeAtTimestamp:
+		if in.Timestamp == nil {
+			return nil, errorf("InvalidArgumentException", "Must specify timestampInMillis parameter for iterator of type AT_TIMESTAMP.")
+		}
+		at := time.UnixMilli(int64(*in.Timestamp * 1000))
+		position = sort.Search(len(records), func(i int) bool { return !records[i].arrival.Before(at) })
+	default:
+		return nil, errorf("ValidationException", "1 validation error detected: Value '%s' at 'shardIteratorType' failed to satisfy constraint", in.ShardIteratorType)
+	}
+
+	it := iterator{stream: s.name, shard: index, position: position}
+	return map[string]string{"ShardIterator": it.encode()}, nil
+}
+
+// getRecords returns up to Limit records from the iterator position and the
+// iterator of the record after them.
+func (server *Server) getRecords(body []byte) (interface{}, *apiError) {
+	var in struct {
+		ShardIterator string
+		Limit         int
+	}
+	if err := json.Unmarshal(body, &in); err != nil {
+		return nil, errorf("SerializationException", "%s", err)
+	}
+	it, ok := decodeIterator(in.ShardIterator)
+	if !ok {
+		return nil, errorf("InvalidArgumentException", "Invalid ShardIterator.")
+	}
+	s, aerr := server.stream(it.stream, "")
+	if aerr != nil {
+		return nil, aerr
+	}
+	if it.shard < 0 || it.shard >= len(s.shards) {
+		return nil, errorf("InvalidArgumentException", "Invalid ShardIterator.")
+	}
+	limit := in.Limit
+	if limit <= 0 || limit > maxGetRecordsLimit {
+		limit = maxGetRecordsLimit
+	}
+
+	records := s.shards[it.shard].records
+	start := it.position
+	if start > len(records) {
+		start = len(records)
+	}
+	end := start + limit
+	if end > len(records) {
+		end = len(records)
+	}
+
+	type recordOut struct {
+		SequenceNumber              string
+		ApproximateArrivalTimestamp float64
+		Data                        []byte
+		PartitionKey                string
+	}
+	out := struct {
+		Records            []recordOut
+		NextShardIterator  string
+		MillisBehindLatest int64
+	}{Records: []recordOut{}}
+	for _, r := range records[start:end] {
+		out.Records = append(out.Records, recordOut{
+			SequenceNumber:              r.sequence,
+			ApproximateArrivalTimestamp: float64(r.arrival.UnixMilli()) / 1000,
+			Data:                        r.data,
+			PartitionKey:                r.partitionKey,
+		})
+	}
+	if end < len(records) {
+		out.MillisBehindLatest = server.now().Sub(records[end].arrival).Milliseconds()
+	}
+	it.position = end
+	out.NextShardIterator = it.encode()
+	return out, nil
+}
+
+// stream looks up a stream by name or ARN.
+func (server *Server) stream(name, arn string) (*stream, *apiError) {
+	if name == "" && arn != "" {
+		name = arn[strings.LastIndex(arn, "/")+1:]
+	}
+	if name == "" {
+		return nil, errorf("InvalidArgumentException", "StreamName or StreamARN must be provided.")
+	}
+	s, ok := server.streams[name]
+	if !ok {
+		return nil, errorf("ResourceNotFoundException", "Stream %s under account %s not found.", name, Account)
+	}
+	return s, nil
+}
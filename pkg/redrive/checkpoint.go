@@ -0,0 +1,233 @@
+package redrive
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrCheckpointExists is returned by New when a checkpoint file exists and resuming was not asked for.
+var ErrCheckpointExists = errors.New("checkpoint exists; resume from it or remove it")
+
+// Checkpoint records how far a redrive got so a rerun can resume without resending.
+//
+// The file is a journal of JSON lines: a header naming the stream, then an entry
+// appended after every batch Kinesis accepts. Appending keeps the cost of a save
+// to one short write however many files the redrive covers. Opening the
+// checkpoint replays the journal, the last entry for a file winning, and
+// rewrites it compacted to a temporary file renamed into place.
+type Checkpoint struct {
+	mu      sync.Mutex
+	path    string
+	journal *os.File
+
+	Stream  string                     `json:"stream"`
+	Updated time.Time                  `json:"updated"`
+	Files   map[string]*FileCheckpoint `json:"files"`
+}
+
+// FileCheckpoint is the progress through one file.
+type FileCheckpoint struct {
+	// Line is the number of lines sent or passed over
+	Line int `json:"line"`
+	// Failed are the line numbers up to Line that Kinesis never accepted;
+	// a resume sends just these again
+	Failed []int `json:"failed,omitempty"`
+	// Sequence is the sequence number of the last record Kinesis accepted
+	Sequence string `json:"sequence,omitempty"`
+	// Done is set once every line of the file has been handled
+	Done bool `json:"done"`
+}
+
+// journalEntry is a line of the checkpoint journal. The header sets Stream
+// and every other line sets File.
+type journalEntry struct {
+	Stream  string    `json:"stream,omitempty"`
+	File    string    `json:"file,omitempty"`
+	Updated time.Time `json:"updated"`
+	*FileCheckpoint
+}
+
+// openCheckpoint loads the checkpoint at path for stream, or starts a new one.
+// An existing checkpoint is only used when resume is set.
+func openCheckpoint(path, stream string, resume bool) (*Checkpoint, error) {
+	cp := &Checkpoint{
+		path:   path,
+		Stream: stream,
+		Files:  map[string]*FileCheckpoint{},
+	}
+
+	data, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return cp, nil
+	case err != nil:
+		return nil, err
+	case !resume:
+		return nil, fmt.Errorf("%s: %w", path, ErrCheckpointExists)
+	}
+
+	if err := cp.replay(data); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if cp.Stream != stream {
+		return nil, fmt.Errorf("%s: checkpoint is for stream %s, not %s", path, cp.Stream, stream)
+	}
+	return cp, nil
+}
+
+// replay loads the progress recorded in a journal. A partial last line, left by
+// a crash part way through an append, is ignored.
+func (cp *Checkpoint) replay(data []byte) error {
+	if i := bytes.LastIndexByte(data, '\n'); i < len(data)-1 {
+		data = data[:i+1]
+	}
+	if len(data) == 0 {
+		return errors.New("checkpoint is empty")
+	}
+	cp.Stream = ""
+	for lineNo, line := range bytes.Split(bytes.TrimSuffix(data, []byte("\n")), []byte("\n")) {
+		var entry journalEntry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			return fmt.Errorf("line %d: %w", lineNo+1, err)
+		}
+		switch {
+		case lineNo == 0 && entry.Stream == "":
+			return errors.New("not a checkpoint journal")
+		case entry.Stream != "":
+			cp.Stream = entry.Stream
+		case entry.File != "" && entry.FileCheckpoint != nil:
+			cp.Files[entry.File] = entry.FileCheckpoint
+		}
+		if entry.Updated.After(cp.Updated) {
+			cp.Updated = entry.Updated
+		}
+	}
+	return nil
+}
+
+// file returns a copy of the progress through the named file.
+func (cp *Checkpoint) file(name string) FileCheckpoint {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+
+	if fc, ok := cp.Files[name]; ok {
+		return *fc
+	}
+	return FileCheckpoint{}
+}
+
+// update records the progress through the named file and appends it to the journal.
+// An empty sequence keeps the previous one.
+func (cp *Checkpoint) update(name string, line int, sequence string, failed []int, done bool) error {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+
+	if cp.journal == nil {
+		if err := cp.compact(); err != nil {
+			return err
+		}
+	}
+
+	fc, ok := cp.Files[name]
+	if !ok {
+		fc = &FileCheckpoint{}
+		cp.Files[name] = fc
+	}
+	fc.Line = line
+	fc.Failed = append([]int(nil), failed...)
+	fc.Done = done
+	if sequence != "" {
+		fc.Sequence = sequence
+	}
+	cp.Updated = time.Now().UTC()
+	return cp.append(journalEntry{File: name, Updated: cp.Updated, FileCheckpoint: fc})
+}
+
+// compact writes the header and the progress through every file to a temporary
+// file, renames it into place so a crash never leaves a truncated checkpoint,
+// and opens it for appending.
+func (cp *Checkpoint) compact() error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	if err := enc.Encode(journalEntry{Stream: cp.Stream, Updated: time.Now().UTC()}); err != nil {
+		return err
+	}
+	names := make([]string, 0, len(cp.Files))
+	for name := range cp.Files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := enc.Encode(journalEntry{File: name, Updated: cp.Updated, FileCheckpoint: cp.Files[name]}); err != nil {
+			return err
+		}
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(cp.path), filepath.Base(cp.path)+".*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(buf.Bytes()); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), cp.path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	cp.journal, err = os.OpenFile(cp.path, os.O_WRONLY|os.O_APPEND, 0644)
+	return err
+}
+
+// append writes an entry to the end of the journal in a single write.
+func (cp *Checkpoint) append(entry journalEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	_, err = cp.journal.Write(append(data, '\n'))
+	return err
+}
+
+// close closes the journal. It is safe on a nil Checkpoint and to call twice.
+func (cp *Checkpoint) close() error {
+	if cp == nil {
+		return nil
+	}
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	if cp.journal == nil {
+		return nil
+	}
+	err := cp.journal.Close()
+	cp.journal = nil
+	return err
+}
+
+// remove closes the journal and deletes the checkpoint, once a redrive has
+// sent everything and there is nothing left to resume.
+func (cp *Checkpoint) remove() error {
+	if cp == nil {
+		return nil
+	}
+	if err := cp.close(); err != nil {
+		return err
+	}
+	if err := os.Remove(cp.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,197 @@
+package redrive
+
+import (
+	"fmt"
+	"net"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/rmrfslashbin/aws-cf-rtl/pkg/rtl"
+)
+
+// Filter names, as counted in Stats.Filtered.
+const (
+	FilterTime         = "time"
+	FilterHost         = "host"
+	FilterStatus       = "status"
+	FilterEdgeLocation = "edge-location"
+	FilterClientCIDR   = "client-cidr"
+)
+
+// filter passes the lines whose field values match.
+type filter struct {
+	name    string
+	indexes []int
+	match   func(value string) bool
+}
+
+// passes reports whether any of the filter's fields match.
+func (f filter) passes(parts []string) bool {
+	for _, i := range f.indexes {
+		if f.match(parts[i]) {
+			return true
+		}
+	}
+	return false
+}
+
+// buildFilters turns the configured filters into checks on the field list.
+func (config *Config) buildFilters() error {
+	config.filters = nil
+	add := func(name string, match func(string) bool, fields ...string) error {
+		f := filter{name: name, match: match}
+		for _, field := range fields {
+			if i := rtl.FieldIndex(config.fields, field); i >= 0 {
+				f.indexes = append(f.indexes, i)
+			}
+		}
+		if len(f.indexes) == 0 {
+			return fmt.Errorf("field list has no %s to filter on", strings.Join(fields, " or "))
+		}
+		config.filters = append(config.filters, f)
+		return nil
+	}
+
+	if !config.start.IsZero() || !config.end.IsZero() {
+		if err := add(FilterTime, config.inTimeRange, "timestamp"); err != nil {
+			return err
+		}
+	}
+
+	if len(config.hosts) > 0 {
+		// The Host header is the customer's name for the site; cs-host is the distribution domain
+		if err := add(FilterHost, config.matchHost, "x-host-header", "cs-host"); err != nil {
+			return err
+		}
+	}
+
+	if config.minStatus > 0 || config.maxStatus > 0 {
+		if err := add(FilterStatus, config.inStatusRange, "sc-status"); err != nil {
+			return err
+		}
+	}
+
+	if len(config.edgeLocations) > 0 {
+		if err := add(FilterEdgeLocation, config.matchEdgeLocation, "x-edge-location"); err != nil {
+			return err
+		}
+	}
+
+	if len(config.clientCIDRs) > 0 {
+		nets := make([]*net.IPNet, 0, len(config.clientCIDRs))
+		for _, cidr := range config.clientCIDRs {
+			n, err := parseCIDR(cidr)
+			if err != nil {
+				return err
+			}
+			nets = append(nets, n)
+		}
+		match := func(value string) bool {
+			ip := net.ParseIP(value)
+			if ip == nil {
+				return false
+			}
+			for _, n := range nets {
+				if n.Contains(ip) {
+					return true
+				}
+			}
+			return false
+		}
+		if err := add(FilterClientCIDR, match, "c-ip"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// match runs every filter over the line, counting each one it fails in stats,
+// and reports whether it passed them all.
+func (config *Config) match(parts []string, stats *Stats) bool {
+	ok := true
+	for _, f := range config.filters {
+		if f.passes(parts) {
+			continue
+		}
+		if stats.Filtered == nil {
+			stats.Filtered = map[string]int{}
+		}
+		stats.Filtered[f.name]++
+		ok = false
+	}
+	return ok
+}
+
+// inTimeRange reports whether a timestamp is within the configured range.
+func (config *Config) inTimeRange(value string) bool {
+	ts, err := parseTimestamp(value)
+	if err != nil {
+		return false
+	}
+	if !config.start.IsZero() && ts.Before(config.start) {
+		return false
+	}
+	if !config.end.IsZero() && !ts.Before(config.end) {
+		return false
+	}
+	return true
+}
+
+// matchHost reports whether a host matches one of the host patterns, case-insensitively.
+func (config *Config) matchHost(value string) bool {
+	value = strings.ToLower(value)
+	for _, pattern := range config.hosts {
+		if ok, _ := path.Match(strings.ToLower(pattern), value); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// inStatusRange reports whether a status code is within the configured range.
+func (config *Config) inStatusRange(value string) bool {
+	status, err := strconv.Atoi(value)
+	if err != nil {
+		return false
+	}
+	if config.minStatus > 0 && status < config.minStatus {
+		return false
+	}
+	if config.maxStatus > 0 && status > config.maxStatus {
+		return false
+	}
+	return true
+}
+
+// matchEdgeLocation reports whether an edge location starts with one of the
+// configured locations, so "IAD" matches every IAD edge and "IAD89-C1" just the one.
+func (config *Config) matchEdgeLocation(value string) bool {
+	value = strings.ToUpper(value)
+	for _, location := range config.edgeLocations {
+		if strings.HasPrefix(value, strings.ToUpper(location)) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseCIDR parses a CIDR block, taking a bare address as a single host.
+func parseCIDR(cidr string) (*net.IPNet, error) {
+	if !strings.Contains(cidr, "/") {
+		ip := net.ParseIP(cidr)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid client address: %s", cidr)
+		}
+		bits := 128
+		if ip.To4() != nil {
+			ip, bits = ip.To4(), 32
+		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+	}
+	_, n, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid client CIDR: %w", err)
+	}
+	return n, nil
+}
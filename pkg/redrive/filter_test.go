@@ -0,0 +1,225 @@
+package redrive
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rmrfslashbin/aws-cf-rtl/pkg/rtl"
+)
+
+// logLine returns a line of rtl.DefaultFields with the values given, "-" elsewhere.
+func logLine(values map[string]string) string {
+	parts := make([]string, len(rtl.DefaultFields))
+	for i, field := range rtl.DefaultFields {
+		parts[i] = "-"
+		if value, ok := values[field]; ok {
+			parts[i] = value
+		}
+	}
+	return strings.Join(parts, "\t")
+}
+
+// writeLines writes lines to a log file and returns its path.
+func writeLines(t *testing.T, lines ...string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "rtl-1-2022-11-15-00-00-00-0000.tsv")
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestInStatusRange(t *testing.T) {
+	tests := []struct {
+		min, max int
+		value    string
+		in       bool
+	}{
+		{200, 299, "200", true},
+		{200, 299, "299", true},
+		{200, 299, "300", false},
+		{200, 299, "199", false},
+		{500, 0, "503", true},
+		{500, 0, "404", false},
+		{0, 399, "301", true},
+		{0, 399, "400", false},
+		{200, 299, "-", false},
+		{200, 299, "", false},
+	}
+	for _, tt := range tests {
+		config := &Config{minStatus: tt.min, maxStatus: tt.max}
+		if in := config.inStatusRange(tt.value); in != tt.in {
+			t.Errorf("status %q in %d-%d = %v; want %v", tt.value, tt.min, tt.max, in, tt.in)
+		}
+	}
+}
+
+func TestInTimeRange(t *testing.T) {
+	start := time.Date(2022, 11, 15, 12, 0, 0, 0, time.UTC)
+	end := start.Add(time.Hour)
+	tests := []struct {
+		start, end time.Time
+		value      string
+		in         bool
+	}{
+		{start, end, "1668513600.000", true},  // 12:00:00, start is inclusive
+		{start, end, "1668513599.999", false}, // 11:59:59.999
+		{start, end, "1668517199.999", true},  // 12:59:59.999
+		{start, end, "1668517200.000", false}, // 13:00:00, end is exclusive
+		{start, time.Time{}, "1700000000.000", true},
+		{time.Time{}, end, "1600000000.000", true},
+		{start, end, "-", false},
+		{start, end, "yesterday", false},
+	}
+	for _, tt := range tests {
+		config := &Config{start: tt.start, end: tt.end}
+		if in := config.inTimeRange(tt.value); in != tt.in {
+			t.Errorf("timestamp %s in %s to %s = %v; want %v", tt.value, tt.start, tt.end, in, tt.in)
+		}
+	}
+}
+
+func TestMatchFields(t *testing.T) {
+	config := &Config{
+		hosts:         []string{"*.example.com", "example.org"},
+		edgeLocations: []string{"iad", "LHR62-C1"},
+	}
+	hosts := map[string]bool{"www.example.com": true, "WWW.EXAMPLE.COM": true, "example.com": false, "example.org": true, "www.example.org": false}
+	for host, want := range hosts {
+		if got := config.matchHost(host); got != want {
+			t.Errorf("matchHost(%q) = %v; want %v", host, got, want)
+		}
+	}
+	locations := map[string]bool{"IAD89-P2": true, "iad12-c3": true, "LHR62-C1": true, "LHR62-C2": false, "SFO5-C1": false}
+	for location, want := range locations {
+		if got := config.matchEdgeLocation(location); got != want {
+			t.Errorf("matchEdgeLocation(%q) = %v; want %v", location, got, want)
+		}
+	}
+
+	for _, cidr := range []string{"10.0.0.0/33", "not-an-ip", "10.0.0"} {
+		if _, err := parseCIDR(cidr); err == nil {
+			t.Errorf("parseCIDR(%q) succeeded", cidr)
+		}
+	}
+	if n, err := parseCIDR("2001:db8::1"); err != nil || n.String() != "2001:db8::1/128" {
+		t.Errorf("parseCIDR of a bare IPv6 address = %v, %v; want a /128", n, err)
+	}
+}
+
+func TestBuildFiltersMissingField(t *testing.T) {
+	fields := []string{"timestamp", "c-ip", "x-edge-request-id"}
+	for name, opt := range map[string]Option{
+		FilterStatus:       SetStatusRange(500, 599),
+		FilterHost:         SetHosts("www.example.com"),
+		FilterEdgeLocation: SetEdgeLocations("IAD"),
+	} {
+		if _, err := New(SetClient(&stubKinesis{}), SetS3Client(nil), SetFields(fields), opt); err == nil {
+			t.Errorf("%s filter without its field was accepted", name)
+		}
+	}
+	if _, err := New(SetClient(&stubKinesis{}), SetS3Client(nil), SetClientCIDRs("10.0.0.0/33")); err == nil {
+		t.Error("an invalid client CIDR was accepted")
+	}
+}
+
+func TestRunDryRunFiltered(t *testing.T) {
+	line := func(ts, host, status, edge, ip string) string {
+		return logLine(map[string]string{
+			"timestamp":         ts,
+			"x-host-header":     host,
+			"sc-status":         status,
+			"x-edge-location":   edge,
+			"c-ip":              ip,
+			"x-edge-request-id": ts + host + status + edge + ip,
+		})
+	}
+	const in = "1668513600.000"
+	path := writeLines(t,
+		line(in, "www.example.com", "500", "IAD89-P2", "10.1.2.3"),
+		line(in, "www.example.com", "503", "IAD12-C1", "10.9.9.9"),
+		line("1668510000.000", "www.example.com", "500", "IAD89-P2", "10.1.2.3"),  // time
+		line(in, "www.other.com", "500", "IAD89-P2", "10.1.2.3"),                  // host
+		line(in, "www.example.com", "200", "IAD89-P2", "10.1.2.3"),                // status
+		line(in, "www.example.com", "500", "LHR62-C1", "10.1.2.3"),                // edge
+		line(in, "www.example.com", "500", "IAD89-P2", "192.168.0.1"),             // client
+		line("1668510000.000", "www.other.com", "404", "LHR62-C1", "192.168.0.1"), // all five
+		"too\tfew\tfields",
+	)
+
+	client := &stubKinesis{}
+	start := time.Date(2022, 11, 15, 12, 0, 0, 0, time.UTC)
+	rejects := filepath.Join(t.TempDir(), "rejects.tsv")
+	r := newRedrive(t, client,
+		SetDryRun(true),
+		SetRejectFile(rejects),
+		SetTimeRange(start, start.Add(time.Hour)),
+		SetHosts("*.example.com"),
+		SetStatusRange(500, 599),
+		SetEdgeLocations("IAD"),
+		SetClientCIDRs("10.0.0.0/8"),
+	)
+	stats, err := run(t, r, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Lines != 9 || stats.Matched != 2 || stats.Skipped != 6 || stats.Invalid != 1 || stats.Records != 0 {
+		t.Errorf("lines %d, matched %d, skipped %d, invalid %d, records %d; want 9, 2, 6, 1 and 0",
+			stats.Lines, stats.Matched, stats.Skipped, stats.Invalid, stats.Records)
+	}
+	want := map[string]int{FilterTime: 2, FilterHost: 2, FilterStatus: 2, FilterEdgeLocation: 2, FilterClientCIDR: 2}
+	if len(stats.Filtered) != len(want) {
+		t.Errorf("filtered %v; want %v", stats.Filtered, want)
+	}
+	for name, n := range want {
+		if stats.Filtered[name] != n {
+			t.Errorf("filtered by %s %d; want %d", name, stats.Filtered[name], n)
+		}
+	}
+	if client.calls != 0 {
+		t.Errorf("dry run made %d PutRecords calls", client.calls)
+	}
+	if _, err := os.Stat(rejects); !os.IsNotExist(err) {
+		t.Errorf("dry run wrote a reject file: %v", err)
+	}
+}
+
+func TestRunRejectFile(t *testing.T) {
+	good := logLine(map[string]string{"x-edge-request-id": "request-1"})
+	short := "1668513600.000\t10.1.2.3\t200"
+	long := good + "\textra"
+	path := writeLines(t, short, good, long, "", good)
+	rejects := filepath.Join(t.TempDir(), "rejects.tsv")
+
+	client := &stubKinesis{}
+	stats, err := run(t, newRedrive(t, client, SetRejectFile(rejects)), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Invalid != 2 || stats.Records != 2 || stats.Lines != 4 {
+		t.Errorf("invalid %d, records %d, lines %d; want 2, 2 and 4", stats.Invalid, stats.Records, stats.Lines)
+	}
+	data, err := os.ReadFile(rejects)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := short + "\n" + long + "\n"; string(data) != want {
+		t.Errorf("reject file %q; want %q", data, want)
+	}
+
+	// A later run appends to it
+	if _, err := run(t, newRedrive(t, client, SetRejectFile(rejects)), path); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(rejects); strings.Count(string(data), "\n") != 4 {
+		t.Errorf("reject file after a second run %q; want both runs' rejects", data)
+	}
+
+	// Without a reject file nothing is written
+	if stats, err := run(t, newRedrive(t, client), path); err != nil || stats.Invalid != 2 {
+		t.Errorf("run without a reject file = %+v, %v", stats, err)
+	}
+}
@@ -0,0 +1,102 @@
+package redrive
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Adaptive pacing bounds. The pause doubles on every throttled PutRecords
+// and decays by a tenth on every clean one until it drops below minPause.
+const (
+	minPause = 50 * time.Millisecond
+	maxPause = 5 * time.Second
+)
+
+// limiter paces work to a rate shared by every worker. Each reservation pushes
+// the next free slot out by n/rate; there is no burst beyond the first request.
+type limiter struct {
+	mu   sync.Mutex
+	rate float64
+	next time.Time
+}
+
+// newLimiter returns a limiter of rate units per second, or nil for no limit.
+func newLimiter(rate float64) *limiter {
+	if rate <= 0 {
+		return nil
+	}
+	return &limiter{rate: rate}
+}
+
+// reserve books n units at now and returns how long to wait before using them.
+func (l *limiter) reserve(now time.Time, n float64) time.Duration {
+	if l == nil || n <= 0 {
+		return 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	wait := l.next.Sub(now)
+	l.next = l.next.Add(time.Duration(n / l.rate * float64(time.Second)))
+	return wait
+}
+
+// pacer is the adaptive pause taken before every PutRecords, shared by every
+// worker so one throttled worker slows them all down.
+type pacer struct {
+	mu    sync.Mutex
+	pause time.Duration
+}
+
+// current returns the pause to take before the next request.
+func (p *pacer) current() time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.pause
+}
+
+// throttled backs off after Kinesis throttled a request.
+func (p *pacer) throttled() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.pause *= 2
+	if p.pause < minPause {
+		p.pause = minPause
+	}
+	if p.pause > maxPause {
+		p.pause = maxPause
+	}
+}
+
+// ok speeds back up after a request went through unthrottled.
+func (p *pacer) ok() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.pause -= p.pause / 10
+	if p.pause < minPause {
+		p.pause = 0
+	}
+}
+
+// wait sleeps for at least delay, the adaptive pause and until the rate limits
+// allow records and bytes, whichever is longest.
+func (config *Config) wait(ctx context.Context, delay time.Duration, records int, bytes int64) error {
+	d := config.pacer.current()
+	if delay > d {
+		d = delay
+	}
+	now := config.now()
+	if w := config.recordLimit.reserve(now, float64(records)); w > d {
+		d = w
+	}
+	if w := config.byteLimit.reserve(now, float64(bytes)); w > d {
+		d = w
+	}
+	if d <= 0 {
+		return nil
+	}
+	return config.sleep(ctx, d)
+}
@@ -0,0 +1,341 @@
+package redrive
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kinesis"
+	"github.com/aws/aws-sdk-go-v2/service/kinesis/types"
+)
+
+// fakeClock is a clock that only moves when slept on, recording every sleep.
+type fakeClock struct {
+	mu     sync.Mutex
+	t      time.Time
+	sleeps []time.Duration
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{t: time.Unix(1668470400, 0)}
+}
+
+func (c *fakeClock) now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.t
+}
+
+func (c *fakeClock) sleep(ctx context.Context, d time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sleeps = append(c.sleeps, d)
+	c.t = c.t.Add(d)
+	return ctx.Err()
+}
+
+// useClock runs the redrive on the fake clock.
+func useClock(r *Config, clock *fakeClock) {
+	r.now = clock.now
+	r.sleep = clock.sleep
+}
+
+// stubKinesis answers PutRecords with fn, accepting every record when fn is nil.
+type stubKinesis struct {
+	mu    sync.Mutex
+	calls int
+	fn    func(call int, params *kinesis.PutRecordsInput) (*kinesis.PutRecordsOutput, error)
+}
+
+func (s *stubKinesis) PutRecords(ctx context.Context, params *kinesis.PutRecordsInput, optFns ...func(*kinesis.Options)) (*kinesis.PutRecordsOutput, error) {
+	s.mu.Lock()
+	s.calls++
+	call := s.calls
+	s.mu.Unlock()
+	if s.fn != nil {
+		return s.fn(call, params)
+	}
+	return accept(params, 0), nil
+}
+
+// accept answers params, rejecting the first rejected records as throttled.
+func accept(params *kinesis.PutRecordsInput, rejected int) *kinesis.PutRecordsOutput {
+	out := &kinesis.PutRecordsOutput{}
+	for i := range params.Records {
+		if i < rejected {
+			out.Records = append(out.Records, types.PutRecordsResultEntry{
+				ErrorCode:    aws.String("ProvisionedThroughputExceededException"),
+				ErrorMessage: aws.String("Rate exceeded for shard"),
+			})
+			out.FailedRecordCount = aws.Int32(int32(rejected))
+			continue
+		}
+		out.Records = append(out.Records, types.PutRecordsResultEntry{
+			SequenceNumber: aws.String(fmt.Sprint(i)),
+			ShardId:        aws.String("shardId-000000000000"),
+		})
+	}
+	return out
+}
+
+func TestLimiter(t *testing.T) {
+	var none *limiter
+	if d := none.reserve(time.Now(), 1000); d != 0 {
+		t.Errorf("nil limiter waits %s", d)
+	}
+	if newLimiter(0) != nil {
+		t.Error("a zero rate built a limiter")
+	}
+
+	t0 := time.Unix(1668470400, 0)
+	l := newLimiter(100)
+	steps := []struct {
+		at   time.Duration
+		n    float64
+		wait time.Duration
+	}{
+		{0, 50, 0},
+		{0, 50, 500 * time.Millisecond},
+		{200 * time.Millisecond, 100, 800 * time.Millisecond},
+		{2 * time.Second, 0, 0},
+		// Idle time does not build up a burst
+		{5 * time.Second, 100, 0},
+		{5 * time.Second, 1, time.Second},
+	}
+	for i, step := range steps {
+		if wait := l.reserve(t0.Add(step.at), step.n); wait != step.wait {
+			t.Errorf("step %d: reserve(%s, %v) waits %s; want %s", i+1, step.at, step.n, wait, step.wait)
+		}
+	}
+}
+
+func TestPacer(t *testing.T) {
+	p := &pacer{}
+	want := []time.Duration{50, 100, 200, 400, 800, 1600, 3200, 5000, 5000}
+	for i, ms := range want {
+		p.throttled()
+		if got := p.current(); got != ms*time.Millisecond {
+			t.Errorf("throttle %d: pause %s; want %dms", i+1, got, ms)
+		}
+	}
+
+	// A tenth off per clean request, then nothing once under minPause
+	p.ok()
+	if got := p.current(); got != 4500*time.Millisecond {
+		t.Errorf("pause %s after one clean request; want 4.5s", got)
+	}
+	oks := 1
+	for last := p.current(); p.current() > 0; oks++ {
+		p.ok()
+		if got := p.current(); got >= last {
+			t.Fatalf("pause %s did not drop from %s", got, last)
+		}
+		last = p.current()
+	}
+	// 5s * 0.9^n drops under 50ms after 44 clean requests
+	if oks != 44 {
+		t.Errorf("recovered after %d clean requests; want 44", oks)
+	}
+}
+
+func TestFlushThrottleThreshold(t *testing.T) {
+	tests := []struct {
+		rejected  int
+		throttled int
+		pause     time.Duration
+	}{
+		{0, 0, 0},
+		{9, 0, 0},
+		{10, 1, minPause},
+		{100, 1, minPause},
+	}
+	for _, tt := range tests {
+		client := &stubKinesis{fn: func(call int, params *kinesis.PutRecordsInput) (*kinesis.PutRecordsOutput, error) {
+			if call == 1 {
+				return accept(params, tt.rejected), nil
+			}
+			return accept(params, 0), nil
+		}}
+		r := newRedrive(t, client)
+		clock := newFakeClock()
+		useClock(r, clock)
+
+		b := newBatch()
+		for i := 0; i < 100; i++ {
+			b.add([]byte(fmt.Sprintf("line %d\n", i)), fmt.Sprintf("request-%d", i), i+1)
+		}
+		stats := &Stats{}
+		_, failed, err := r.flush(context.Background(), b, stats)
+		if err != nil || len(failed) != 0 {
+			t.Fatalf("%d rejected: flush = %v, %v", tt.rejected, failed, err)
+		}
+		if stats.Throttled != tt.throttled || stats.Records != 100 {
+			t.Errorf("%d of 100 rejected: throttled %d, records %d; want %d and 100", tt.rejected, stats.Throttled, stats.Records, tt.throttled)
+		}
+		// The clean retry takes a tenth off the pause
+		wantPause := tt.pause - tt.pause/10
+		if wantPause < minPause {
+			wantPause = 0
+		}
+		if got := r.pacer.current(); got != wantPause {
+			t.Errorf("%d of 100 rejected: pause %s after the retry; want %s", tt.rejected, got, wantPause)
+		}
+		// The retry waits the backoff or the pause, whichever is longer
+		if tt.rejected > 0 {
+			wantSleep := backoff(1)
+			if tt.pause > wantSleep {
+				wantSleep = tt.pause
+			}
+			if len(clock.sleeps) != 1 || clock.sleeps[0] != wantSleep {
+				t.Errorf("%d of 100 rejected: slept %v; want [%s]", tt.rejected, clock.sleeps, wantSleep)
+			}
+		}
+	}
+}
+
+func TestFlushThrottleError(t *testing.T) {
+	// A throttled call is retried after the longer of the backoff and the pause
+	throttle := func(calls int) *stubKinesis {
+		return &stubKinesis{fn: func(call int, params *kinesis.PutRecordsInput) (*kinesis.PutRecordsOutput, error) {
+			if call <= calls {
+				return nil, &types.ProvisionedThroughputExceededException{Message: aws.String("Rate exceeded")}
+			}
+			return accept(params, 0), nil
+		}}
+	}
+	r := newRedrive(t, throttle(3))
+	clock := newFakeClock()
+	useClock(r, clock)
+
+	b := newBatch()
+	b.add([]byte("line\n"), "request-1", 1)
+	stats := &Stats{}
+	if _, failed, err := r.flush(context.Background(), b, stats); err != nil || len(failed) != 0 {
+		t.Fatalf("flush = %v, %v", failed, err)
+	}
+	if stats.Throttled != 3 || stats.Retries != 3 || stats.Records != 1 {
+		t.Errorf("throttled %d, retries %d, records %d; want 3, 3 and 1", stats.Throttled, stats.Retries, stats.Records)
+	}
+	want := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond}
+	if fmt.Sprint(clock.sleeps) != fmt.Sprint(want) {
+		t.Errorf("slept %v; want %v", clock.sleeps, want)
+	}
+
+	// Throttled calls use up the retries like rejected records
+	r = newRedrive(t, throttle(3), SetMaxRetries(1))
+	useClock(r, newFakeClock())
+	b.add([]byte("line\n"), "request-1", 1)
+	stats = &Stats{}
+	if _, failed, err := r.flush(context.Background(), b, stats); err != nil || len(failed) != 1 || failed[0] != 1 {
+		t.Errorf("flush = %v, %v; want line 1 failed", failed, err)
+	}
+	if stats.Failed != 1 || stats.Throttled != 2 {
+		t.Errorf("failed %d, throttled %d; want 1 and 2", stats.Failed, stats.Throttled)
+	}
+
+	other := &stubKinesis{fn: func(int, *kinesis.PutRecordsInput) (*kinesis.PutRecordsOutput, error) {
+		return nil, errors.New("access denied")
+	}}
+	r = newRedrive(t, other)
+	useClock(r, newFakeClock())
+	b.add([]byte("line\n"), "request-1", 1)
+	if _, _, err := r.flush(context.Background(), b, &Stats{}); err == nil || other.calls != 1 {
+		t.Errorf("flush returned %v after %d calls; want the error after one", err, other.calls)
+	}
+}
+
+func TestRunRateLimit(t *testing.T) {
+	// Three full batches at 500 records a second: the second and third wait a second each
+	path, lines := writeLog(t, 1500)
+	client := &stubKinesis{}
+	r := newRedrive(t, client, SetRateLimit(500, 0), SetConcurrency(1))
+	clock := newFakeClock()
+	useClock(r, clock)
+	stats, err := run(t, r, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Records != len(lines) || client.calls != 3 {
+		t.Errorf("records %d in %d calls; want %d in 3", stats.Records, client.calls, len(lines))
+	}
+	if want := []time.Duration{time.Second, time.Second}; fmt.Sprint(clock.sleeps) != fmt.Sprint(want) {
+		t.Errorf("slept %v; want %v", clock.sleeps, want)
+	}
+}
+
+func TestRunConcurrency(t *testing.T) {
+	const workers, files = 3, 7
+	dir := t.TempDir()
+	for i := 0; i < files; i++ {
+		path, _ := writeLog(t, 10)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		name := fmt.Sprintf("rtl-1-2022-11-15-00-00-0%d-0000.tsv", i)
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Hold the first calls until every worker has one in flight
+	var mu sync.Mutex
+	inFlight, most := 0, 0
+	ready := make(chan struct{})
+	var once sync.Once
+	client := &stubKinesis{fn: func(call int, params *kinesis.PutRecordsInput) (*kinesis.PutRecordsOutput, error) {
+		mu.Lock()
+		inFlight++
+		if inFlight > most {
+			most = inFlight
+		}
+		if inFlight == workers {
+			once.Do(func() { close(ready) })
+		}
+		mu.Unlock()
+		defer func() {
+			mu.Lock()
+			inFlight--
+			mu.Unlock()
+		}()
+		select {
+		case <-ready:
+		case <-time.After(5 * time.Second):
+			return nil, errors.New("workers did not run at once")
+		}
+		return accept(params, 0), nil
+	}}
+	r := newRedrive(t, client, SetConcurrency(workers))
+	useClock(r, newFakeClock())
+	stats, err := run(t, r, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Files != files || stats.Records != files*10 || client.calls != files {
+		t.Errorf("files %d, records %d, calls %d; want %d, %d and %d", stats.Files, stats.Records, client.calls, files, files*10, files)
+	}
+	if most != workers {
+		t.Errorf("%d calls in flight at most; want %d", most, workers)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	want := []string{"100ms", "200ms", "400ms", "800ms", "1.6s", "3.2s", "5s", "5s"}
+	got := []string{}
+	for attempt := 1; attempt <= len(want); attempt++ {
+		got = append(got, backoff(attempt).String())
+	}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("backoff %v; want %v", got, want)
+	}
+	if d := backoff(100); d != maxBackoff {
+		t.Errorf("backoff(100) = %s; want %s", d, maxBackoff)
+	}
+}
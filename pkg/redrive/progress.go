@@ -0,0 +1,84 @@
+package redrive
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// tally is the running Stats of a Run, shared by its workers.
+type tally struct {
+	mu      sync.Mutex
+	stats   Stats
+	started time.Time
+}
+
+// add folds a worker's counts into the tally and zeroes them.
+func (t *tally) add(delta *Stats) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.stats.Files += delta.Files
+	t.stats.FilesResumed += delta.FilesResumed
+	t.stats.FilesDone += delta.FilesDone
+	t.stats.Lines += delta.Lines
+	t.stats.Matched += delta.Matched
+	t.stats.Records += delta.Records
+	t.stats.Bytes += delta.Bytes
+	t.stats.Skipped += delta.Skipped
+	t.stats.Invalid += delta.Invalid
+	t.stats.Failed += delta.Failed
+	t.stats.Retries += delta.Retries
+	t.stats.Throttled += delta.Throttled
+	for name, n := range delta.Filtered {
+		if t.stats.Filtered == nil {
+			t.stats.Filtered = map[string]int{}
+		}
+		t.stats.Filtered[name] += n
+	}
+	*delta = Stats{}
+}
+
+// snapshot returns a copy of the counts so far.
+func (t *tally) snapshot() Stats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	stats := t.stats
+	if t.stats.Filtered != nil {
+		stats.Filtered = make(map[string]int, len(t.stats.Filtered))
+		for name, n := range t.stats.Filtered {
+			stats.Filtered[name] = n
+		}
+	}
+	stats.Elapsed = time.Since(t.started)
+	return stats
+}
+
+// report calls the progress callback with a snapshot every interval until ctx is done.
+func (config *Config) report(ctx context.Context, t *tally) {
+	ticker := time.NewTicker(config.progressInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			config.progress(t.snapshot())
+		}
+	}
+}
+
+// RecordsPerSecond is the average send rate.
+func (stats Stats) RecordsPerSecond() float64 {
+	if stats.Elapsed <= 0 {
+		return 0
+	}
+	return float64(stats.Records) / stats.Elapsed.Seconds()
+}
+
+// MBPerSecond is the average send rate in megabytes (10^6 bytes).
+func (stats Stats) MBPerSecond() float64 {
+	if stats.Elapsed <= 0 {
+		return 0
+	}
+	return float64(stats.Bytes) / 1e6 / stats.Elapsed.Seconds()
+}
@@ -0,0 +1,200 @@
+package redrive
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kinesis"
+	"github.com/aws/aws-sdk-go-v2/service/kinesis/types"
+	"github.com/sirupsen/logrus"
+)
+
+// PutRecords limits.
+const (
+	maxBatchRecords = 500
+	maxBatchBytes   = 5 << 20
+)
+
+// throttledShare is the inverse of the share of a batch that must be throttled
+// before every worker backs off.
+const throttledShare = 10
+
+// Retry backoff bounds.
+const (
+	minBackoff = 100 * time.Millisecond
+	maxBackoff = 5 * time.Second
+)
+
+// batch is a PutRecords request being filled, with the line number of each record.
+type batch struct {
+	entries []types.PutRecordsRequestEntry
+	lines   []int
+	bytes   int
+}
+
+func newBatch() *batch {
+	return &batch{
+		entries: make([]types.PutRecordsRequestEntry, 0, maxBatchRecords),
+		lines:   make([]int, 0, maxBatchRecords),
+	}
+}
+
+// fits reports whether a record can be added without exceeding the PutRecords limits.
+func (b *batch) fits(data []byte, key string) bool {
+	return len(b.entries) < maxBatchRecords && b.bytes+len(data)+len(key) <= maxBatchBytes
+}
+
+// add appends the record read from line lineNo, partitioned on key, the edge request ID.
+func (b *batch) add(data []byte, key string, lineNo int) {
+	if key == "" || key == "-" {
+		// Spread lines without a request ID rather than piling them on one shard
+		h := fnv.New64a()
+		h.Write(data)
+		key = strconv.FormatUint(h.Sum64(), 36)
+	}
+	b.entries = append(b.entries, types.PutRecordsRequestEntry{
+		Data:         data,
+		PartitionKey: aws.String(key),
+	})
+	b.lines = append(b.lines, lineNo)
+	b.bytes += len(data) + len(key)
+}
+
+// reset empties the batch for reuse.
+func (b *batch) reset() {
+	b.entries = b.entries[:0]
+	b.lines = b.lines[:0]
+	b.bytes = 0
+}
+
+// flush sends the batch, retrying rejected records with backoff, and empties it.
+// Every request waits on the rate limits and the adaptive pause, which grows while
+// Kinesis throttles. Records still rejected after the last retry are counted as
+// failed. The sequence number of the last record accepted is returned, with the
+// line numbers of the records that failed, in order.
+func (config *Config) flush(ctx context.Context, b *batch, stats *Stats) (string, []int, error) {
+	defer b.reset()
+
+	pending := b.entries
+	pendingLines := b.lines
+	sent := 0
+	sequence := ""
+	var sentBytes int64
+	for attempt := 0; len(pending) > 0; attempt++ {
+		var delay time.Duration
+		if attempt > 0 {
+			if attempt > config.maxRetries {
+				break
+			}
+			stats.Retries++
+			delay = backoff(attempt)
+		}
+
+		if err := config.wait(ctx, delay, len(pending), entriesSize(pending)); err != nil {
+			return "", nil, err
+		}
+		out, err := config.kinesis.PutRecords(ctx, &kinesis.PutRecordsInput{
+			StreamName: aws.String(config.stream),
+			Records:    pending,
+		})
+		if err != nil {
+			if isThrottle(err) {
+				stats.Throttled++
+				config.pacer.throttled()
+				continue
+			}
+			return "", nil, err
+		}
+
+		// Keep the rejected entries; results are in request order
+		failed := pending[:0:0]
+		failedLines := pendingLines[:0:0]
+		rejected := 0
+		for i, result := range out.Records {
+			if result.ErrorCode != nil {
+				if aws.ToString(result.ErrorCode) == "ProvisionedThroughputExceededException" {
+					rejected++
+				}
+				failed = append(failed, pending[i])
+				failedLines = append(failedLines, pendingLines[i])
+				continue
+			}
+			sent++
+			sentBytes += int64(len(pending[i].Data))
+			sequence = aws.ToString(result.SequenceNumber)
+		}
+		// A few rejections are a hot shard and are left to the retry backoff;
+		// more than that means the stream as a whole is over its limit
+		throttled := rejected > 0 && rejected*throttledShare >= len(out.Records)
+		if throttled {
+			stats.Throttled++
+			config.pacer.throttled()
+		} else {
+			config.pacer.ok()
+		}
+		if len(failed) > 0 {
+			config.log.WithFields(logrus.Fields{
+				"failed":    len(failed),
+				"attempt":   attempt + 1,
+				"throttled": throttled,
+				"pause":     config.pacer.current(),
+			}).Debug("PutRecords rejected records")
+		}
+		pending = failed
+		pendingLines = failedLines
+	}
+
+	stats.Records += sent
+	stats.Bytes += sentBytes
+	if len(pending) > 0 {
+		stats.Failed += len(pending)
+		config.log.WithFields(logrus.Fields{
+			"failed":     len(pending),
+			"first_line": pendingLines[0],
+		}).Error("records still rejected after retries")
+		return sequence, append([]int(nil), pendingLines...), nil
+	}
+	return sequence, nil, nil
+}
+
+// entriesSize is the size of the entries as counted against the stream write limit.
+func entriesSize(entries []types.PutRecordsRequestEntry) int64 {
+	var size int64
+	for _, entry := range entries {
+		size += int64(len(entry.Data) + len(aws.ToString(entry.PartitionKey)))
+	}
+	return size
+}
+
+// isThrottle reports whether err is a Kinesis throttling error worth retrying.
+func isThrottle(err error) bool {
+	var throughput *types.ProvisionedThroughputExceededException
+	var kms *types.KMSThrottlingException
+	var limit *types.LimitExceededException
+	return errors.As(err, &throughput) || errors.As(err, &kms) || errors.As(err, &limit)
+}
+
+// backoff returns the exponential delay before retry attempt.
+func backoff(attempt int) time.Duration {
+	d := minBackoff << (attempt - 1)
+	if d > maxBackoff || d <= 0 {
+		return maxBackoff
+	}
+	return d
+}
+
+// sleep waits for d or until ctx is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
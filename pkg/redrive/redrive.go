@@ -0,0 +1,586 @@
+// Package redrive replays Cloudfront real-time log lines, such as the Firehose
+// source record backups, into a Kinesis data stream.
+package redrive
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/kinesis"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/rmrfslashbin/aws-cf-rtl/pkg/rtl"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	// ErrStreamNotSet is returned by Run when no stream name is set.
+	ErrStreamNotSet = errors.New("stream name is not set")
+
+	// ErrNoRequestID is returned by New when the field list has no x-edge-request-id to partition on.
+	ErrNoRequestID = errors.New("field list has no x-edge-request-id")
+
+	// ErrRecordsFailed is returned by Run when records were still rejected after
+	// every retry. The checkpoint lists their lines, so a resumed redrive sends
+	// just those again.
+	ErrRecordsFailed = errors.New("records could not be sent")
+)
+
+// DefaultMaxRetries is how often records rejected by PutRecords are retried.
+const DefaultMaxRetries = 8
+
+// DefaultConcurrency is how many files are replayed at once.
+const DefaultConcurrency = 4
+
+// maxLineSize is the longest log line read; Kinesis records are at most 1 MiB.
+const maxLineSize = 1 << 20
+
+// KinesisAPI is the subset of the Kinesis client used to replay records.
+type KinesisAPI interface {
+	PutRecords(ctx context.Context, params *kinesis.PutRecordsInput, optFns ...func(*kinesis.Options)) (*kinesis.PutRecordsOutput, error)
+}
+
+var _ KinesisAPI = (*kinesis.Client)(nil)
+
+type Option func(config *Config)
+
+// Config is a redrive of log lines into a Kinesis stream.
+type Config struct {
+	region     string
+	profile    string
+	log        *logrus.Logger
+	stream     string
+	fields     []string
+	start      time.Time
+	end        time.Time
+	maxRetries int
+	dryRun     bool
+	rejects    *rejectFile
+	endpoint   string
+	kinesis    KinesisAPI
+	s3         S3API
+
+	checkpointFile string
+	resume         bool
+	checkpoint     *Checkpoint
+
+	concurrency int
+	recordLimit *limiter
+	byteLimit   *limiter
+	pacer       *pacer
+
+	// now and sleep are the clock the rate limits and pauses run on
+	now   func() time.Time
+	sleep func(ctx context.Context, d time.Duration) error
+
+	progressInterval time.Duration
+	progress         func(Stats)
+
+	hosts         []string
+	minStatus     int
+	maxStatus     int
+	edgeLocations []string
+	clientCIDRs   []string
+	filters       []filter
+
+	requestIDIndex int
+}
+
+// Stats counts the work done by Run.
+type Stats struct {
+	Files        int           `json:"files"`
+	FilesResumed int           `json:"files_resumed"`
+	FilesDone    int           `json:"files_done"`
+	Lines        int           `json:"lines"`
+	Matched      int           `json:"matched"`
+	Records      int           `json:"records"`
+	Bytes        int64         `json:"bytes"`
+	Skipped      int           `json:"skipped"`
+	Invalid      int           `json:"invalid"`
+	Failed       int           `json:"failed"`
+	Retries      int           `json:"retries"`
+	Throttled    int           `json:"throttled"`
+	Elapsed      time.Duration `json:"elapsed"`
+
+	// Filtered counts the lines each filter turned away; a line failing
+	// several filters is counted against each. Skipped is the lines failing any.
+	Filtered map[string]int `json:"filtered,omitempty"`
+}
+
+// New returns a redrive. Clients not injected with SetClient or SetS3Client
+// are built from the default AWS configuration.
+func New(opts ...Option) (*Config, error) {
+	cfg := &Config{
+		fields:      rtl.DefaultFields,
+		maxRetries:  DefaultMaxRetries,
+		concurrency: DefaultConcurrency,
+		pacer:       &pacer{},
+		now:         time.Now,
+		sleep:       sleep,
+	}
+
+	// apply the list of options to Config
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	if cfg.log == nil {
+		cfg.log = logrus.New()
+	}
+	if cfg.concurrency < 1 {
+		cfg.concurrency = 1
+	}
+
+	cfg.requestIDIndex = rtl.FieldIndex(cfg.fields, "x-edge-request-id")
+	if cfg.requestIDIndex < 0 {
+		return nil, ErrNoRequestID
+	}
+	if err := cfg.buildFilters(); err != nil {
+		return nil, err
+	}
+
+	// A dry run sends nothing, so has no progress to keep
+	if cfg.checkpointFile != "" && !cfg.dryRun {
+		var err error
+		if cfg.checkpoint, err = openCheckpoint(cfg.checkpointFile, cfg.stream, cfg.resume); err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.kinesis != nil && cfg.s3 != nil {
+		return cfg, nil
+	}
+
+	if cfg.region == "" {
+		cfg.region = os.Getenv("AWS_REGION")
+	}
+
+	c, err := config.LoadDefaultConfig(context.TODO(), func(o *config.LoadOptions) error {
+		o.Region = cfg.region
+		if cfg.profile != "" {
+			o.SharedConfigProfile = cfg.profile
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if cfg.kinesis == nil {
+		var optFns []func(*kinesis.Options)
+		if cfg.endpoint != "" {
+			optFns = append(optFns, func(o *kinesis.Options) {
+				o.BaseEndpoint = aws.String(cfg.endpoint)
+			})
+		}
+		cfg.kinesis = kinesis.NewFromConfig(c, optFns...)
+	}
+	if cfg.s3 == nil {
+		cfg.s3 = s3.NewFromConfig(c)
+	}
+	return cfg, nil
+}
+
+func SetProfile(profile string) Option {
+	return func(config *Config) {
+		config.profile = profile
+	}
+}
+
+func SetRegion(region string) Option {
+	return func(config *Config) {
+		config.region = region
+	}
+}
+
+func SetLogger(log *logrus.Logger) Option {
+	return func(config *Config) {
+		config.log = log
+	}
+}
+
+// SetClient injects the Kinesis client.
+func SetClient(client KinesisAPI) Option {
+	return func(config *Config) {
+		config.kinesis = client
+	}
+}
+
+// SetEndpoint sends Kinesis requests to url, such as a kinesistest.Server or
+// another local stand-in, instead of the regional endpoint. It has no effect
+// with SetClient.
+func SetEndpoint(url string) Option {
+	return func(config *Config) {
+		config.endpoint = url
+	}
+}
+
+// SetS3Client injects the S3 client used to read backups from S3.
+func SetS3Client(client S3API) Option {
+	return func(config *Config) {
+		config.s3 = client
+	}
+}
+
+// SetStream sets the name of the Kinesis stream to write to.
+func SetStream(stream string) Option {
+	return func(config *Config) {
+		config.stream = stream
+	}
+}
+
+// SetFields sets the ordered field list of the log lines, defaulting to rtl.DefaultFields.
+func SetFields(fields []string) Option {
+	return func(config *Config) {
+		config.fields = fields
+	}
+}
+
+// SetTimeRange only replays lines logged at or after start and before end.
+// A zero start or end leaves that side open. Files whose names put them
+// outside the range are not read at all.
+func SetTimeRange(start, end time.Time) Option {
+	return func(config *Config) {
+		config.start = start
+		config.end = end
+	}
+}
+
+// SetHosts only replays requests for the hosts, matched against the Host header
+// and the distribution domain. Patterns may use wildcards, e.g. "*.example.com".
+func SetHosts(hosts ...string) Option {
+	return func(config *Config) {
+		config.hosts = hosts
+	}
+}
+
+// SetStatusRange only replays responses with a status code from min to max
+// inclusive. Zero leaves that side open.
+func SetStatusRange(min, max int) Option {
+	return func(config *Config) {
+		config.minStatus = min
+		config.maxStatus = max
+	}
+}
+
+// SetEdgeLocations only replays requests served by the edge locations. A location
+// matches by prefix, so "IAD" is every Ashburn edge and "IAD89-C1" just the one.
+func SetEdgeLocations(locations ...string) Option {
+	return func(config *Config) {
+		config.edgeLocations = locations
+	}
+}
+
+// SetClientCIDRs only replays requests from client addresses in the CIDR blocks.
+// A bare address is a single host. New returns an error for an invalid block.
+func SetClientCIDRs(cidrs ...string) Option {
+	return func(config *Config) {
+		config.clientCIDRs = cidrs
+	}
+}
+
+// SetDryRun reads and filters every line but sends nothing, writes no
+// checkpoint and no reject file, for checking filters with the Stats counts.
+func SetDryRun(dryRun bool) Option {
+	return func(config *Config) {
+		config.dryRun = dryRun
+	}
+}
+
+// SetRejectFile appends lines with the wrong field count to path instead of just
+// logging them. Empty disables it.
+func SetRejectFile(path string) Option {
+	return func(config *Config) {
+		config.rejects = nil
+		if path != "" {
+			config.rejects = &rejectFile{path: path}
+		}
+	}
+}
+
+// SetMaxRetries sets how often records rejected by PutRecords are retried.
+func SetMaxRetries(retries int) Option {
+	return func(config *Config) {
+		config.maxRetries = retries
+	}
+}
+
+// SetCheckpoint writes progress to a checkpoint file after every batch. With resume set,
+// an existing checkpoint is picked up from: finished files are skipped and lines already
+// sent are passed over. Without it New refuses to overwrite an existing checkpoint.
+// A run that sends every record removes the checkpoint, as there is nothing to resume.
+func SetCheckpoint(path string, resume bool) Option {
+	return func(config *Config) {
+		config.checkpointFile = path
+		config.resume = resume
+	}
+}
+
+// SetConcurrency sets how many files are replayed at once, defaulting to DefaultConcurrency.
+// Lines within a file are always sent in order.
+func SetConcurrency(workers int) Option {
+	return func(config *Config) {
+		config.concurrency = workers
+	}
+}
+
+// SetRateLimit caps the records and bytes sent per second across all workers.
+// Zero leaves that limit off.
+func SetRateLimit(recordsPerSecond, bytesPerSecond float64) Option {
+	return func(config *Config) {
+		config.recordLimit = newLimiter(recordsPerSecond)
+		config.byteLimit = newLimiter(bytesPerSecond)
+	}
+}
+
+// SetProgress calls fn with the running Stats every interval while Run works.
+func SetProgress(interval time.Duration, fn func(Stats)) Option {
+	return func(config *Config) {
+		config.progressInterval = interval
+		config.progress = fn
+	}
+}
+
+// NewSource returns the Source for location: an s3://bucket/prefix URL, a local
+// directory or a local file. Files are limited to the configured time range by
+// the Firehose timestamp in their names.
+func (config *Config) NewSource(location string) (Source, error) {
+	if !strings.HasPrefix(location, "s3://") {
+		return NewLocalSource(location, config.start, config.end), nil
+	}
+	bucket, prefix, err := splitS3URL(location)
+	if err != nil {
+		return nil, err
+	}
+	return NewS3Source(config.s3, bucket, prefix, config.start, config.end), nil
+}
+
+// Run replays every line of every file from source that passes the filters,
+// gunzipping compressed files. Files are shared out to the configured number of
+// workers. Lines with the wrong field count are counted as invalid and written to
+// the reject file. The first error stops the run. Records Kinesis still rejects
+// after every retry do not stop it, but Run then returns ErrRecordsFailed.
+func (config *Config) Run(ctx context.Context, source Source) (stats *Stats, err error) {
+	if config.stream == "" && !config.dryRun {
+		return nil, ErrStreamNotSet
+	}
+	if !config.dryRun {
+		defer func() {
+			if cerr := config.rejects.close(); cerr != nil && err == nil {
+				err = cerr
+			}
+			if cerr := config.checkpoint.close(); cerr != nil && err == nil {
+				err = cerr
+			}
+		}()
+	}
+
+	files, err := source.Files(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &tally{started: time.Now()}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	if config.progress != nil && config.progressInterval > 0 {
+		reportCtx, stopReport := context.WithCancel(ctx)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			config.report(reportCtx, t)
+		}()
+		defer func() {
+			stopReport()
+			<-done
+		}()
+	}
+
+	queue := make(chan File)
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+	)
+	for i := 0; i < config.concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for file := range queue {
+				if err := config.runFile(ctx, file, t); err != nil {
+					errOnce.Do(func() {
+						firstErr = fmt.Errorf("%s: %w", file.Name, err)
+						cancel()
+					})
+					return
+				}
+			}
+		}()
+	}
+
+feed:
+	for _, file := range files {
+		select {
+		case queue <- file:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(queue)
+	wg.Wait()
+
+	snapshot := t.snapshot()
+	if firstErr == nil {
+		firstErr = ctx.Err()
+	}
+	if firstErr == nil && snapshot.Failed > 0 {
+		firstErr = fmt.Errorf("%d %w", snapshot.Failed, ErrRecordsFailed)
+	}
+	if firstErr == nil && !config.dryRun {
+		firstErr = config.checkpoint.remove()
+	}
+	return &snapshot, firstErr
+}
+
+// runFile replays the lines of a single file, picking up from its checkpoint.
+// Counts are folded into t after every batch.
+func (config *Config) runFile(ctx context.Context, file File, t *tally) error {
+	stats := &Stats{Files: 1}
+	defer t.add(stats)
+
+	var progress FileCheckpoint
+	if config.checkpoint != nil {
+		progress = config.checkpoint.file(file.Name)
+	}
+	if progress.Done && len(progress.Failed) == 0 {
+		stats.FilesDone++
+		return nil
+	}
+	retry := make(map[int]bool, len(progress.Failed))
+	for _, line := range progress.Failed {
+		retry[line] = true
+	}
+	if progress.Line > 0 {
+		stats.FilesResumed++
+	}
+
+	config.log.WithFields(logrus.Fields{
+		"file":   file.Name,
+		"resume": progress.Line,
+	}).Info("redriving file")
+
+	raw, err := file.Open(ctx)
+	if err != nil {
+		return err
+	}
+	rc, err := rtl.Decompress(raw)
+	if err != nil {
+		raw.Close()
+		return err
+	}
+	defer rc.Close()
+
+	// batchLine is the line number of the last line added to the batch and
+	// failed those Kinesis never accepted in this run. Lines from the checkpoint
+	// still to be sent again stay in it until they are reached.
+	b := newBatch()
+	lineNo, batchLine := 0, 0
+	var failed []int
+	pending := func() []int {
+		lines := append([]int(nil), failed...)
+		for _, line := range progress.Failed {
+			if line > batchLine {
+				lines = append(lines, line)
+			}
+		}
+		return lines
+	}
+	flush := func() error {
+		sequence, rejected, err := config.flush(ctx, b, stats)
+		t.add(stats)
+		failed = append(failed, rejected...)
+		if err != nil || config.checkpoint == nil || batchLine == 0 {
+			return err
+		}
+		line := batchLine
+		if progress.Line > line {
+			line = progress.Line
+		}
+		return config.checkpoint.update(file.Name, line, sequence, pending(), false)
+	}
+
+	scanner := bufio.NewScanner(rc)
+	scanner.Buffer(make([]byte, 64*1024), maxLineSize)
+	for scanner.Scan() {
+		lineNo++
+		if lineNo <= progress.Line && !retry[lineNo] {
+			continue
+		}
+		line := scanner.Text()
+		if line == "" {
+			continue
+		}
+		stats.Lines++
+
+		parts := strings.Split(line, "\t")
+		if len(parts) != len(config.fields) {
+			stats.Invalid++
+			config.log.WithFields(logrus.Fields{
+				"file":   file.Name,
+				"line":   lineNo,
+				"fields": len(parts),
+			}).Warn("wrong field count")
+			if !config.dryRun {
+				if err := config.rejects.write(line); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if !config.match(parts, stats) {
+			stats.Skipped++
+			continue
+		}
+		stats.Matched++
+		if config.dryRun {
+			continue
+		}
+
+		// The scanner drops the newline Cloudfront ends each record with; put it back
+		data := []byte(line + "\n")
+		if !b.fits(data, parts[config.requestIDIndex]) {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+		b.add(data, parts[config.requestIDIndex], lineNo)
+		batchLine = lineNo
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if err := flush(); err != nil {
+		return err
+	}
+	if config.checkpoint != nil {
+		return config.checkpoint.update(file.Name, lineNo, "", failed, true)
+	}
+	return nil
+}
+
+// parseTimestamp parses a Cloudfront epoch timestamp with milliseconds, e.g. "1642349408.581".
+func parseTimestamp(value string) (time.Time, error) {
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.UnixMilli(int64(f * 1000)), nil
+}
@@ -0,0 +1,225 @@
+package redrive
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kinesis"
+	"github.com/rmrfslashbin/aws-cf-rtl/pkg/kinesistest"
+	"github.com/rmrfslashbin/aws-cf-rtl/pkg/rtl"
+	"github.com/sirupsen/logrus"
+)
+
+const testStream = "rtl"
+
+// writeLog writes a log of n lines with distinct request IDs and returns its path and lines.
+func writeLog(t *testing.T, n int) (string, []string) {
+	t.Helper()
+	requestID := rtl.FieldIndex(rtl.DefaultFields, "x-edge-request-id")
+	lines := make([]string, n)
+	for i := range lines {
+		parts := make([]string, len(rtl.DefaultFields))
+		for j := range parts {
+			parts[j] = "-"
+		}
+		parts[requestID] = fmt.Sprintf("request-%04d", i+1)
+		lines[i] = strings.Join(parts, "\t")
+	}
+	path := filepath.Join(t.TempDir(), "rtl-1-2022-11-15-00-00-00-0000.tsv")
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path, lines
+}
+
+// newRedrive returns a redrive of the test stream through client.
+func newRedrive(t *testing.T, client KinesisAPI, opts ...Option) *Config {
+	t.Helper()
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	opts = append([]Option{
+		SetClient(client),
+		SetS3Client(nil),
+		SetStream(testStream),
+		SetLogger(log),
+		SetRejectFile(""),
+	}, opts...)
+	r, err := New(opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+// run replays the file at path.
+func run(t *testing.T, r *Config, path string) (*Stats, error) {
+	t.Helper()
+	source, err := r.NewSource(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r.Run(context.Background(), source)
+}
+
+// delivered counts how often each line reached the stream.
+func delivered(server *kinesistest.Server) map[string]int {
+	counts := map[string]int{}
+	for _, record := range server.Records(testStream) {
+		counts[strings.TrimSuffix(string(record.Data), "\n")]++
+	}
+	return counts
+}
+
+// noRetries turns off the SDK retries, leaving them to the redrive.
+func noRetries(o *kinesis.Options) {
+	o.Retryer = aws.NopRetryer{}
+}
+
+func TestRunPartialFailures(t *testing.T) {
+	server := kinesistest.NewServer(kinesistest.SetSeed(7))
+	defer server.Close()
+	server.AddStream(testStream, 2)
+	server.SetRecordFailures(0.2, "InternalFailure")
+
+	path, lines := writeLog(t, 100)
+	stats, err := run(t, newRedrive(t, server.Client(noRetries)), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Records != len(lines) || stats.Failed != 0 || stats.Retries == 0 {
+		t.Errorf("records %d, failed %d, retries %d; want %d, 0 and some retries", stats.Records, stats.Failed, stats.Retries, len(lines))
+	}
+
+	counts := delivered(server)
+	if len(counts) != len(lines) {
+		t.Errorf("%d distinct lines delivered; want %d", len(counts), len(lines))
+	}
+	for i, line := range lines {
+		if counts[line] != 1 {
+			t.Errorf("line %d delivered %d times; want once", i+1, counts[line])
+		}
+	}
+}
+
+// tickingClient advances a clock by a second after every PutRecords.
+type tickingClient struct {
+	KinesisAPI
+	second int64
+}
+
+func (c *tickingClient) PutRecords(ctx context.Context, params *kinesis.PutRecordsInput, optFns ...func(*kinesis.Options)) (*kinesis.PutRecordsOutput, error) {
+	defer atomic.AddInt64(&c.second, 1)
+	return c.KinesisAPI.PutRecords(ctx, params, optFns...)
+}
+
+func (c *tickingClient) now() time.Time {
+	return time.Unix(1668470400+atomic.LoadInt64(&c.second), 0)
+}
+
+func TestRunThrottled(t *testing.T) {
+	// A shard takes 100 records a second and the clock moves on a second per
+	// call, so a batch of 250 needs three calls whatever the backoff
+	client := &tickingClient{}
+	server := kinesistest.NewServer(kinesistest.SetClock(client.now), kinesistest.SetShardLimits(100, 0))
+	defer server.Close()
+	server.AddStream(testStream, 1)
+	client.KinesisAPI = server.Client(noRetries)
+
+	path, lines := writeLog(t, 250)
+	stats, err := run(t, newRedrive(t, client), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls := server.Calls("PutRecords"); calls != 3 {
+		t.Errorf("%d PutRecords calls; want 3", calls)
+	}
+	if stats.Records != len(lines) || stats.Throttled != 2 || stats.Retries != 2 {
+		t.Errorf("records %d, throttled %d, retries %d; want %d, 2 and 2", stats.Records, stats.Throttled, stats.Retries, len(lines))
+	}
+
+	records := server.Records(testStream)
+	if len(records) != len(lines) {
+		t.Fatalf("%d records delivered; want %d", len(records), len(lines))
+	}
+	// Each call accepts the first records up to the limit, so retries keep the order
+	for i, record := range records {
+		if got := strings.TrimSuffix(string(record.Data), "\n"); got != lines[i] {
+			t.Fatalf("record %d is line %q; want line %d", i, got, i+1)
+		}
+	}
+}
+
+func TestRunResume(t *testing.T) {
+	server := kinesistest.NewServer(kinesistest.SetSeed(3))
+	defer server.Close()
+	server.AddStream(testStream, 2)
+	client := server.Client(noRetries)
+
+	path, lines := writeLog(t, 100)
+	// A short line near the end is rejected once, not again on resume
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fmt.Fprintln(f, "short\tline")
+	f.Close()
+	dir := t.TempDir()
+	checkpoint := filepath.Join(dir, "checkpoint.json")
+	rejects := filepath.Join(dir, "rejects.tsv")
+
+	// Without retries some records are rejected for good
+	server.SetRecordFailures(0.1, "InternalFailure")
+	stats, err := run(t, newRedrive(t, client, SetMaxRetries(0), SetCheckpoint(checkpoint, false), SetRejectFile(rejects)), path)
+	if !errors.Is(err, ErrRecordsFailed) {
+		t.Fatalf("first run returned %v; want ErrRecordsFailed", err)
+	}
+	if _, err := os.Stat(checkpoint); err != nil {
+		t.Fatalf("checkpoint not kept after a failed run: %v", err)
+	}
+	failed := stats.Failed
+	if failed == 0 {
+		t.Fatal("no record was rejected")
+	}
+	if first := delivered(server); len(first) != len(lines)-failed {
+		t.Fatalf("%d lines delivered by the first run; want %d", len(first), len(lines)-failed)
+	}
+
+	server.SetRecordFailures(0, "")
+	if _, err := New(SetClient(client), SetS3Client(nil), SetStream(testStream), SetCheckpoint(checkpoint, false)); !errors.Is(err, ErrCheckpointExists) {
+		t.Fatalf("New without resume returned %v; want ErrCheckpointExists", err)
+	}
+	stats, err = run(t, newRedrive(t, client, SetCheckpoint(checkpoint, true), SetRejectFile(rejects)), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.FilesResumed != 1 || stats.Records != failed || stats.Invalid != 0 {
+		t.Errorf("resumed %d files, sent %d records, %d invalid; want 1, %d and 0", stats.FilesResumed, stats.Records, stats.Invalid, failed)
+	}
+	if _, err := os.Stat(checkpoint); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("checkpoint not removed after a complete run: %v", err)
+	}
+
+	// Only the rejected records are sent again, so every line arrives once
+	counts := delivered(server)
+	for i, line := range lines {
+		if counts[line] != 1 {
+			t.Errorf("line %d delivered %d times; want once", i+1, counts[line])
+		}
+	}
+	data, err := os.ReadFile(rejects)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "short\tline\n" {
+		t.Errorf("reject file %q; want the short line once", data)
+	}
+}
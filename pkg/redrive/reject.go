@@ -0,0 +1,48 @@
+package redrive
+
+import (
+	"os"
+	"sync"
+)
+
+// rejectFile collects the lines that could not be parsed, as they were read, so
+// they can be fixed and redriven. It is created on the first reject and appended
+// to, so a resumed redrive keeps the rejects of the earlier run.
+type rejectFile struct {
+	mu   sync.Mutex
+	path string
+	f    *os.File
+}
+
+// write appends line to the reject file.
+func (r *rejectFile) write(line string) error {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.f == nil {
+		f, err := os.OpenFile(r.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+		if err != nil {
+			return err
+		}
+		r.f = f
+	}
+	_, err := r.f.WriteString(line + "\n")
+	return err
+}
+
+// close closes the reject file if one was written.
+func (r *rejectFile) close() error {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.f == nil {
+		return nil
+	}
+	err := r.f.Close()
+	r.f = nil
+	return err
+}
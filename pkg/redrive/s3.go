@@ -0,0 +1,131 @@
+package redrive
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// DefaultKeySlack widens both sides of the object key time window. Firehose names
+// an object for the time its buffer opened, so it holds lines up to the buffer
+// interval (at most 900s) later; and lines reach Firehose some time after the
+// request they log, so an object named after a line may still hold it.
+const DefaultKeySlack = 15 * time.Minute
+
+// S3API is the subset of the S3 client used to read backups.
+type S3API interface {
+	ListObjectsV2(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error)
+	GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error)
+}
+
+var _ S3API = (*s3.Client)(nil)
+
+// keyTime matches the timestamp Firehose puts in object names,
+// e.g. cf-rtl-logs-delivery-stream-1-2022-01-18-00-18-55-1adc337f-84b7-4a04-9620-96890081fec4.
+var keyTime = regexp.MustCompile(`(\d{4}-\d{2}-\d{2}-\d{2}-\d{2}-\d{2})-[0-9a-f]{8}-[0-9a-f]{4}-`)
+
+// s3Source is every object below a bucket prefix, optionally within a key time window.
+type s3Source struct {
+	client S3API
+	bucket string
+	prefix string
+	start  time.Time
+	end    time.Time
+}
+
+// NewS3Source returns a Source for the objects below prefix in bucket, in key order.
+// Objects whose key time is before start minus DefaultKeySlack, or at or after end
+// plus DefaultKeySlack, are left out; keys without a Firehose timestamp are always included.
+func NewS3Source(client S3API, bucket, prefix string, start, end time.Time) Source {
+	return &s3Source{
+		client: client,
+		bucket: bucket,
+		prefix: prefix,
+		start:  start,
+		end:    end,
+	}
+}
+
+// Files lists the objects within the key time window.
+func (source *s3Source) Files(ctx context.Context) ([]File, error) {
+	files := []File{}
+	paginator := s3.NewListObjectsV2Paginator(source.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(source.bucket),
+		Prefix: aws.String(source.prefix),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, object := range page.Contents {
+			key := aws.ToString(object.Key)
+			if strings.HasSuffix(key, "/") || !inKeyWindow(key, source.start, source.end) {
+				continue
+			}
+			files = append(files, File{
+				Name: fmt.Sprintf("s3://%s/%s", source.bucket, key),
+				Size: object.Size,
+				Open: func(ctx context.Context) (io.ReadCloser, error) {
+					out, err := source.client.GetObject(ctx, &s3.GetObjectInput{
+						Bucket: aws.String(source.bucket),
+						Key:    aws.String(key),
+					})
+					if err != nil {
+						return nil, err
+					}
+					return out.Body, nil
+				},
+			})
+		}
+	}
+	return files, nil
+}
+
+// inKeyWindow reports whether the Firehose timestamp in key is within start and
+// end, each widened by DefaultKeySlack. Zero times leave that side open; keys
+// without a timestamp are in.
+func inKeyWindow(key string, start, end time.Time) bool {
+	t, ok := KeyTime(key)
+	if !ok {
+		return true
+	}
+	if !start.IsZero() && t.Before(start.Add(-DefaultKeySlack)) {
+		return false
+	}
+	if !end.IsZero() && !t.Before(end.Add(DefaultKeySlack)) {
+		return false
+	}
+	return true
+}
+
+// KeyTime returns the UTC timestamp Firehose put in an object key or file name.
+func KeyTime(key string) (time.Time, bool) {
+	m := keyTime.FindStringSubmatch(key)
+	if m == nil {
+		return time.Time{}, false
+	}
+	t, err := time.Parse("2006-01-02-15-04-05", m[1])
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// splitS3URL splits s3://bucket/prefix into the bucket and prefix.
+func splitS3URL(location string) (string, string, error) {
+	if !strings.HasPrefix(location, "s3://") {
+		return "", "", fmt.Errorf("not an s3 location: %s", location)
+	}
+	bucket, prefix, _ := strings.Cut(strings.TrimPrefix(location, "s3://"), "/")
+	if bucket == "" {
+		return "", "", fmt.Errorf("no bucket in s3 location: %s", location)
+	}
+	return bucket, prefix, nil
+}
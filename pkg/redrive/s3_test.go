@@ -0,0 +1,34 @@
+package redrive
+
+import (
+	"testing"
+	"time"
+)
+
+func TestInKeyWindow(t *testing.T) {
+	start := time.Date(2022, 11, 15, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2022, 11, 16, 0, 0, 0, 0, time.UTC)
+	key := func(ts string) string {
+		return "backup/rtl/2022/11/15/cf-rtl-1-" + ts + "-1adc337f-84b7-4a04-9620-96890081fec4.gz"
+	}
+	tests := []struct {
+		key   string
+		start time.Time
+		end   time.Time
+		in    bool
+	}{
+		{key("2022-11-15-12-00-00"), start, end, true},
+		{key("2022-11-14-23-50-00"), start, end, true},
+		{key("2022-11-14-23-44-59"), start, end, false},
+		{key("2022-11-16-00-10-00"), start, end, true},
+		{key("2022-11-16-00-15-00"), start, end, false},
+		{key("2022-11-20-00-00-00"), start, time.Time{}, true},
+		{key("2022-11-01-00-00-00"), time.Time{}, end, true},
+		{"backup/rtl/unnamed.gz", start, end, true},
+	}
+	for _, tt := range tests {
+		if in := inKeyWindow(tt.key, tt.start, tt.end); in != tt.in {
+			t.Errorf("inKeyWindow(%s, %s, %s) = %v; want %v", tt.key, tt.start, tt.end, in, tt.in)
+		}
+	}
+}
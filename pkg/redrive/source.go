@@ -0,0 +1,73 @@
+package redrive
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// File is a file of log lines to replay.
+type File struct {
+	Name string
+	Size int64
+	Open func(ctx context.Context) (io.ReadCloser, error)
+}
+
+// Source lists the files to replay.
+type Source interface {
+	Files(ctx context.Context) ([]File, error)
+}
+
+// localSource is a local file or a directory tree of files, optionally within a key time window.
+type localSource struct {
+	path  string
+	start time.Time
+	end   time.Time
+}
+
+// NewLocalSource returns a Source for a local file, or for every file below a directory in name order.
+// Files are limited to the time window by the Firehose timestamp in their names, as with NewS3Source.
+func NewLocalSource(path string, start, end time.Time) Source {
+	return &localSource{
+		path:  filepath.Clean(path),
+		start: start,
+		end:   end,
+	}
+}
+
+// Files lists the file or the files below the directory.
+func (source *localSource) Files(ctx context.Context) ([]File, error) {
+	files := []File{}
+	err := filepath.WalkDir(source.path, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.Type().IsRegular() || !inKeyWindow(entry.Name(), source.start, source.end) {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		files = append(files, File{
+			Name: path,
+			Size: info.Size(),
+			Open: func(ctx context.Context) (io.ReadCloser, error) {
+				return os.Open(path)
+			},
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Name < files[j].Name
+	})
+	return files, nil
+}
@@ -0,0 +1,43 @@
+package rtl
+
+// DefaultFields is the field list used when no other is configured.
+// It matches the Fields list of CloudfrontRealtimeLogConfig in the bundled template.
+var DefaultFields = []string{
+	"timestamp",
+	"c-ip",
+	"sc-status",
+	"sc-bytes",
+	"cs-method",
+	"cs-protocol",
+	"cs-host",
+	"cs-uri-stem",
+	"x-edge-location",
+	"x-edge-request-id",
+	"x-host-header",
+	"time-taken",
+	"cs-protocol-version",
+	"c-ip-version",
+	"cs-user-agent",
+	"cs-referer",
+	"cs-cookie",
+	"cs-uri-query",
+	"x-edge-response-result-type",
+	"ssl-protocol",
+	"ssl-cipher",
+	"x-edge-result-type",
+	"sc-content-type",
+	"sc-content-len",
+	"x-edge-detailed-result-type",
+	"c-country",
+	"cache-behavior-path-pattern",
+}
+
+// FieldIndex returns the position of name in fields, or -1.
+func FieldIndex(fields []string, name string) int {
+	for i, field := range fields {
+		if field == name {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,124 @@
+// Package rtl holds the Cloudfront real-time log fields, the record the Lambda
+// writes, the Glue table schema generated from it, and a reader for raw log files.
+package rtl
+
+import (
+	"net"
+)
+
+// Record represents a single log entry.
+// The Glue table columns are generated from the json tags and Go types; a glue tag
+// overrides the generated column type.
+type Record struct {
+	Timestamp                int64   `json:"timestamp" glue:"timestamp"`
+	ClientIP                 net.IP  `json:"client_ip"`
+	Status                   int     `json:"status"`
+	Bytes                    int64   `json:"bytes"`
+	Method                   string  `json:"method"`
+	Protocol                 string  `json:"protocol"`
+	Host                     string  `json:"host"`
+	URIStem                  string  `json:"uri_stem"`
+	EdgeLocation             string  `json:"edge_location"`
+	EdgeRequestId            string  `json:"edge_request_id"`
+	HostHeader               string  `json:"host_header"`
+	TimeTaken                float64 `json:"time_taken"`
+	ProtoVersion             string  `json:"proto_version"`
+	IPVersion                string  `json:"ip_version"`
+	UserAgent                string  `json:"user_agent"`
+	Referer                  string  `json:"referer"`
+	Cookie                   string  `json:"cookie"`
+	URIQuery                 string  `json:"uri_query"`
+	EdgeResponseResultType   string  `json:"edge_response_result_type"`
+	SSLProtocol              string  `json:"ssl_protocol"`
+	SSLCipher                string  `json:"ssl_cipher"`
+	EdgeResultType           string  `json:"edge_result_type"`
+	ContentType              string  `json:"content_type"`
+	ContentLength            int64   `json:"content_length"`
+	EdgeDetailedResultType   string  `json:"edge_detailed_result_type"`
+	Country                  string  `json:"country"`
+	CacheBehaviorPathPattern string  `json:"cache_behavior_path_pattern"`
+	UserAgentDeviceFamily    string  `json:"user_agent_device_family"`
+	UserAgentDeviceBrand     string  `json:"user_agent_device_brand"`
+	UserAgentDeviceModel     string  `json:"user_agent_device_model"`
+	UserAgentOSFamily        string  `json:"user_agent_os_family"`
+	UserAgentOSMajor         string  `json:"user_agent_os_major"`
+	UserAgentOSMinor         string  `json:"user_agent_os_minor"`
+	UserAgentOSPatch         string  `json:"user_agent_os_patch"`
+	UserAgentOSPatchMinor    string  `json:"user_agent_os_patch_minor"`
+	UserAgentFamily          string  `json:"user_agent_family"`
+	UserAgentMajor           string  `json:"user_agent_major"`
+	UserAgentMinor           string  `json:"user_agent_minor"`
+	UserAgentPatch           string  `json:"user_agent_patch"`
+
+	// Additional fields from the full Cloudfront real-time log field catalog.
+	ServerIP                       net.IP            `json:"server_ip"`
+	TimeToFirstByte                float64           `json:"time_to_first_byte"`
+	RequestBytes                   int64             `json:"request_bytes"`
+	ForwardedFor                   string            `json:"forwarded_for"`
+	FLEEncryptedFields             int               `json:"fle_encrypted_fields"`
+	FLEStatus                      string            `json:"fle_status"`
+	RangeStart                     int64             `json:"range_start"`
+	RangeEnd                       int64             `json:"range_end"`
+	ClientPort                     int               `json:"client_port"`
+	AcceptEncoding                 string            `json:"accept_encoding"`
+	Accept                         string            `json:"accept"`
+	Headers                        map[string]string `json:"headers"`
+	HeaderNames                    []string          `json:"header_names"`
+	HeadersCount                   int               `json:"headers_count"`
+	PrimaryDistributionId          string            `json:"primary_distribution_id"`
+	PrimaryDistributionDNSName     string            `json:"primary_distribution_dns_name"`
+	OriginFirstByteLatency         float64           `json:"origin_fbl"`
+	OriginLastByteLatency          float64           `json:"origin_lbl"`
+	ASN                            int64             `json:"asn"`
+	ServerReason                   string            `json:"sr_reason"`
+	EdgeMQCS                       int               `json:"edge_mqcs"`
+	CMCDEncodedBitrate             int               `json:"cmcd_encoded_bitrate"`
+	CMCDBufferLength               int               `json:"cmcd_buffer_length"`
+	CMCDBufferStarvation           bool              `json:"cmcd_buffer_starvation"`
+	CMCDContentId                  string            `json:"cmcd_content_id"`
+	CMCDObjectDuration             int               `json:"cmcd_object_duration"`
+	CMCDDeadline                   int               `json:"cmcd_deadline"`
+	CMCDMeasuredThroughput         int               `json:"cmcd_measured_throughput"`
+	CMCDNextObjectRequest          string            `json:"cmcd_next_object_request"`
+	CMCDNextRangeRequest           string            `json:"cmcd_next_range_request"`
+	CMCDObjectType                 string            `json:"cmcd_object_type"`
+	CMCDPlaybackRate               float64           `json:"cmcd_playback_rate"`
+	CMCDRequestedMaximumThroughput int               `json:"cmcd_requested_maximum_throughput"`
+	CMCDStreamingFormat            string            `json:"cmcd_streaming_format"`
+	CMCDSessionId                  string            `json:"cmcd_session_id"`
+	CMCDStreamType                 string            `json:"cmcd_stream_type"`
+	CMCDStartup                    bool              `json:"cmcd_startup"`
+	CMCDTopBitrate                 int               `json:"cmcd_top_bitrate"`
+	CMCDVersion                    int               `json:"cmcd_version"`
+
+	// GeoIP enrichment, populated when a GeoIP database is available.
+	GeoCity        string  `json:"geo_city"`
+	GeoSubdivision string  `json:"geo_subdivision"`
+	GeoPostalCode  string  `json:"geo_postal_code"`
+	GeoLatitude    float64 `json:"geo_latitude"`
+	GeoLongitude   float64 `json:"geo_longitude"`
+	GeoTimeZone    string  `json:"geo_time_zone"`
+	GeoMetroCode   uint    `json:"geo_metro_code" glue:"int"`
+
+	// ASN, ISP and connection type enrichment, populated when the matching databases are available.
+	GeoASN            uint   `json:"geo_asn"`
+	GeoASOrganization string `json:"geo_as_organization"`
+	GeoISP            string `json:"geo_isp"`
+	GeoOrganization   string `json:"geo_organization"`
+	GeoConnectionType string `json:"geo_connection_type"`
+
+	// GeoDatabaseBuild records the GeoIP database builds used, when RTL_GEOIP_BUILD is enabled.
+	GeoDatabaseBuild string `json:"geo_database_build"`
+
+	// Automated traffic classification of the user-agent.
+	UserAgentIsBot       bool   `json:"user_agent_is_bot"`
+	UserAgentBotCategory string `json:"user_agent_bot_category"`
+	UserAgentBotName     string `json:"user_agent_bot_name"`
+	UserAgentBotOperator string `json:"user_agent_bot_operator"`
+
+	// Client hints merged from cs-headers, and which source won per field.
+	UserAgentMobile       bool   `json:"user_agent_mobile"`
+	UserAgentSource       string `json:"user_agent_source"`
+	UserAgentOSSource     string `json:"user_agent_os_source"`
+	UserAgentDeviceSource string `json:"user_agent_device_source"`
+}
@@ -0,0 +1,233 @@
+package rtl
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Column is a Glue table column.
+type Column struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// PartitionKeys are the partition columns, matching the Firehose year=/month=/day= prefix.
+var PartitionKeys = []Column{
+	{Name: "year", Type: "string"},
+	{Name: "month", Type: "string"},
+	{Name: "day", Type: "string"},
+}
+
+// SerDeLibrary is the SerDe the Firehose format conversion reads the Record JSON with.
+const SerDeLibrary = "org.openx.data.jsonserde.JsonSerDe"
+
+var ipType = reflect.TypeOf(net.IP{})
+
+// Columns returns the Glue columns of Record in field order.
+func Columns() []Column {
+	t := reflect.TypeOf(Record{})
+	columns := make([]Column, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		glueType := field.Tag.Get("glue")
+		if glueType == "" {
+			glueType = glueTypeOf(field.Type)
+		}
+		columns = append(columns, Column{Name: name, Type: glueType})
+	}
+	return columns
+}
+
+// glueTypeOf maps a Go type to its Glue (Hive) type.
+func glueTypeOf(t reflect.Type) string {
+	if t == ipType {
+		return "string"
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16:
+		return "int"
+	case reflect.Int:
+		// int is 64 bits in the Lambda but the Cloudfront values it holds fit in 32
+		return "int"
+	case reflect.Int64, reflect.Uint, reflect.Uint32, reflect.Uint64:
+		return "bigint"
+	case reflect.Float32:
+		return "float"
+	case reflect.Float64:
+		return "double"
+	case reflect.Slice:
+		return "array<" + glueTypeOf(t.Elem()) + ">"
+	case reflect.Map:
+		return "map<" + glueTypeOf(t.Key()) + "," + glueTypeOf(t.Elem()) + ">"
+	default:
+		panic(fmt.Sprintf("rtl: no glue type for %s", t))
+	}
+}
+
+// SerDePaths returns the JSON SerDe paths parameter: the column names, sorted and comma separated.
+func SerDePaths() string {
+	columns := Columns()
+	names := make([]string, len(columns))
+	for i, column := range columns {
+		names[i] = column.Name
+	}
+	sort.Strings(names)
+	return strings.Join(names, ",")
+}
+
+// AthenaDDL returns the CREATE EXTERNAL TABLE statement for the JSON records at location.
+// The processed Firehose output is ORC; pass orc to describe that instead.
+func AthenaDDL(database, table, location string, orc bool) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "CREATE EXTERNAL TABLE IF NOT EXISTS `%s`.`%s` (\n", database, table)
+	columns := Columns()
+	for i, column := range columns {
+		sep := ","
+		if i == len(columns)-1 {
+			sep = ""
+		}
+		fmt.Fprintf(&b, "  `%s` %s%s\n", column.Name, column.Type, sep)
+	}
+	b.WriteString(")\n")
+
+	keys := make([]string, len(PartitionKeys))
+	for i, key := range PartitionKeys {
+		keys[i] = fmt.Sprintf("`%s` %s", key.Name, key.Type)
+	}
+	fmt.Fprintf(&b, "PARTITIONED BY (%s)\n", strings.Join(keys, ", "))
+
+	if orc {
+		b.WriteString("STORED AS ORC\n")
+	} else {
+		fmt.Fprintf(&b, "ROW FORMAT SERDE '%s'\n", SerDeLibrary)
+		fmt.Fprintf(&b, "WITH SERDEPROPERTIES ('paths' = '%s')\n", SerDePaths())
+		b.WriteString("STORED AS INPUTFORMAT 'org.apache.hadoop.mapred.TextInputFormat'\n")
+		b.WriteString("OUTPUTFORMAT 'org.apache.hadoop.hive.ql.io.HiveIgnoreKeyTextOutputFormat'\n")
+	}
+	fmt.Fprintf(&b, "LOCATION '%s';\n", location)
+	return b.String()
+}
+
+// JSONSchema returns a JSON Schema (draft 2020-12) describing the Record JSON.
+func JSONSchema() map[string]interface{} {
+	properties := map[string]interface{}{}
+	for _, column := range Columns() {
+		properties[column.Name] = jsonSchemaOf(column.Type)
+	}
+	return map[string]interface{}{
+		"$schema":              "https://json-schema.org/draft/2020-12/schema",
+		"title":                "Cloudfront real-time log record",
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+}
+
+// jsonSchemaOf maps a Glue type to a JSON Schema.
+func jsonSchemaOf(glueType string) map[string]interface{} {
+	switch {
+	case glueType == "string":
+		// net.IP encodes as a string; null when unset
+		return map[string]interface{}{"type": []string{"string", "null"}}
+	case glueType == "boolean":
+		return map[string]interface{}{"type": "boolean"}
+	case glueType == "int", glueType == "bigint":
+		return map[string]interface{}{"type": "integer"}
+	case glueType == "timestamp":
+		return map[string]interface{}{"type": "integer", "description": "milliseconds since the epoch"}
+	case glueType == "float", glueType == "double":
+		return map[string]interface{}{"type": "number"}
+	case strings.HasPrefix(glueType, "array<"):
+		return map[string]interface{}{
+			"type":  []string{"array", "null"},
+			"items": jsonSchemaOf(strings.TrimSuffix(strings.TrimPrefix(glueType, "array<"), ">")),
+		}
+	case strings.HasPrefix(glueType, "map<"):
+		_, value, _ := strings.Cut(strings.TrimSuffix(strings.TrimPrefix(glueType, "map<"), ">"), ",")
+		return map[string]interface{}{
+			"type":                 []string{"object", "null"},
+			"additionalProperties": jsonSchemaOf(value),
+		}
+	default:
+		return map[string]interface{}{}
+	}
+}
+
+// Difference kinds reported by Diff.
+const (
+	DiffMissing = "missing" // in Record, not in the table
+	DiffExtra   = "extra"   // in the table, not in Record
+	DiffType    = "type"    // in both with different types
+)
+
+// ColumnDiff is a difference between Record and a Glue table.
+type ColumnDiff struct {
+	Kind       string `json:"kind"`
+	Name       string `json:"name"`
+	RecordType string `json:"record_type,omitempty"`
+	TableType  string `json:"table_type,omitempty"`
+}
+
+// Diff compares the Record columns against a table's columns. Names and
+// types are compared case-insensitively, as Glue lower-cases them.
+func Diff(table []Column) []ColumnDiff {
+	diffs := []ColumnDiff{}
+	live := make(map[string]string, len(table))
+	for _, column := range table {
+		live[strings.ToLower(column.Name)] = strings.ToLower(column.Type)
+	}
+
+	want := map[string]bool{}
+	for _, column := range Columns() {
+		want[column.Name] = true
+		tableType, ok := live[column.Name]
+		switch {
+		case !ok:
+			diffs = append(diffs, ColumnDiff{Kind: DiffMissing, Name: column.Name, RecordType: column.Type})
+		case tableType != column.Type:
+			diffs = append(diffs, ColumnDiff{Kind: DiffType, Name: column.Name, RecordType: column.Type, TableType: tableType})
+		}
+	}
+	for _, column := range table {
+		if name := strings.ToLower(column.Name); !want[name] {
+			diffs = append(diffs, ColumnDiff{Kind: DiffExtra, Name: name, TableType: strings.ToLower(column.Type)})
+		}
+	}
+	return diffs
+}
+
+// DiffPaths compares the SerDe paths parameter of a table against the Record columns,
+// returning the columns missing from paths and the paths with no column.
+func DiffPaths(paths string) ([]string, []string) {
+	have := map[string]bool{}
+	for _, path := range strings.Split(paths, ",") {
+		if path = strings.TrimSpace(path); path != "" {
+			have[path] = true
+		}
+	}
+
+	missing := []string{}
+	for _, column := range Columns() {
+		if !have[column.Name] {
+			missing = append(missing, column.Name)
+		}
+		delete(have, column.Name)
+	}
+	extra := make([]string, 0, len(have))
+	for path := range have {
+		extra = append(extra, path)
+	}
+	sort.Strings(extra)
+	return missing, extra
+}
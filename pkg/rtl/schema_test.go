@@ -0,0 +1,174 @@
+package rtl
+
+import (
+	"bufio"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// tableColumns returns Columns with the named column changed by fn, or removed when fn returns false.
+func tableColumns(name string, fn func(*Column) bool) []Column {
+	columns := []Column{}
+	for _, column := range Columns() {
+		if column.Name == name && !fn(&column) {
+			continue
+		}
+		columns = append(columns, column)
+	}
+	return columns
+}
+
+func TestColumnOverrides(t *testing.T) {
+	types := map[string]string{}
+	for _, column := range Columns() {
+		types[column.Name] = column.Type
+	}
+	tests := map[string]string{
+		"timestamp":      "timestamp", // glue:"timestamp" on an int64
+		"geo_metro_code": "int",       // glue:"int" on a uint
+		"status":         "int",
+		"client_ip":      "string",
+	}
+	for name, want := range tests {
+		if types[name] != want {
+			t.Errorf("column %s is %s; want %s", name, types[name], want)
+		}
+	}
+}
+
+func TestDiff(t *testing.T) {
+	keep := func(*Column) bool { return true }
+	tests := []struct {
+		name  string
+		table []Column
+		want  []ColumnDiff
+	}{
+		{"same", Columns(), []ColumnDiff{}},
+		{"upper case", tableColumns("host", func(c *Column) bool {
+			c.Name, c.Type = "HOST", "STRING"
+			return true
+		}), []ColumnDiff{}},
+		{"removed", tableColumns("host", func(*Column) bool { return false }),
+			[]ColumnDiff{{Kind: DiffMissing, Name: "host", RecordType: "string"}}},
+		{"added", append(tableColumns("", keep), Column{Name: "Extra_Column", Type: "String"}),
+			[]ColumnDiff{{Kind: DiffExtra, Name: "extra_column", TableType: "string"}}},
+		{"changed", tableColumns("status", func(c *Column) bool {
+			c.Type = "string"
+			return true
+		}), []ColumnDiff{{Kind: DiffType, Name: "status", RecordType: "int", TableType: "string"}}},
+		{"timestamp as bigint", tableColumns("timestamp", func(c *Column) bool {
+			c.Type = "bigint"
+			return true
+		}), []ColumnDiff{{Kind: DiffType, Name: "timestamp", RecordType: "timestamp", TableType: "bigint"}}},
+		{"metro code as bigint", tableColumns("geo_metro_code", func(c *Column) bool {
+			c.Type = "bigint"
+			return true
+		}), []ColumnDiff{{Kind: DiffType, Name: "geo_metro_code", RecordType: "int", TableType: "bigint"}}},
+	}
+	for _, tt := range tests {
+		if got := Diff(tt.table); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: Diff = %+v; want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDiffPaths(t *testing.T) {
+	tests := []struct {
+		name    string
+		paths   string
+		missing []string
+		extra   []string
+	}{
+		{"same", SerDePaths(), []string{}, []string{}},
+		{"spaced", strings.ReplaceAll(SerDePaths(), ",", ", "), []string{}, []string{}},
+		{"removed", strings.Replace(SerDePaths(), "host,", "", 1), []string{"host"}, []string{}},
+		{"added", SerDePaths() + ",zzz,aaa", []string{}, []string{"aaa", "zzz"}},
+	}
+	for _, tt := range tests {
+		missing, extra := DiffPaths(tt.paths)
+		if !reflect.DeepEqual(missing, tt.missing) || !reflect.DeepEqual(extra, tt.extra) {
+			t.Errorf("%s: DiffPaths = %v, %v; want %v, %v", tt.name, missing, extra, tt.missing, tt.extra)
+		}
+	}
+}
+
+// templateTable reads the columns and SerDe paths of the Glue table in the CloudFormation template.
+func templateTable(t *testing.T) ([]Column, string) {
+	t.Helper()
+	f, err := os.Open("../../aws-cloudformation/template.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	columns := []Column{}
+	paths := ""
+	indent := -1
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+	for scanner.Scan() {
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+		depth := len(line) - len(strings.TrimLeft(line, " "))
+		switch {
+		case trimmed == "Columns:":
+			indent = depth
+		case indent >= 0 && depth <= indent:
+			indent = -1
+		case indent >= 0 && strings.HasPrefix(trimmed, "- Name: "):
+			columns = append(columns, Column{Name: strings.TrimPrefix(trimmed, "- Name: ")})
+		case indent >= 0 && strings.HasPrefix(trimmed, "Type: ") && len(columns) > 0:
+			columns[len(columns)-1].Type = strings.TrimPrefix(trimmed, "Type: ")
+		}
+		if strings.HasPrefix(trimmed, "paths: ") {
+			paths = strings.TrimPrefix(trimmed, "paths: ")
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return columns, paths
+}
+
+func TestTemplateColumns(t *testing.T) {
+	columns, paths := templateTable(t)
+	if !reflect.DeepEqual(columns, Columns()) {
+		t.Errorf("template columns differ from Record: %+v", Diff(columns))
+	}
+	if paths != SerDePaths() {
+		missing, extra := DiffPaths(paths)
+		t.Errorf("template SerDe paths differ from Record: missing %v, extra %v", missing, extra)
+	}
+}
+
+func TestAthenaDDL(t *testing.T) {
+	for _, orc := range []bool{false, true} {
+		ddl := AthenaDDL("cfrtl", "rtl", "s3://bucket/processed/rtl/", orc)
+		for _, column := range Columns() {
+			if !strings.Contains(ddl, "`"+column.Name+"` "+column.Type) {
+				t.Errorf("orc %v: DDL has no column %s %s", orc, column.Name, column.Type)
+			}
+		}
+		if got := strings.Contains(ddl, "STORED AS ORC"); got != orc {
+			t.Errorf("orc %v: DDL stored as ORC is %v", orc, got)
+		}
+		if got := strings.Contains(ddl, SerDePaths()); got == orc {
+			t.Errorf("orc %v: DDL has the JSON SerDe paths %v", orc, got)
+		}
+		if !strings.HasSuffix(ddl, "LOCATION 's3://bucket/processed/rtl/';\n") {
+			t.Errorf("orc %v: DDL does not end with the location:\n%s", orc, ddl)
+		}
+	}
+}
+
+func TestJSONSchema(t *testing.T) {
+	properties := JSONSchema()["properties"].(map[string]interface{})
+	if len(properties) != len(Columns()) {
+		t.Errorf("%d properties; want one per column, %d", len(properties), len(Columns()))
+	}
+	if timestamp := properties["timestamp"].(map[string]interface{}); timestamp["type"] != "integer" {
+		t.Errorf("timestamp is %v; want an integer", timestamp["type"])
+	}
+}
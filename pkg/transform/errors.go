@@ -0,0 +1,57 @@
+package transform
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// Reasons a log line could not be converted into a Record.
+const (
+	ReasonFieldCount = "field_count"
+	ReasonFieldParse = "field_parse"
+	ReasonEncode     = "encode"
+	ReasonPanic      = "panic"
+)
+
+// ParseError describes why a log line could not be converted into a Record.
+type ParseError struct {
+	Reason string `json:"reason"`
+	Field  string `json:"field,omitempty"`
+	Value  string `json:"value,omitempty"`
+	Err    string `json:"error"`
+}
+
+// Error implements the error interface.
+func (e *ParseError) Error() string {
+	if e.Field != "" {
+		return fmt.Sprintf("%s: %s: %s", e.Reason, e.Field, e.Err)
+	}
+	return fmt.Sprintf("%s: %s", e.Reason, e.Err)
+}
+
+// FailedRecord is the payload returned to Firehose for records that failed processing.
+// Firehose writes it under the delivery stream's error output prefix.
+type FailedRecord struct {
+	*ParseError
+	RawData string `json:"raw_data"`
+}
+
+// failed builds a ProcessingFailed response record carrying the error and the original data.
+func failed(record events.KinesisFirehoseEventRecord, perr *ParseError) events.KinesisFirehoseResponseRecord {
+	data, err := json.Marshal(&FailedRecord{
+		ParseError: perr,
+		RawData:    string(record.Data),
+	})
+	if err != nil {
+		// Fall back to the original data so the record is never lost.
+		data = record.Data
+	}
+
+	return events.KinesisFirehoseResponseRecord{
+		RecordID: record.RecordID,
+		Result:   events.KinesisFirehoseTransformedStateProcessingFailed,
+		Data:     data,
+	}
+}
@@ -0,0 +1,339 @@
+package transform
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/rmrfslashbin/aws-cf-rtl/pkg/rtl"
+)
+
+// fieldParser parses a single raw log value into the Record.
+type fieldParser func(record *rtl.Record, value string) error
+
+// registry maps Cloudfront real-time log field names to their parsers.
+var registry = map[string]fieldParser{
+	"timestamp": func(r *rtl.Record, v string) error {
+		tstampfloat, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return err
+		}
+		r.Timestamp = int64(tstampfloat * 1000)
+		return nil
+	},
+	"c-ip": func(r *rtl.Record, v string) error {
+		r.ClientIP = net.ParseIP(v)
+		if r.ClientIP == nil {
+			return fmt.Errorf("invalid ip address: %q", v)
+		}
+		return nil
+	},
+	"sc-status":                   parseInt(func(r *rtl.Record) *int { return &r.Status }),
+	"sc-bytes":                    parseInt64(func(r *rtl.Record) *int64 { return &r.Bytes }),
+	"cs-method":                   parseString(func(r *rtl.Record) *string { return &r.Method }),
+	"cs-protocol":                 parseString(func(r *rtl.Record) *string { return &r.Protocol }),
+	"cs-host":                     parseString(func(r *rtl.Record) *string { return &r.Host }),
+	"cs-uri-stem":                 parseString(func(r *rtl.Record) *string { return &r.URIStem }),
+	"x-edge-location":             parseString(func(r *rtl.Record) *string { return &r.EdgeLocation }),
+	"x-edge-request-id":           parseString(func(r *rtl.Record) *string { return &r.EdgeRequestId }),
+	"x-host-header":               parseString(func(r *rtl.Record) *string { return &r.HostHeader }),
+	"time-taken":                  parseFloat(func(r *rtl.Record) *float64 { return &r.TimeTaken }),
+	"cs-protocol-version":         parseString(func(r *rtl.Record) *string { return &r.ProtoVersion }),
+	"c-ip-version":                parseString(func(r *rtl.Record) *string { return &r.IPVersion }),
+	"cs-user-agent":               parseString(func(r *rtl.Record) *string { return &r.UserAgent }),
+	"cs-referer":                  parseString(func(r *rtl.Record) *string { return &r.Referer }),
+	"cs-cookie":                   parseString(func(r *rtl.Record) *string { return &r.Cookie }),
+	"cs-uri-query":                parseString(func(r *rtl.Record) *string { return &r.URIQuery }),
+	"x-edge-response-result-type": parseString(func(r *rtl.Record) *string { return &r.EdgeResponseResultType }),
+	"ssl-protocol":                parseString(func(r *rtl.Record) *string { return &r.SSLProtocol }),
+	"ssl-cipher":                  parseString(func(r *rtl.Record) *string { return &r.SSLCipher }),
+	"x-edge-result-type":          parseString(func(r *rtl.Record) *string { return &r.EdgeResultType }),
+	"sc-content-type":             parseString(func(r *rtl.Record) *string { return &r.ContentType }),
+	"sc-content-len":              parseInt64(func(r *rtl.Record) *int64 { return &r.ContentLength }),
+	"x-edge-detailed-result-type": parseString(func(r *rtl.Record) *string { return &r.EdgeDetailedResultType }),
+	"c-country":                   parseString(func(r *rtl.Record) *string { return &r.Country }),
+	"cache-behavior-path-pattern": parseString(func(r *rtl.Record) *string { return &r.CacheBehaviorPathPattern }),
+	"s-ip": func(r *rtl.Record, v string) error {
+		if isEmpty(v) {
+			return nil
+		}
+		r.ServerIP = net.ParseIP(v)
+		if r.ServerIP == nil {
+			return fmt.Errorf("invalid ip address: %q", v)
+		}
+		return nil
+	},
+	"time-to-first-byte":   parseFloat(func(r *rtl.Record) *float64 { return &r.TimeToFirstByte }),
+	"cs-bytes":             parseInt64(func(r *rtl.Record) *int64 { return &r.RequestBytes }),
+	"x-forwarded-for":      parseString(func(r *rtl.Record) *string { return &r.ForwardedFor }),
+	"fle-encrypted-fields": parseInt(func(r *rtl.Record) *int { return &r.FLEEncryptedFields }),
+	"fle-status":           parseString(func(r *rtl.Record) *string { return &r.FLEStatus }),
+	"sc-range-start":       parseInt64(func(r *rtl.Record) *int64 { return &r.RangeStart }),
+	"sc-range-end":         parseInt64(func(r *rtl.Record) *int64 { return &r.RangeEnd }),
+	"c-port":               parseInt(func(r *rtl.Record) *int { return &r.ClientPort }),
+	"cs-accept-encoding":   parseString(func(r *rtl.Record) *string { return &r.AcceptEncoding }),
+	"cs-accept":            parseString(func(r *rtl.Record) *string { return &r.Accept }),
+	"cs-headers": func(r *rtl.Record, v string) error {
+		if isEmpty(v) {
+			return nil
+		}
+		headers, err := decodeHeaders(v)
+		if err != nil {
+			return err
+		}
+		r.Headers = headers
+		return nil
+	},
+	"cs-header-names": func(r *rtl.Record, v string) error {
+		if isEmpty(v) {
+			return nil
+		}
+		names, err := decodeHeaderNames(v)
+		if err != nil {
+			return err
+		}
+		r.HeaderNames = names
+		return nil
+	},
+	"cs-headers-count":                  parseInt(func(r *rtl.Record) *int { return &r.HeadersCount }),
+	"primary-distribution-id":           parseString(func(r *rtl.Record) *string { return &r.PrimaryDistributionId }),
+	"primary-distribution-dns-name":     parseString(func(r *rtl.Record) *string { return &r.PrimaryDistributionDNSName }),
+	"origin-fbl":                        parseFloat(func(r *rtl.Record) *float64 { return &r.OriginFirstByteLatency }),
+	"origin-lbl":                        parseFloat(func(r *rtl.Record) *float64 { return &r.OriginLastByteLatency }),
+	"asn":                               parseInt64(func(r *rtl.Record) *int64 { return &r.ASN }),
+	"sr-reason":                         parseString(func(r *rtl.Record) *string { return &r.ServerReason }),
+	"x-edge-mqcs":                       parseInt(func(r *rtl.Record) *int { return &r.EdgeMQCS }),
+	"cmcd-encoded-bitrate":              parseInt(func(r *rtl.Record) *int { return &r.CMCDEncodedBitrate }),
+	"cmcd-buffer-length":                parseInt(func(r *rtl.Record) *int { return &r.CMCDBufferLength }),
+	"cmcd-buffer-starvation":            parseBool(func(r *rtl.Record) *bool { return &r.CMCDBufferStarvation }),
+	"cmcd-content-id":                   parseString(func(r *rtl.Record) *string { return &r.CMCDContentId }),
+	"cmcd-object-duration":              parseInt(func(r *rtl.Record) *int { return &r.CMCDObjectDuration }),
+	"cmcd-deadline":                     parseInt(func(r *rtl.Record) *int { return &r.CMCDDeadline }),
+	"cmcd-measured-throughput":          parseInt(func(r *rtl.Record) *int { return &r.CMCDMeasuredThroughput }),
+	"cmcd-next-object-request":          parseString(func(r *rtl.Record) *string { return &r.CMCDNextObjectRequest }),
+	"cmcd-next-range-request":           parseString(func(r *rtl.Record) *string { return &r.CMCDNextRangeRequest }),
+	"cmcd-object-type":                  parseString(func(r *rtl.Record) *string { return &r.CMCDObjectType }),
+	"cmcd-playback-rate":                parseFloat(func(r *rtl.Record) *float64 { return &r.CMCDPlaybackRate }),
+	"cmcd-requested-maximum-throughput": parseInt(func(r *rtl.Record) *int { return &r.CMCDRequestedMaximumThroughput }),
+	"cmcd-streaming-format":             parseString(func(r *rtl.Record) *string { return &r.CMCDStreamingFormat }),
+	"cmcd-session-id":                   parseString(func(r *rtl.Record) *string { return &r.CMCDSessionId }),
+	"cmcd-stream-type":                  parseString(func(r *rtl.Record) *string { return &r.CMCDStreamType }),
+	"cmcd-startup":                      parseBool(func(r *rtl.Record) *bool { return &r.CMCDStartup }),
+	"cmcd-top-bitrate":                  parseInt(func(r *rtl.Record) *int { return &r.CMCDTopBitrate }),
+	"cmcd-version":                      parseInt(func(r *rtl.Record) *int { return &r.CMCDVersion }),
+}
+
+// ParseMode controls how a field parse error is handled.
+type ParseMode int
+
+const (
+	// Lenient logs the parse error and leaves the field at its zero value.
+	Lenient ParseMode = iota
+	// Strict fails the whole record.
+	Strict
+)
+
+// strictFields are the fields parsed in Strict mode unless configured otherwise.
+// The timestamp drives the Firehose partition keys, so a bad value must not land in 1970.
+var strictFields = map[string]bool{
+	"timestamp": true,
+}
+
+// ParseModeFromString converts "strict" or "lenient" to a ParseMode.
+func ParseModeFromString(mode string) (ParseMode, error) {
+	switch strings.ToLower(strings.TrimSpace(mode)) {
+	case "strict":
+		return Strict, nil
+	case "lenient":
+		return Lenient, nil
+	default:
+		return Lenient, fmt.Errorf("unknown parse mode: %q", mode)
+	}
+}
+
+// Schema is an ordered list of fields as configured on the Cloudfront real-time log config.
+type Schema struct {
+	fields  []string
+	parsers []fieldParser
+	modes   []ParseMode
+}
+
+// NewSchema builds a Schema from an ordered list of Cloudfront field names.
+func NewSchema(fields []string) (*Schema, error) {
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("no fields specified")
+	}
+
+	schema := &Schema{
+		fields:  make([]string, len(fields)),
+		parsers: make([]fieldParser, len(fields)),
+		modes:   make([]ParseMode, len(fields)),
+	}
+	for i, name := range fields {
+		name = strings.TrimSpace(name)
+		parser, ok := registry[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown field: %q", name)
+		}
+		schema.fields[i] = name
+		schema.parsers[i] = parser
+		if strictFields[name] {
+			schema.modes[i] = Strict
+		}
+	}
+	return schema, nil
+}
+
+// SetMode sets the parse mode of the named field.
+func (s *Schema) SetMode(field string, mode ParseMode) error {
+	found := false
+	for i, name := range s.fields {
+		if name == field {
+			s.modes[i] = mode
+			found = true
+		}
+	}
+	if !found {
+		return fmt.Errorf("field not in schema: %q", field)
+	}
+	return nil
+}
+
+// SetAllModes sets the parse mode of every field.
+func (s *Schema) SetAllModes(mode ParseMode) {
+	for i := range s.modes {
+		s.modes[i] = mode
+	}
+}
+
+// Fields returns the ordered field names of the schema.
+func (s *Schema) Fields() []string {
+	return s.fields
+}
+
+// isEmpty reports whether a raw value is Cloudfront's placeholder for "no value".
+func isEmpty(value string) bool {
+	return value == "" || value == "-"
+}
+
+func parseString(field func(*rtl.Record) *string) fieldParser {
+	return func(r *rtl.Record, v string) error {
+		*field(r) = v
+		return nil
+	}
+}
+
+func parseInt(field func(*rtl.Record) *int) fieldParser {
+	return func(r *rtl.Record, v string) error {
+		if isEmpty(v) {
+			return nil
+		}
+		i, err := strconv.Atoi(v)
+		if err != nil {
+			return err
+		}
+		*field(r) = i
+		return nil
+	}
+}
+
+func parseInt64(field func(*rtl.Record) *int64) fieldParser {
+	return func(r *rtl.Record, v string) error {
+		if isEmpty(v) {
+			return nil
+		}
+		i, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return err
+		}
+		*field(r) = i
+		return nil
+	}
+}
+
+func parseFloat(field func(*rtl.Record) *float64) fieldParser {
+	return func(r *rtl.Record, v string) error {
+		if isEmpty(v) {
+			return nil
+		}
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return err
+		}
+		*field(r) = f
+		return nil
+	}
+}
+
+func parseBool(field func(*rtl.Record) *bool) fieldParser {
+	return func(r *rtl.Record, v string) error {
+		if isEmpty(v) {
+			return nil
+		}
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return err
+		}
+		*field(r) = b
+		return nil
+	}
+}
+
+// splitHeaderLines percent-decodes a cs-headers or cs-header-names value
+// and splits it into lines.
+func splitHeaderLines(value string) ([]string, error) {
+	decoded, err := url.PathUnescape(value)
+	if err != nil {
+		return nil, err
+	}
+
+	lines := []string{}
+	for _, line := range strings.Split(decoded, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines, nil
+}
+
+// decodeHeaders decodes the percent-encoded cs-headers value into a map keyed on
+// the lower-cased header name. Repeated headers are joined with a comma.
+func decodeHeaders(value string) (map[string]string, error) {
+	lines, err := splitHeaderLines(value)
+	if err != nil {
+		return nil, err
+	}
+
+	headers := make(map[string]string, len(lines))
+	for _, line := range lines {
+		name, val, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, fmt.Errorf("malformed header: %q", line)
+		}
+		name = strings.ToLower(strings.TrimSpace(name))
+		val = strings.TrimSpace(val)
+		if prev, ok := headers[name]; ok {
+			val = prev + "," + val
+		}
+		headers[name] = val
+	}
+	return headers, nil
+}
+
+// decodeHeaderNames decodes the percent-encoded cs-header-names value into a list
+// of lower-cased header names.
+func decodeHeaderNames(value string) ([]string, error) {
+	lines, err := splitHeaderLines(value)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(lines))
+	for _, line := range lines {
+		names = append(names, strings.ToLower(strings.TrimSpace(line)))
+	}
+	return names, nil
+}
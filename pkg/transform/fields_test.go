@@ -0,0 +1,189 @@
+package transform
+
+import (
+	"io"
+	"testing"
+
+	"github.com/rmrfslashbin/aws-cf-rtl/pkg/rtl"
+	"github.com/sirupsen/logrus"
+)
+
+// newTestConfig returns a transform for lines with the schema.
+func newTestConfig(t *testing.T, schema *Schema) *Config {
+	t.Helper()
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	config, err := New(SetSchema(schema), SetLogger(log))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return config
+}
+
+// newTestSchema returns the schema of the timestamp, c-ip and sc-status fields.
+func newTestSchema(t *testing.T) *Schema {
+	t.Helper()
+	schema, err := NewSchema([]string{"timestamp", "c-ip", "sc-status"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return schema
+}
+
+func TestParseModeFromString(t *testing.T) {
+	tests := map[string]ParseMode{"strict": Strict, " STRICT ": Strict, "lenient": Lenient, "Lenient": Lenient}
+	for s, want := range tests {
+		if mode, err := ParseModeFromString(s); err != nil || mode != want {
+			t.Errorf("ParseModeFromString(%q) = %v, %v; want %v", s, mode, err, want)
+		}
+	}
+	for _, s := range []string{"", "loose", "strictly"} {
+		if _, err := ParseModeFromString(s); err == nil {
+			t.Errorf("ParseModeFromString(%q) succeeded", s)
+		}
+	}
+}
+
+func TestDefaultModes(t *testing.T) {
+	schema, err := NewSchema(rtl.DefaultFields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, field := range schema.fields {
+		want := Lenient
+		if field == "timestamp" {
+			want = Strict
+		}
+		if schema.modes[i] != want {
+			t.Errorf("field %s mode %v; want %v", field, schema.modes[i], want)
+		}
+	}
+}
+
+func TestLenientField(t *testing.T) {
+	config := newTestConfig(t, newTestSchema(t))
+	record, perr := config.Parse("1642349408.581\t10.0.0.1\tOK")
+	if perr != nil {
+		t.Fatalf("lenient sc-status failed the record: %s", perr)
+	}
+	if record.Status != 0 || record.Timestamp != 1642349408581 {
+		t.Errorf("status %d, timestamp %d; want 0 and the line timestamp", record.Status, record.Timestamp)
+	}
+}
+
+func TestStrictField(t *testing.T) {
+	schema := newTestSchema(t)
+	if err := schema.SetMode("sc-status", Strict); err != nil {
+		t.Fatal(err)
+	}
+	if err := schema.SetMode("cs-host", Strict); err == nil {
+		t.Error("SetMode of a field not in the schema succeeded")
+	}
+	config := newTestConfig(t, schema)
+	record, perr := config.Parse("1642349408.581\t10.0.0.1\tOK")
+	if record != nil || perr == nil {
+		t.Fatalf("strict sc-status kept the record %+v", record)
+	}
+	if perr.Reason != ReasonFieldParse || perr.Field != "sc-status" || perr.Value != "OK" {
+		t.Errorf("error %+v; want field_parse of sc-status", perr)
+	}
+}
+
+func TestStrictTimestamp(t *testing.T) {
+	schema := newTestSchema(t)
+	config := newTestConfig(t, schema)
+	if _, perr := config.Parse("yesterday\t10.0.0.1\t200"); perr == nil || perr.Reason != ReasonFieldParse || perr.Field != "timestamp" {
+		t.Errorf("bad timestamp returned %v; want field_parse of timestamp", perr)
+	}
+
+	// Every field lenient, the timestamp included
+	schema.SetAllModes(Lenient)
+	record, perr := config.Parse("yesterday\t10.0.0.1\t200")
+	if perr != nil {
+		t.Fatalf("lenient timestamp failed the record: %s", perr)
+	}
+	if record.Timestamp != 0 || record.Status != 200 {
+		t.Errorf("timestamp %d, status %d; want 0 and 200", record.Timestamp, record.Status)
+	}
+
+	schema.SetAllModes(Strict)
+	if _, perr := config.Parse("1642349408.581\tnot-an-ip\t200"); perr == nil || perr.Field != "c-ip" {
+		t.Errorf("bad c-ip with every field strict returned %v; want field_parse of c-ip", perr)
+	}
+}
+
+func TestParsePanic(t *testing.T) {
+	schema := newTestSchema(t)
+	schema.parsers[2] = func(*rtl.Record, string) error { panic("parser bug") }
+	config := newTestConfig(t, schema)
+	record, perr := config.Parse("1642349408.581\t10.0.0.1\t200")
+	if record != nil || perr == nil || perr.Reason != ReasonPanic || perr.Err != "parser bug" {
+		t.Errorf("Parse = %v, %+v; want a panic error", record, perr)
+	}
+}
+
+func TestDecodeHeaders(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		headers map[string]string
+		ok      bool
+	}{
+		{"empty", "", map[string]string{}, true},
+		{"simple", "Host:%20www.example.com%0AUser-Agent:%20curl/8.1.2%0A",
+			map[string]string{"host": "www.example.com", "user-agent": "curl/8.1.2"}, true},
+		{"mixed case", "Content-TYPE:%20text/html%0Asec-CH-ua-Mobile:%20?0",
+			map[string]string{"content-type": "text/html", "sec-ch-ua-mobile": "?0"}, true},
+		{"repeated", "Accept:%20text/html%0AX-Forwarded-For:%201.1.1.1%0AACCEPT:%20*/*",
+			map[string]string{"accept": "text/html,*/*", "x-forwarded-for": "1.1.1.1"}, true},
+		{"crlf and blank lines", "Host:%20a%0D%0A%0D%0AVia:%202.0%20b%0D%0A",
+			map[string]string{"host": "a", "via": "2.0 b"}, true},
+		{"colon in value", "Referer:%20https://www.example.com:8443/a+b",
+			map[string]string{"referer": "https://www.example.com:8443/a+b"}, true},
+		{"malformed escape", "Host:%20www.example.com%0AX-Bad:%20%zz", nil, false},
+		{"truncated escape", "Host:%20www.example.com%0", nil, false},
+		{"no colon", "Host%20www.example.com", nil, false},
+	}
+	for _, tt := range tests {
+		headers, err := decodeHeaders(tt.value)
+		if !tt.ok {
+			if err == nil {
+				t.Errorf("%s: decodeHeaders(%q) = %v; want an error", tt.name, tt.value, headers)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %s", tt.name, err)
+			continue
+		}
+		if len(headers) != len(tt.headers) {
+			t.Errorf("%s: headers %v; want %v", tt.name, headers, tt.headers)
+			continue
+		}
+		for name, value := range tt.headers {
+			if headers[name] != value {
+				t.Errorf("%s: header %s = %q; want %q", tt.name, name, headers[name], value)
+			}
+		}
+	}
+}
+
+func TestHeaderFieldsEmpty(t *testing.T) {
+	for _, value := range []string{"-", ""} {
+		record := &rtl.Record{}
+		if err := registry["cs-headers"](record, value); err != nil || record.Headers != nil {
+			t.Errorf("cs-headers %q = %v, %v; want no headers", value, record.Headers, err)
+		}
+		if err := registry["cs-header-names"](record, value); err != nil || record.HeaderNames != nil {
+			t.Errorf("cs-header-names %q = %v, %v; want no names", value, record.HeaderNames, err)
+		}
+	}
+
+	names, err := decodeHeaderNames("Host%0AUser-Agent%0ASec-CH-UA%0A")
+	if err != nil || len(names) != 3 || names[0] != "host" || names[1] != "user-agent" || names[2] != "sec-ch-ua" {
+		t.Errorf("decodeHeaderNames = %v, %v; want host, user-agent and sec-ch-ua", names, err)
+	}
+	if _, err := decodeHeaderNames("Host%0A%zz"); err == nil {
+		t.Error("decodeHeaderNames of a malformed escape succeeded")
+	}
+}
@@ -0,0 +1,65 @@
+package transform
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/sirupsen/logrus"
+)
+
+// Handler is the Firehose data transformation Lambda handler.
+func (config *Config) Handler(ctx context.Context, kinesisFirehoseEvent events.KinesisFirehoseEvent) (*events.KinesisFirehoseResponse, error) {
+	// Struct to hold the response
+	output := &events.KinesisFirehoseResponse{}
+
+	// Loop through each record from Kinesis Firehose.
+	// Every record must be returned, failed records included.
+	for _, record := range kinesisFirehoseEvent.Records {
+		output.Records = append(output.Records, config.Transform(record))
+	}
+
+	stats := config.uaCache.Stats()
+	config.log.WithFields(logrus.Fields{
+		"records":         len(kinesisFirehoseEvent.Records),
+		"ua_cache_hits":   stats.Hits,
+		"ua_cache_misses": stats.Misses,
+		"ua_cache_len":    stats.Len,
+	}).Info("batch processed")
+
+	// Return the response to Kinesis Firehose
+	return output, nil
+}
+
+// Transform converts a single Firehose record. Records that cannot be converted
+// are returned as ProcessingFailed so Firehose writes them to the error output prefix.
+func (config *Config) Transform(record events.KinesisFirehoseEventRecord) events.KinesisFirehoseResponseRecord {
+	data, perr := config.Parse(string(record.Data))
+	if perr != nil {
+		config.log.WithFields(logrus.Fields{
+			"error":     perr,
+			"record_id": record.RecordID,
+		}).Error("marshal failed")
+		return failed(record, perr)
+	}
+
+	// Convert to JSON
+	jsonData, err := json.Marshal(data)
+	if err != nil {
+		config.log.WithFields(logrus.Fields{
+			"error":     err,
+			"record_id": record.RecordID,
+		}).Error("json encode failed")
+		return failed(record, &ParseError{Reason: ReasonEncode, Err: err.Error()})
+	}
+
+	// Create the response
+	return events.KinesisFirehoseResponseRecord{
+		RecordID: record.RecordID,
+		Result:   events.KinesisFirehoseTransformedStateOk,
+		Data:     jsonData,
+		Metadata: events.KinesisFirehoseResponseRecordMetadata{
+			PartitionKeys: PartitionKeys(data),
+		},
+	}
+}
@@ -0,0 +1,130 @@
+package transform_test
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/rmrfslashbin/aws-cf-rtl/pkg/rtl"
+	"github.com/rmrfslashbin/aws-cf-rtl/pkg/transform"
+	"github.com/rmrfslashbin/aws-cf-rtl/pkg/transform/transformtest"
+	"github.com/sirupsen/logrus"
+)
+
+// exampleFields are the fields of the example line documented in the Lambda.
+var exampleFields = []string{
+	"1642349408.581",
+	"123.123.123.123",
+	"200",
+	"3536",
+	"GET",
+	"https",
+	"www.example.com",
+	"/news/today/",
+	"IAD89-P2",
+	"fv2T3ZdTRe4x0VV4Ro6YLWhfvD0LvfeKVRtJAXXWaev6SxFOPjhkjM==",
+	"d986b4ld3rmrlc.cloudfront.net",
+	"0.130",
+	"HTTP/1.1",
+	"IPv4",
+	"Mozilla/5.0%20(compatible;%20SemrushBot/7%7Ebl;%20+http://www.semrush.com/bot.html)",
+	"-",
+	"-",
+	"-",
+	"Miss",
+	"TLSv1.3",
+	"TLS_AES_128_GCM_SHA256",
+	"Miss",
+	"text/html",
+	"-",
+	"Miss",
+	"GB",
+	"*",
+}
+
+// exampleLine returns the example line with the timestamp replaced when ts is set.
+func exampleLine(ts string) string {
+	fields := append([]string(nil), exampleFields...)
+	if ts != "" {
+		fields[0] = ts
+	}
+	return strings.Join(fields, "\t")
+}
+
+func newTransform(t *testing.T) *transform.Config {
+	t.Helper()
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	config, err := transform.New(transform.SetLogger(log))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return config
+}
+
+func TestHandlerExample(t *testing.T) {
+	result := transformtest.Check(t, newTransform(t).Handler, exampleLine(""))
+	if result.Results[events.KinesisFirehoseTransformedStateOk] != 1 {
+		t.Fatalf("results %v; want one Ok", result.Results)
+	}
+	if result.Partitions["2022/1/16"] != 1 {
+		t.Errorf("partitions %v; want 2022/1/16", result.Partitions)
+	}
+
+	var record rtl.Record
+	if err := json.Unmarshal(result.Response.Records[0].Data, &record); err != nil {
+		t.Fatal(err)
+	}
+	if record.Timestamp != 1642349408581 || record.Status != 200 || record.Host != "www.example.com" || record.Country != "GB" {
+		t.Errorf("record %+v does not match the example line", record)
+	}
+	if !record.UserAgentIsBot || record.UserAgentBotName != "SemrushBot" {
+		t.Errorf("user-agent bot %v %q; want SemrushBot", record.UserAgentIsBot, record.UserAgentBotName)
+	}
+}
+
+func TestHandlerMalformed(t *testing.T) {
+	lines := []string{
+		exampleLine(""),
+		"1642349408.581\t123.123.123.123\t200",
+		exampleLine("yesterday"),
+	}
+	result := transformtest.Check(t, newTransform(t).Handler, lines...)
+	if result.Results[events.KinesisFirehoseTransformedStateOk] != 1 || result.Results[events.KinesisFirehoseTransformedStateProcessingFailed] != 2 {
+		t.Errorf("results %v; want one Ok and two ProcessingFailed", result.Results)
+	}
+	if result.Reasons[transform.ReasonFieldCount] != 1 || result.Reasons[transform.ReasonFieldParse] != 1 {
+		t.Errorf("reasons %v; want one field_count and one field_parse", result.Reasons)
+	}
+	// Records come back in event order
+	if got := result.Response.Records[1].Result; got != events.KinesisFirehoseTransformedStateProcessingFailed {
+		t.Errorf("short line is %s; want ProcessingFailed", got)
+	}
+}
+
+func TestHandlerPartitionsUTC(t *testing.T) {
+	// Partition keys must not follow the local zone of the Lambda
+	local := time.Local
+	time.Local = time.FixedZone("UTC-5", -5*60*60)
+	defer func() { time.Local = local }()
+
+	lines := []string{
+		exampleLine("1668470400.000"), // 2022-11-15 00:00:00 UTC
+		exampleLine("1668556799.999"), // 2022-11-15 23:59:59.999 UTC
+		exampleLine("1672531200.500"), // 2023-01-01 00:00:00.5 UTC
+	}
+	result := transformtest.Check(t, newTransform(t).Handler, lines...)
+	want := map[string]int{"2022/11/15": 2, "2023/1/1": 1}
+	if len(result.Partitions) != len(want) {
+		t.Errorf("partitions %v; want %v", result.Partitions, want)
+	}
+	for partition, n := range want {
+		if result.Partitions[partition] != n {
+			t.Errorf("partitions %v; want %v", result.Partitions, want)
+			break
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package transform
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// PartitionPath returns the year=/month=/day= directory of the partition keys,
+// as Firehose lays out its output.
+func PartitionPath(keys map[string]string) string {
+	return filepath.Join("year="+keys["year"], "month="+keys["month"], "day="+keys["day"])
+}
+
+// PartitionWriter writes records as JSON lines, either to a single writer or to
+// a file per partition under the year=/month=/day= layout of the Firehose output.
+type PartitionWriter struct {
+	dir   string
+	name  string
+	out   *bufio.Writer
+	files map[string]*partitionFile
+}
+
+// partitionFile is an open partition output file.
+type partitionFile struct {
+	f *os.File
+	w *bufio.Writer
+}
+
+// NewPartitionWriter writes to out when dir is empty, otherwise to
+// dir/year=/month=/day=/name, appending to files already there.
+func NewPartitionWriter(dir, name string, out io.Writer) *PartitionWriter {
+	w := &PartitionWriter{dir: dir, name: name, files: map[string]*partitionFile{}}
+	if dir == "" {
+		w.out = bufio.NewWriter(out)
+	}
+	return w
+}
+
+// Write writes a record to the partition of keys, as returned by PartitionKeys.
+func (w *PartitionWriter) Write(keys map[string]string, data []byte) error {
+	out := w.out
+	if out == nil {
+		partition := PartitionPath(keys)
+		pf := w.files[partition]
+		if pf == nil {
+			dir := filepath.Join(w.dir, partition)
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				return err
+			}
+			f, err := os.OpenFile(filepath.Join(dir, w.name), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+			if err != nil {
+				return err
+			}
+			pf = &partitionFile{f: f, w: bufio.NewWriter(f)}
+			w.files[partition] = pf
+		}
+		out = pf.w
+	}
+	if _, err := out.Write(data); err != nil {
+		return err
+	}
+	return out.WriteByte('\n')
+}
+
+// Close flushes the output and closes the partition files. It is safe to call twice.
+func (w *PartitionWriter) Close() error {
+	var first error
+	if w.out != nil {
+		first = w.out.Flush()
+	}
+	for partition, pf := range w.files {
+		if err := pf.w.Flush(); err != nil && first == nil {
+			first = err
+		}
+		if err := pf.f.Close(); err != nil && first == nil {
+			first = err
+		}
+		delete(w.files, partition)
+	}
+	return first
+}
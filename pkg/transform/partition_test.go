@@ -0,0 +1,124 @@
+package transform_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rmrfslashbin/aws-cf-rtl/pkg/rtl"
+	"github.com/rmrfslashbin/aws-cf-rtl/pkg/transform"
+)
+
+// writeRecords parses the lines and writes them with w.
+func writeRecords(t *testing.T, w *transform.PartitionWriter, lines ...string) {
+	t.Helper()
+	config := newTransform(t)
+	for _, line := range lines {
+		record, perr := config.Parse(line)
+		if perr != nil {
+			t.Fatal(perr)
+		}
+		data, err := json.Marshal(record)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Write(transform.PartitionKeys(record), data); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// readRecords reads the JSON lines of a partition file.
+func readRecords(t *testing.T, path string) []rtl.Record {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	records := []rtl.Record{}
+	for _, line := range strings.Split(strings.TrimSuffix(string(data), "\n"), "\n") {
+		var record rtl.Record
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("%s: %s", path, err)
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestPartitionWriterLayout(t *testing.T) {
+	dir := t.TempDir()
+	w := transform.NewPartitionWriter(dir, "backup.json", nil)
+	writeRecords(t, w,
+		exampleLine("1668470400.000"), // 2022-11-15
+		exampleLine("1668556799.999"), // 2022-11-15
+		exampleLine("1672531200.500"), // 2023-01-01
+	)
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Errorf("second Close: %s", err)
+	}
+
+	files := []string{}
+	err := filepath.WalkDir(dir, func(path string, entry os.DirEntry, err error) error {
+		if err == nil && !entry.IsDir() {
+			rel, _ := filepath.Rel(dir, path)
+			files = append(files, filepath.ToSlash(rel))
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"year=2022/month=11/day=15/backup.json", "year=2023/month=1/day=1/backup.json"}
+	if strings.Join(files, ",") != strings.Join(want, ",") {
+		t.Fatalf("files %v; want %v", files, want)
+	}
+
+	// Every record sits in the directory its partition keys name
+	for _, file := range files {
+		for _, record := range readRecords(t, filepath.Join(dir, file)) {
+			keys := transform.PartitionKeys(&record)
+			if got := filepath.ToSlash(filepath.Dir(file)); got != filepath.ToSlash(transform.PartitionPath(keys)) {
+				t.Errorf("record %d is in %s; its keys are %v", record.Timestamp, got, keys)
+			}
+		}
+	}
+	if n := len(readRecords(t, filepath.Join(dir, want[0]))); n != 2 {
+		t.Errorf("%s holds %d records; want 2", want[0], n)
+	}
+
+	// A second writer of the same name appends
+	w = transform.NewPartitionWriter(dir, "backup.json", nil)
+	writeRecords(t, w, exampleLine("1668470400.000"))
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(readRecords(t, filepath.Join(dir, want[0]))); n != 3 {
+		t.Errorf("%s holds %d records after appending; want 3", want[0], n)
+	}
+}
+
+func TestPartitionWriterStream(t *testing.T) {
+	var buf bytes.Buffer
+	w := transform.NewPartitionWriter("", "ignored.json", &buf)
+	writeRecords(t, w, exampleLine("1668470400.000"), exampleLine("1672531200.500"))
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(buf.String(), "\n"); lines != 2 {
+		t.Errorf("%d lines written; want 2", lines)
+	}
+}
+
+func TestPartitionPath(t *testing.T) {
+	keys := map[string]string{"year": "2022", "month": "1", "day": "5"}
+	if got := filepath.ToSlash(transform.PartitionPath(keys)); got != "year=2022/month=1/day=5" {
+		t.Errorf("PartitionPath = %s", got)
+	}
+}
@@ -0,0 +1,204 @@
+// Package transform converts Cloudfront real-time log lines into the enriched
+// rtl.Record, the parsing and enrichment the Lambda runs on every Firehose record.
+package transform
+
+import (
+	_ "embed"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rmrfslashbin/aws-cf-rtl/pkg/geoip"
+	"github.com/rmrfslashbin/aws-cf-rtl/pkg/rtl"
+	"github.com/rmrfslashbin/aws-cf-rtl/pkg/useragent"
+	"github.com/sirupsen/logrus"
+)
+
+// UAOverrides are the user-agent rules bundled with the Lambda. Pass them to
+// useragent.SetOverrides to check them ahead of the default definitions.
+//
+//go:embed ua-overrides.yaml
+var UAOverrides []byte
+
+// DefaultUACacheSize is the number of parsed user-agents kept unless SetUACache is given.
+const DefaultUACacheSize = 4096
+
+type Option func(config *Config)
+
+// Config converts log lines into records.
+type Config struct {
+	log     *logrus.Logger
+	schema  *Schema
+	uaCache *useragent.Cache
+	geo     *geoip.Reader
+}
+
+// New returns a transform for lines with the rtl.DefaultFields unless SetSchema is given.
+// Records are only enriched with GeoIP data when a reader is set with SetGeoIP.
+func New(opts ...Option) (*Config, error) {
+	cfg := &Config{}
+
+	// apply the list of options to Config
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	if cfg.log == nil {
+		cfg.log = logrus.New()
+	}
+	if cfg.schema == nil {
+		schema, err := NewSchema(rtl.DefaultFields)
+		if err != nil {
+			return nil, err
+		}
+		cfg.schema = schema
+	}
+	if cfg.uaCache == nil {
+		cfg.uaCache = useragent.NewCache(DefaultUACacheSize)
+	}
+	return cfg, nil
+}
+
+func SetLogger(log *logrus.Logger) Option {
+	return func(config *Config) {
+		config.log = log
+	}
+}
+
+// SetSchema sets the ordered fields of the log lines.
+func SetSchema(schema *Schema) Option {
+	return func(config *Config) {
+		config.schema = schema
+	}
+}
+
+// SetUACache sets the cache of parsed user-agents, which may be shared.
+func SetUACache(cache *useragent.Cache) Option {
+	return func(config *Config) {
+		config.uaCache = cache
+	}
+}
+
+// SetGeoIP enriches records with lookups of the client address.
+func SetGeoIP(reader *geoip.Reader) Option {
+	return func(config *Config) {
+		config.geo = reader
+	}
+}
+
+// Schema returns the field schema of the log lines.
+func (config *Config) Schema() *Schema {
+	return config.schema
+}
+
+// UACacheStats returns the user-agent cache counters.
+func (config *Config) UACacheStats() useragent.CacheStats {
+	return config.uaCache.Stats()
+}
+
+// Parse converts a tab separated log line into an enriched Record.
+// A trailing newline is ignored.
+func (config *Config) Parse(line string) (record *rtl.Record, perr *ParseError) {
+	// A bad line must never take down the caller
+	defer func() {
+		if r := recover(); r != nil {
+			record, perr = nil, &ParseError{Reason: ReasonPanic, Err: fmt.Sprint(r)}
+		}
+	}()
+
+	parts := strings.Split(strings.TrimRight(line, "\r\n"), "\t")
+	return config.marshal(parts)
+}
+
+// PartitionKeys returns the year, month and day partition keys of the record,
+// unpadded and in UTC, as Firehose dynamic partitioning writes them.
+func PartitionKeys(record *rtl.Record) map[string]string {
+	ts := time.UnixMilli(record.Timestamp).UTC()
+	return map[string]string{
+		"year":  strconv.Itoa(ts.Year()),
+		"month": strconv.Itoa(int(ts.Month())),
+		"day":   strconv.Itoa(ts.Day()),
+	}
+}
+
+// marshal the log fields into a Record
+func (config *Config) marshal(parts []string) (*rtl.Record, *ParseError) {
+	schema := config.schema
+	record := &rtl.Record{}
+
+	if len(parts) != len(schema.fields) {
+		return nil, &ParseError{
+			Reason: ReasonFieldCount,
+			Err:    fmt.Sprintf("expected %d fields, got %d", len(schema.fields), len(parts)),
+		}
+	}
+
+	for i, parser := range schema.parsers {
+		if err := parser(record, parts[i]); err != nil {
+			if schema.modes[i] == Strict {
+				return nil, &ParseError{
+					Reason: ReasonFieldParse,
+					Field:  schema.fields[i],
+					Value:  parts[i],
+					Err:    err.Error(),
+				}
+			}
+			config.log.WithFields(logrus.Fields{
+				"error": err,
+				"field": schema.fields[i],
+				"value": parts[i],
+			}).Warn("field parse failed")
+		}
+	}
+
+	// Add user agent parsing data
+	// Client hints are only available when cs-headers is logged
+	client := config.uaCache.ParseWithHints(record.UserAgent, useragent.ClientHintsFromHeaders(record.Headers))
+	record.UserAgentDeviceFamily = client.UADeviceFamily
+	record.UserAgentDeviceBrand = client.UADeviceBrand
+	record.UserAgentDeviceModel = client.UADeviceModel
+	record.UserAgentOSFamily = client.UAOSFamily
+	record.UserAgentOSMajor = client.UAOSMajor
+	record.UserAgentOSMinor = client.UAOSMinor
+	record.UserAgentOSPatch = client.UAOSPatch
+	record.UserAgentOSPatchMinor = client.UAOSPatchMinor
+	record.UserAgentFamily = client.UAFamily
+	record.UserAgentMajor = client.UAMajor
+	record.UserAgentMinor = client.UAMinor
+	record.UserAgentPatch = client.UAPatch
+	record.UserAgentIsBot = client.IsBot
+	record.UserAgentBotCategory = client.BotCategory
+	record.UserAgentBotName = client.BotName
+	record.UserAgentBotOperator = client.BotOperator
+	record.UserAgentMobile = client.UAMobile
+	record.UserAgentSource = client.UASource
+	record.UserAgentOSSource = client.UAOSSource
+	record.UserAgentDeviceSource = client.UADeviceSource
+
+	// Add GeoIP data
+	if config.geo != nil && record.ClientIP != nil {
+		if geo, err := config.geo.Lookup(record.ClientIP); err != nil {
+			config.log.WithFields(logrus.Fields{
+				"error":     err,
+				"client_ip": record.ClientIP,
+			}).Warn("geoip lookup failed")
+		} else {
+			record.GeoCity = geo.City
+			record.GeoSubdivision = geo.Subdivision
+			record.GeoPostalCode = geo.PostalCode
+			record.GeoLatitude = geo.Latitude
+			record.GeoLongitude = geo.Longitude
+			record.GeoTimeZone = geo.TimeZone
+			record.GeoMetroCode = geo.MetroCode
+			record.GeoASN = geo.ASN
+			record.GeoASOrganization = geo.ASOrganization
+			record.GeoISP = geo.ISP
+			record.GeoOrganization = geo.Organization
+			record.GeoConnectionType = geo.ConnectionType
+			record.GeoDatabaseBuild = geo.DatabaseBuild
+		}
+	}
+
+	return record, nil
+}
@@ -0,0 +1,94 @@
+package transform_test
+
+import (
+	"io"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/rmrfslashbin/aws-cf-rtl/pkg/rtl"
+	"github.com/rmrfslashbin/aws-cf-rtl/pkg/transform"
+	"github.com/sirupsen/logrus"
+)
+
+// newParser returns a transform for lines with the fields.
+func newParser(t *testing.T, fields ...string) *transform.Config {
+	t.Helper()
+	schema, err := transform.NewSchema(fields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	config, err := transform.New(transform.SetSchema(schema), transform.SetLogger(log))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return config
+}
+
+func TestNewSchema(t *testing.T) {
+	schema, err := transform.NewSchema([]string{" timestamp", "c-ip ", "sc-status"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(schema.Fields(), ","); got != "timestamp,c-ip,sc-status" {
+		t.Errorf("fields %s; want the names trimmed and in order", got)
+	}
+
+	if _, err := transform.NewSchema(rtl.DefaultFields); err != nil {
+		t.Errorf("default fields: %s", err)
+	}
+	for _, fields := range [][]string{
+		nil,
+		{"timestamp", "c-ip", "cs-bogus"},
+		{"timestamp", ""},
+	} {
+		if _, err := transform.NewSchema(fields); err == nil {
+			t.Errorf("NewSchema(%q) succeeded", fields)
+		}
+	}
+}
+
+func TestParseReordered(t *testing.T) {
+	config := newParser(t, "sc-status", "cs-host", "c-ip", "timestamp")
+	record, perr := config.Parse("404\twww.example.com\t10.0.0.1\t1642349408.581\n")
+	if perr != nil {
+		t.Fatal(perr)
+	}
+	if record.Status != 404 || record.Host != "www.example.com" || !record.ClientIP.Equal(net.ParseIP("10.0.0.1")) || record.Timestamp != 1642349408581 {
+		t.Errorf("record %+v does not match the reordered line", record)
+	}
+}
+
+func TestParseDropped(t *testing.T) {
+	// Fields left out of the configuration stay at their zero values
+	config := newParser(t, "timestamp", "c-ip", "cs-uri-stem")
+	record, perr := config.Parse("1642349408.581\t10.0.0.1\t/news/today/")
+	if perr != nil {
+		t.Fatal(perr)
+	}
+	if record.URIStem != "/news/today/" || record.Timestamp != 1642349408581 {
+		t.Errorf("record %+v does not match the line", record)
+	}
+	if record.Status != 0 || record.Host != "" || record.Bytes != 0 || record.Country != "" {
+		t.Errorf("fields not in the schema were set: %+v", record)
+	}
+}
+
+func TestParseFieldCount(t *testing.T) {
+	config := newParser(t, "timestamp", "c-ip", "sc-status")
+	for _, line := range []string{
+		"1642349408.581\t10.0.0.1",
+		"1642349408.581\t10.0.0.1\t200\textra",
+		"",
+	} {
+		record, perr := config.Parse(line)
+		if perr == nil || perr.Reason != transform.ReasonFieldCount || record != nil {
+			t.Errorf("Parse(%q) = %v, %v; want a field_count error", line, record, perr)
+		}
+	}
+	if _, perr := config.Parse("1642349408.581\t10.0.0.1\t200\r\n"); perr != nil {
+		t.Errorf("line ending in CRLF: %s", perr)
+	}
+}
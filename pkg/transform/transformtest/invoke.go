@@ -0,0 +1,241 @@
+// Package transformtest invokes a Firehose data transformation handler in-process,
+// with events built and encoded the way Firehose sends them, and checks the response
+// the way Firehose reads it.
+package transformtest
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/rmrfslashbin/aws-cf-rtl/pkg/rtl"
+	"github.com/rmrfslashbin/aws-cf-rtl/pkg/transform"
+)
+
+// DefaultStreamArn is the delivery stream the events claim to come from.
+const DefaultStreamArn = "arn:aws:firehose:us-east-1:123456789012:deliverystream/cf-rtl"
+
+// maxResponseSize is the largest response Firehose accepts from the Lambda.
+const maxResponseSize = 6 << 20
+
+// Handler is the signature of a Firehose data transformation Lambda handler,
+// such as transform.Config.Handler.
+type Handler func(ctx context.Context, event events.KinesisFirehoseEvent) (*events.KinesisFirehoseResponse, error)
+
+// Problem is a response that Firehose would reject or that breaks the record contract.
+type Problem struct {
+	RecordID string `json:"record_id,omitempty"`
+	Message  string `json:"message"`
+}
+
+func (p Problem) Error() string {
+	if p.RecordID == "" {
+		return p.Message
+	}
+	return fmt.Sprintf("%s: %s", p.RecordID, p.Message)
+}
+
+// Result is the outcome of an invocation.
+type Result struct {
+	// Event is the event as the handler received it, after the JSON round trip
+	Event events.KinesisFirehoseEvent `json:"-"`
+	// Response is the response as Firehose reads it, after the JSON round trip
+	Response *events.KinesisFirehoseResponse `json:"-"`
+	// Results counts the records by result state
+	Results map[string]int `json:"results"`
+	// Partitions counts the Ok records by year/month/day partition
+	Partitions map[string]int `json:"partitions"`
+	// Reasons counts the ProcessingFailed records by failure reason
+	Reasons  map[string]int `json:"reasons,omitempty"`
+	Problems []Problem      `json:"problems"`
+}
+
+// NewEvent builds a Firehose event with a record for each data, numbered the way
+// Firehose numbers record IDs. The data of a Cloudfront record is a log line
+// ending in a newline.
+func NewEvent(data [][]byte) events.KinesisFirehoseEvent {
+	now := time.Now()
+	event := events.KinesisFirehoseEvent{
+		InvocationID:      newUUID(),
+		DeliveryStreamArn: DefaultStreamArn,
+		Region:            "us-east-1",
+		Records:           make([]events.KinesisFirehoseEventRecord, len(data)),
+	}
+	// Firehose record IDs are the shard sequence number with an index appended
+	base := strconv.FormatInt(now.UnixNano(), 10)
+	for i, d := range data {
+		event.Records[i] = events.KinesisFirehoseEventRecord{
+			RecordID:                    fmt.Sprintf("4955%s%030d", base, i),
+			ApproximateArrivalTimestamp: events.MilliSecondsEpochTime{Time: now},
+			Data:                        d,
+		}
+	}
+	return event
+}
+
+// newUUID returns a random version 4 UUID, as Firehose uses for invocation IDs.
+func newUUID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// EventFromLines builds a Firehose event with a record for each log line.
+func EventFromLines(lines ...string) events.KinesisFirehoseEvent {
+	data := make([][]byte, len(lines))
+	for i, line := range lines {
+		data[i] = []byte(strings.TrimRight(line, "\n") + "\n")
+	}
+	return NewEvent(data)
+}
+
+// Invoke encodes event to JSON as the Lambda runtime receives it, with the
+// record data base64 encoded, decodes it and calls handler. The response is
+// encoded and decoded again, as Firehose reads it, then checked with Validate
+// and against the Firehose response size limit.
+// An error is returned when the handler fails or the JSON does not round trip.
+func Invoke(ctx context.Context, handler Handler, event events.KinesisFirehoseEvent) (*Result, error) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return nil, err
+	}
+	result := &Result{}
+	if err := json.Unmarshal(payload, &result.Event); err != nil {
+		return nil, fmt.Errorf("event: %w", err)
+	}
+
+	response, err := handler(ctx, result.Event)
+	if err != nil {
+		return nil, fmt.Errorf("handler: %w", err)
+	}
+	if response == nil {
+		return nil, fmt.Errorf("handler returned no response")
+	}
+	payload, err = json.Marshal(response)
+	if err != nil {
+		return nil, fmt.Errorf("response: %w", err)
+	}
+	result.Response = &events.KinesisFirehoseResponse{}
+	if err := json.Unmarshal(payload, result.Response); err != nil {
+		return nil, fmt.Errorf("response: %w", err)
+	}
+
+	result.Results = map[string]int{}
+	result.Partitions = map[string]int{}
+	for _, record := range result.Response.Records {
+		result.Results[record.Result]++
+		switch record.Result {
+		case events.KinesisFirehoseTransformedStateOk:
+			keys := record.Metadata.PartitionKeys
+			result.Partitions[keys["year"]+"/"+keys["month"]+"/"+keys["day"]]++
+		case events.KinesisFirehoseTransformedStateProcessingFailed:
+			var failed transform.FailedRecord
+			if json.Unmarshal(record.Data, &failed) == nil && failed.ParseError != nil {
+				if result.Reasons == nil {
+					result.Reasons = map[string]int{}
+				}
+				result.Reasons[failed.Reason]++
+			}
+		}
+	}
+	result.Problems = Validate(result.Event, result.Response)
+	if len(payload) > maxResponseSize {
+		result.Problems = append(result.Problems, Problem{
+			Message: fmt.Sprintf("response is %d bytes, over the %d byte limit", len(payload), maxResponseSize),
+		})
+	}
+	return result, nil
+}
+
+// Validate checks a response against its event:
+//   - every record is returned exactly once, and no others
+//   - every result is Ok, Dropped or ProcessingFailed
+//   - Ok data is a record whose partition keys match its timestamp
+//   - ProcessingFailed data carries a reason and the original data
+func Validate(event events.KinesisFirehoseEvent, response *events.KinesisFirehoseResponse) []Problem {
+	problems := []Problem{}
+	sent := make(map[string][]byte, len(event.Records))
+	for _, record := range event.Records {
+		sent[record.RecordID] = record.Data
+	}
+
+	seen := make(map[string]bool, len(response.Records))
+	for _, record := range response.Records {
+		id := record.RecordID
+		problem := func(format string, args ...interface{}) {
+			problems = append(problems, Problem{RecordID: id, Message: fmt.Sprintf(format, args...)})
+		}
+
+		data, ok := sent[id]
+		switch {
+		case !ok:
+			problem("record was not in the event")
+			continue
+		case seen[id]:
+			problem("record returned more than once")
+			continue
+		}
+		seen[id] = true
+
+		switch record.Result {
+		case events.KinesisFirehoseTransformedStateOk:
+			var out rtl.Record
+			if err := json.Unmarshal(record.Data, &out); err != nil {
+				problem("data is not a record: %s", err)
+				continue
+			}
+			want := transform.PartitionKeys(&out)
+			for key, value := range want {
+				if got := record.Metadata.PartitionKeys[key]; got != value {
+					problem("partition key %s is %q, want %q", key, got, value)
+				}
+			}
+			for key := range record.Metadata.PartitionKeys {
+				if _, ok := want[key]; !ok {
+					problem("unexpected partition key %s", key)
+				}
+			}
+		case events.KinesisFirehoseTransformedStateProcessingFailed:
+			var failed transform.FailedRecord
+			if err := json.Unmarshal(record.Data, &failed); err != nil || failed.ParseError == nil || failed.Reason == "" {
+				problem("failed record carries no reason")
+				continue
+			}
+			if failed.RawData != string(data) {
+				problem("failed record does not carry the original data")
+			}
+		case events.KinesisFirehoseTransformedStateDropped:
+		default:
+			problem("unknown result %q", record.Result)
+		}
+	}
+
+	for _, record := range event.Records {
+		if !seen[record.RecordID] {
+			problems = append(problems, Problem{RecordID: record.RecordID, Message: "record was not returned"})
+		}
+	}
+	return problems
+}
+
+// Check invokes handler with the log lines and reports any error or problem as
+// a test failure. States of the records are not judged; check Result.Results for that.
+func Check(t testing.TB, handler Handler, lines ...string) *Result {
+	t.Helper()
+	result, err := Invoke(context.Background(), handler, EventFromLines(lines...))
+	if err != nil {
+		t.Fatalf("invoke: %s", err)
+	}
+	for _, problem := range result.Problems {
+		t.Errorf("%s", problem)
+	}
+	return result
+}
@@ -0,0 +1,114 @@
+package useragent
+
+import (
+	"container/list"
+	"sync"
+	"sync/atomic"
+)
+
+// Cache is a bounded, concurrency-safe LRU cache of parsed user-agent strings.
+type Cache struct {
+	mu    sync.Mutex
+	size  int
+	ll    *list.List
+	items map[string]*list.Element
+
+	hits   uint64
+	misses uint64
+}
+
+// CacheStats reports the cache counters.
+type CacheStats struct {
+	Hits   uint64 `json:"hits"`
+	Misses uint64 `json:"misses"`
+	Len    int    `json:"len"`
+	Size   int    `json:"size"`
+}
+
+type cacheEntry struct {
+	key        string
+	generation uint64
+	record     Record
+}
+
+// NewCache returns a cache holding at most size parsed user-agents.
+func NewCache(size int) *Cache {
+	if size < 1 {
+		size = 1
+	}
+	return &Cache{
+		size:  size,
+		ll:    list.New(),
+		items: make(map[string]*list.Element, size),
+	}
+}
+
+// Parse returns the parsed user-agent, consulting the cache first.
+// Entries parsed before the definitions were reloaded are treated as misses.
+func (c *Cache) Parse(line string) *Record {
+	return c.get(line, func() *Record { return Parse(line) })
+}
+
+// ParseWithHints returns the user-agent merged with its client hints, consulting the cache first.
+func (c *Cache) ParseWithHints(line string, hints ClientHints) *Record {
+	if hints.IsZero() {
+		return c.Parse(line)
+	}
+	return c.get(line+"\x00"+hints.key(), func() *Record { return ParseWithHints(line, hints) })
+}
+
+// get returns the cached record for key, calling parse on a miss.
+func (c *Cache) get(key string, parse func() *Record) *Record {
+	gen := generation.Load()
+
+	c.mu.Lock()
+	if elem, ok := c.items[key]; ok && elem.Value.(*cacheEntry).generation == gen {
+		c.ll.MoveToFront(elem)
+		record := elem.Value.(*cacheEntry).record
+		c.mu.Unlock()
+		atomic.AddUint64(&c.hits, 1)
+		return &record
+	}
+	c.mu.Unlock()
+	atomic.AddUint64(&c.misses, 1)
+
+	// Parse outside the lock; it is the expensive part.
+	record := parse()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.items[key]; ok {
+		// Refresh a stale entry, or one another goroutine added meanwhile.
+		elem.Value.(*cacheEntry).generation = gen
+		elem.Value.(*cacheEntry).record = *record
+		c.ll.MoveToFront(elem)
+	} else {
+		c.items[key] = c.ll.PushFront(&cacheEntry{key: key, generation: gen, record: *record})
+		if c.ll.Len() > c.size {
+			oldest := c.ll.Back()
+			c.ll.Remove(oldest)
+			delete(c.items, oldest.Value.(*cacheEntry).key)
+		}
+	}
+	return record
+}
+
+// Purge removes all entries from the cache. The counters are kept.
+func (c *Cache) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ll.Init()
+	c.items = make(map[string]*list.Element, c.size)
+}
+
+// Stats returns the current cache counters.
+func (c *Cache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return CacheStats{
+		Hits:   atomic.LoadUint64(&c.hits),
+		Misses: atomic.LoadUint64(&c.misses),
+		Len:    c.ll.Len(),
+		Size:   c.size,
+	}
+}
@@ -0,0 +1,97 @@
+package useragent
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// agent returns a distinct Chrome user-agent for n.
+func agent(n int) string {
+	return fmt.Sprintf("Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/%d.0.0.0 Safari/537.36", 100+n)
+}
+
+func TestCacheHit(t *testing.T) {
+	cache := NewCache(10)
+	first := cache.Parse(agent(1))
+	second := cache.Parse(agent(1))
+	if !reflect.DeepEqual(first, second) || !reflect.DeepEqual(first, Parse(agent(1))) {
+		t.Errorf("cached %+v differs from the first parse %+v", second, first)
+	}
+	if stats := cache.Stats(); stats.Hits != 1 || stats.Misses != 1 || stats.Len != 1 {
+		t.Errorf("stats %+v; want one hit, one miss and one entry", stats)
+	}
+
+	// Callers get a copy, so changing one leaves the cache alone
+	second.UAFamily = "Changed"
+	if third := cache.Parse(agent(1)); third.UAFamily != "Chrome" {
+		t.Errorf("cached family %s after a caller changed its copy", third.UAFamily)
+	}
+
+	// Client hints are part of the key
+	hinted := cache.ParseWithHints(agent(1), ClientHints{Mobile: "?1"})
+	if !hinted.UAMobile {
+		t.Error("hints ignored for a user-agent cached without them")
+	}
+	if stats := cache.Stats(); stats.Len != 2 {
+		t.Errorf("%d entries; want 2 with and without hints", stats.Len)
+	}
+}
+
+func TestCacheReload(t *testing.T) {
+	path := writeOverride(t, filepath.Join(t.TempDir(), "overrides.yaml"), "Before")
+	config := load(t, SetOverridesFile(path))
+	cache := NewCache(10)
+	cache.Parse(agent(1))
+	cache.Parse(agent(2))
+
+	gen := generation.Load()
+	writeOverride(t, path, "After")
+	if err := config.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if generation.Load() != gen+1 {
+		t.Fatalf("generation %d after Reload; want %d", generation.Load(), gen+1)
+	}
+
+	// Entries from before the reload are misses, refreshed in place
+	if family := cache.Parse(agent(1)).UAFamily; family != "After" {
+		t.Errorf("family %s after Reload; want After", family)
+	}
+	if stats := cache.Stats(); stats.Hits != 0 || stats.Misses != 3 || stats.Len != 2 {
+		t.Errorf("stats %+v; want no hits, three misses and two entries", stats)
+	}
+	if cache.Parse(agent(1)); cache.Stats().Hits != 1 {
+		t.Error("refreshed entry was not a hit")
+	}
+
+	cache.Purge()
+	if stats := cache.Stats(); stats.Len != 0 || stats.Misses != 3 {
+		t.Errorf("stats %+v after Purge; want no entries and the counters kept", stats)
+	}
+}
+
+func TestCacheEviction(t *testing.T) {
+	cache := NewCache(2)
+	cache.Parse(agent(1))
+	cache.Parse(agent(2))
+	cache.Parse(agent(1)) // 1 is now the most recently used
+	cache.Parse(agent(3)) // evicts 2
+	if stats := cache.Stats(); stats.Len != 2 || stats.Size != 2 || stats.Hits != 1 || stats.Misses != 3 {
+		t.Fatalf("stats %+v; want two entries, one hit and three misses", stats)
+	}
+
+	cache.Parse(agent(1))
+	if stats := cache.Stats(); stats.Hits != 2 {
+		t.Errorf("recently used entry was evicted: %+v", stats)
+	}
+	cache.Parse(agent(2))
+	if stats := cache.Stats(); stats.Misses != 4 || stats.Len != 2 {
+		t.Errorf("least recently used entry was kept: %+v", stats)
+	}
+
+	if size := NewCache(0).Stats().Size; size != 1 {
+		t.Errorf("NewCache(0) holds %d; want 1", size)
+	}
+}
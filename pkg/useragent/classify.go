@@ -0,0 +1,167 @@
+package useragent
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Categories of automated traffic.
+const (
+	CategoryCrawler    = "crawler"
+	CategoryBot        = "bot"
+	CategoryMonitoring = "monitoring"
+	CategoryHeadless   = "headless"
+	CategoryLibrary    = "library"
+)
+
+// Classification describes automated traffic identified from a user-agent.
+type Classification struct {
+	IsBot    bool   `json:"is_bot"`
+	Category string `json:"bot_category"`
+	Name     string `json:"bot_name"`
+	Operator string `json:"bot_operator"`
+}
+
+// botRule matches a known automated client.
+type botRule struct {
+	re       *regexp.Regexp
+	name     string
+	category string
+	operator string
+}
+
+// botRules are checked in order; the first match wins.
+// Specific rules come before libraries since many bots embed a library token.
+var botRules = []botRule{
+	// Search engines and other crawlers
+	rule(`Googlebot|Google-InspectionTool|Storebot-Google|AdsBot-Google`, "Googlebot", CategoryCrawler, "Google"),
+	rule(`bingbot|BingPreview|msnbot`, "Bingbot", CategoryCrawler, "Microsoft"),
+	rule(`Yahoo! Slurp`, "Slurp", CategoryCrawler, "Yahoo"),
+	rule(`DuckDuckBot`, "DuckDuckBot", CategoryCrawler, "DuckDuckGo"),
+	rule(`Baiduspider`, "Baiduspider", CategoryCrawler, "Baidu"),
+	rule(`YandexBot|YandexImages|YandexMobileBot`, "YandexBot", CategoryCrawler, "Yandex"),
+	rule(`Applebot`, "Applebot", CategoryCrawler, "Apple"),
+	rule(`SemrushBot`, "SemrushBot", CategoryCrawler, "Semrush"),
+	rule(`AhrefsBot|AhrefsSiteAudit`, "AhrefsBot", CategoryCrawler, "Ahrefs"),
+	rule(`MJ12bot`, "MJ12bot", CategoryCrawler, "Majestic"),
+	rule(`DotBot`, "DotBot", CategoryCrawler, "Moz"),
+	rule(`PetalBot`, "PetalBot", CategoryCrawler, "Huawei"),
+	rule(`SeznamBot`, "SeznamBot", CategoryCrawler, "Seznam"),
+	rule(`Sogou (?:web|inst) spider`, "Sogou", CategoryCrawler, "Sogou"),
+	rule(`Bytespider`, "Bytespider", CategoryCrawler, "ByteDance"),
+	rule(`GPTBot|ChatGPT-User|OAI-SearchBot`, "GPTBot", CategoryCrawler, "OpenAI"),
+	rule(`ClaudeBot|Claude-Web|anthropic-ai`, "ClaudeBot", CategoryCrawler, "Anthropic"),
+	rule(`PerplexityBot`, "PerplexityBot", CategoryCrawler, "Perplexity"),
+	rule(`CCBot`, "CCBot", CategoryCrawler, "Common Crawl"),
+	rule(`archive\.org_bot|ia_archiver`, "Internet Archive", CategoryCrawler, "Internet Archive"),
+	rule(`Scrapy`, "Scrapy", CategoryCrawler, ""),
+
+	// Link previews and social bots
+	rule(`facebookexternalhit|facebookcatalog|meta-externalagent`, "facebookexternalhit", CategoryBot, "Meta"),
+	rule(`Twitterbot`, "Twitterbot", CategoryBot, "X"),
+	rule(`LinkedInBot`, "LinkedInBot", CategoryBot, "LinkedIn"),
+	rule(`Slackbot|Slack-ImgProxy`, "Slackbot", CategoryBot, "Slack"),
+	rule(`Discordbot`, "Discordbot", CategoryBot, "Discord"),
+	rule(`WhatsApp`, "WhatsApp", CategoryBot, "Meta"),
+	rule(`TelegramBot`, "TelegramBot", CategoryBot, "Telegram"),
+	rule(`Pinterestbot`, "Pinterestbot", CategoryBot, "Pinterest"),
+
+	// Monitoring probes
+	rule(`UptimeRobot`, "UptimeRobot", CategoryMonitoring, "UptimeRobot"),
+	rule(`Pingdom`, "Pingdom", CategoryMonitoring, "SolarWinds"),
+	rule(`StatusCake`, "StatusCake", CategoryMonitoring, "StatusCake"),
+	rule(`Site24x7`, "Site24x7", CategoryMonitoring, "Zoho"),
+	rule(`DatadogSynthetics|Datadog Agent`, "Datadog", CategoryMonitoring, "Datadog"),
+	rule(`NewRelicPinger|NewRelicSynthetics`, "New Relic", CategoryMonitoring, "New Relic"),
+	rule(`ELB-HealthChecker`, "ELB-HealthChecker", CategoryMonitoring, "Amazon"),
+	rule(`Amazon-Route53-Health-Check-Service`, "Route53-Health-Check", CategoryMonitoring, "Amazon"),
+	rule(`CloudWatchSynthetics`, "CloudWatchSynthetics", CategoryMonitoring, "Amazon"),
+	rule(`GoogleStackdriverMonitoring|GoogleHC`, "Google Cloud Monitoring", CategoryMonitoring, "Google"),
+	rule(`Better ?Uptime`, "Better Uptime", CategoryMonitoring, "Better Stack"),
+	rule(`Checkly`, "Checkly", CategoryMonitoring, "Checkly"),
+
+	// Headless browsers
+	rule(`HeadlessChrome`, "HeadlessChrome", CategoryHeadless, ""),
+	rule(`PhantomJS`, "PhantomJS", CategoryHeadless, ""),
+	rule(`Puppeteer`, "Puppeteer", CategoryHeadless, ""),
+	rule(`Playwright`, "Playwright", CategoryHeadless, ""),
+	rule(`Selenium|webdriver`, "Selenium", CategoryHeadless, ""),
+
+	// HTTP libraries and command line tools
+	rule(`^curl/`, "curl", CategoryLibrary, ""),
+	rule(`^Wget/`, "Wget", CategoryLibrary, ""),
+	rule(`Go-http-client`, "Go-http-client", CategoryLibrary, ""),
+	rule(`python-requests`, "python-requests", CategoryLibrary, ""),
+	rule(`Python-urllib`, "Python-urllib", CategoryLibrary, ""),
+	rule(`python-httpx`, "python-httpx", CategoryLibrary, ""),
+	rule(`aiohttp`, "aiohttp", CategoryLibrary, ""),
+	rule(`okhttp`, "okhttp", CategoryLibrary, ""),
+	rule(`Apache-HttpClient`, "Apache-HttpClient", CategoryLibrary, ""),
+	rule(`^Java/`, "Java", CategoryLibrary, ""),
+	rule(`libwww-perl`, "libwww-perl", CategoryLibrary, ""),
+	rule(`axios/`, "axios", CategoryLibrary, ""),
+	rule(`node-fetch|undici`, "node-fetch", CategoryLibrary, ""),
+	rule(`^Ruby|Faraday`, "Ruby", CategoryLibrary, ""),
+	rule(`PostmanRuntime`, "PostmanRuntime", CategoryLibrary, "Postman"),
+	rule(`HTTPie`, "HTTPie", CategoryLibrary, ""),
+	rule(`aws-sdk-|Boto3|botocore`, "AWS SDK", CategoryLibrary, "Amazon"),
+}
+
+// genericBots catch self-identified bots that have no specific rule: a product
+// token naming a bot, followed by its version or contact URL, as in
+// "(compatible; ExampleBot/1.0; +https://example.com/bot)", or bot, crawler or
+// spider as a word on its own. Device names such as "CUBOT X30" match neither.
+var genericBots = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)(?:^|[\s;(])([\w.-]*(?:bot|crawler|spider|crawl)[\w.-]*)(?:/|;\s*\+?https?:|\s*\(\+?https?:)`),
+	regexp.MustCompile(`(?i)\b(bot|crawler|spider)\b`),
+}
+
+// rule compiles a case-insensitive botRule.
+func rule(pattern, name, category, operator string) botRule {
+	return botRule{
+		re:       regexp.MustCompile(`(?i)` + pattern),
+		name:     name,
+		category: category,
+		operator: operator,
+	}
+}
+
+// Classify flags automated traffic from a raw or percent-encoded user-agent string.
+func Classify(line string) Classification {
+	ua := line
+	if strings.Contains(ua, "%") {
+		if decoded, err := url.PathUnescape(ua); err == nil {
+			ua = decoded
+		}
+	}
+	ua = strings.TrimSpace(ua)
+
+	// An empty user-agent says nothing about the client; leave it unclassified
+	// rather than count it as a bot.
+	if ua == "" || ua == "-" {
+		return Classification{}
+	}
+
+	for _, r := range botRules {
+		if r.re.MatchString(ua) {
+			return Classification{
+				IsBot:    true,
+				Category: r.category,
+				Name:     r.name,
+				Operator: r.operator,
+			}
+		}
+	}
+
+	for _, re := range genericBots {
+		if m := re.FindStringSubmatch(ua); m != nil {
+			return Classification{
+				IsBot:    true,
+				Category: CategoryBot,
+				Name:     m[1],
+			}
+		}
+	}
+	return Classification{}
+}
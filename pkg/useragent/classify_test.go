@@ -0,0 +1,55 @@
+package useragent
+
+import "testing"
+
+func TestClassifyBots(t *testing.T) {
+	tests := []struct {
+		ua       string
+		name     string
+		category string
+	}{
+		{"Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)", "Googlebot", CategoryCrawler},
+		{"Mozilla/5.0 (compatible; bingbot/2.0; +http://www.bing.com/bingbot.htm)", "Bingbot", CategoryCrawler},
+		{"Mozilla/5.0 (compatible; ExampleBot/1.0; +https://example.com/bot)", "ExampleBot", CategoryBot},
+		{"Mozilla/5.0 (compatible; Example-Spider; +https://example.com/spider)", "Example-Spider", CategoryBot},
+		{"SiteCrawler/2.1 (+https://example.com)", "SiteCrawler", CategoryBot},
+		{"Mozilla/5.0 (compatible; web crawler)", "crawler", CategoryBot},
+		{"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) HeadlessChrome/127.0.0.0 Safari/537.36", "HeadlessChrome", CategoryHeadless},
+		{"Sogou web spider/4.0(+http://www.sogou.com/docs/help/webmasters.htm#07)", "Sogou", CategoryCrawler},
+		{"Sogou inst spider/4.0(+http://www.sogou.com/docs/help/webmasters.htm#07)", "Sogou", CategoryCrawler},
+		{"curl/8.1.2", "curl", CategoryLibrary},
+		{"python-requests/2.31.0", "python-requests", CategoryLibrary},
+		{"Mozilla/5.0%20(compatible;%20Googlebot/2.1;%20+http://www.google.com/bot.html)", "Googlebot", CategoryCrawler},
+	}
+	for _, tt := range tests {
+		got := Classify(tt.ua)
+		if !got.IsBot || got.Name != tt.name || got.Category != tt.category {
+			t.Errorf("Classify(%q) = %+v; want %s %s", tt.ua, got, tt.category, tt.name)
+		}
+	}
+}
+
+func TestClassifyBrowsers(t *testing.T) {
+	for _, ua := range []string{
+		"Mozilla/5.0 (Linux; Android 10; CUBOT X30) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/118.0.0.0 Mobile Safari/537.36",
+		"Mozilla/5.0 (Linux; Android 11; CUBOT KINGKONG 5 Pro Build/RP1A.200720.011) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/116.0.0.0 Mobile Safari/537.36",
+		"Mozilla/5.0 (Linux; Android 9; CUBOT_P30) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/110.0.0.0 Mobile Safari/537.36",
+		"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/127.0.0.0 Safari/537.36",
+		"Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.0 Mobile/15E148 Safari/604.1",
+		"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.0 Safari/605.1.15",
+		"Mozilla/5.0 (Windows NT 10.0; WOW64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/58.0.3029.110 Safari/537.36 SE 2.X MetaSr 1.0",
+		"Mozilla/5.0 (Linux; Android 10; SM-G9750) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/86.0.4240.198 Mobile Safari/537.36 SogouMobileBrowser/5.28.11",
+	} {
+		if got := Classify(ua); got.IsBot {
+			t.Errorf("Classify(%q) = %+v; want a browser", ua, got)
+		}
+	}
+}
+
+func TestClassifyEmpty(t *testing.T) {
+	for _, ua := range []string{"", "-", "  ", "%20"} {
+		if got := Classify(ua); got != (Classification{}) {
+			t.Errorf("Classify(%q) = %+v; want unclassified", ua, got)
+		}
+	}
+}
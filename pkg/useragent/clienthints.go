@@ -0,0 +1,350 @@
+package useragent
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Sources a parsed field can come from.
+const (
+	SourceUserAgent   = "user-agent"
+	SourceClientHints = "client-hints"
+)
+
+// ClientHints holds the raw User-Agent Client Hints request headers.
+type ClientHints struct {
+	UA              string // Sec-CH-UA
+	FullVersionList string // Sec-CH-UA-Full-Version-List
+	Platform        string // Sec-CH-UA-Platform
+	PlatformVersion string // Sec-CH-UA-Platform-Version
+	Model           string // Sec-CH-UA-Model
+	Mobile          string // Sec-CH-UA-Mobile
+}
+
+// ClientHintsFromHeaders picks the client hints out of request headers keyed on the
+// lower-cased header name, such as the decoded Cloudfront cs-headers field.
+func ClientHintsFromHeaders(headers map[string]string) ClientHints {
+	return ClientHints{
+		UA:              headers["sec-ch-ua"],
+		FullVersionList: headers["sec-ch-ua-full-version-list"],
+		Platform:        headers["sec-ch-ua-platform"],
+		PlatformVersion: headers["sec-ch-ua-platform-version"],
+		Model:           headers["sec-ch-ua-model"],
+		Mobile:          headers["sec-ch-ua-mobile"],
+	}
+}
+
+// IsZero reports whether no client hints were sent.
+func (hints ClientHints) IsZero() bool {
+	return hints == ClientHints{}
+}
+
+// key identifies the hints in a Cache.
+func (hints ClientHints) key() string {
+	return strings.Join([]string{
+		hints.UA,
+		hints.FullVersionList,
+		hints.Platform,
+		hints.PlatformVersion,
+		hints.Model,
+		hints.Mobile,
+	}, "\x00")
+}
+
+// brandFamilies maps client hint brands to the uap-core family names.
+var brandFamilies = map[string]string{
+	"Google Chrome":    "Chrome",
+	"Microsoft Edge":   "Edge",
+	"Opera":            "Opera",
+	"Brave":            "Brave",
+	"Vivaldi":          "Vivaldi",
+	"Yandex":           "Yandex Browser",
+	"Samsung Internet": "Samsung Internet",
+	"Chromium":         "Chromium",
+}
+
+// platformFamilies maps client hint platforms to the uap-core OS family names.
+var platformFamilies = map[string]string{
+	"Windows":     "Windows",
+	"macOS":       "Mac OS X",
+	"Android":     "Android",
+	"iOS":         "iOS",
+	"Chrome OS":   "Chrome OS",
+	"Chromium OS": "Chrome OS",
+	"Linux":       "Linux",
+}
+
+// ParseWithHints parses the user-agent string and merges in the client hints,
+// which win wherever they are more specific. The Source fields report which won.
+func ParseWithHints(line string, hints ClientHints) *Record {
+	record := Parse(line)
+	mergeHints(record, hints)
+	return record
+}
+
+// mergeHints overlays the client hints onto a record parsed from the user-agent string.
+func mergeHints(record *Record, hints ClientHints) {
+	record.UASource = SourceUserAgent
+	record.UAOSSource = SourceUserAgent
+	record.UADeviceSource = SourceUserAgent
+
+	// Browser family and version
+	list := hints.FullVersionList
+	if list == "" {
+		list = hints.UA
+	}
+	if brand, version, ok := pickBrand(list); ok {
+		family := brandFamilies[brand]
+		if family == "" {
+			family = brand
+		}
+		major, minor, patch := splitVersion(version)
+		// Keep a more specific family from the user-agent, e.g. "Chrome Mobile" over "Chrome"
+		sameFamily := strings.HasPrefix(record.UAFamily, family)
+		if !sameFamily || major != record.UAMajor || hints.FullVersionList != "" {
+			if !sameFamily {
+				record.UAFamily = family
+			}
+			record.UAMajor = major
+			record.UAMinor = minor
+			record.UAPatch = patch
+			record.UASource = SourceClientHints
+		}
+	}
+
+	// Operating system
+	if platform := unquote(hints.Platform); platform != "" {
+		family := platformFamilies[platform]
+		if family == "" {
+			family = platform
+		}
+		major, minor, patch := splitVersion(unquote(hints.PlatformVersion))
+		if family == "Windows" && major != "" {
+			major, minor, patch = windowsVersion(major), "", ""
+		}
+		if family != record.UAOSFamily || (major != "" && major != record.UAOSMajor) {
+			record.UAOSFamily = family
+			record.UAOSMajor = major
+			record.UAOSMinor = minor
+			record.UAOSPatch = patch
+			record.UAOSPatchMinor = ""
+			record.UAOSSource = SourceClientHints
+		}
+	}
+
+	// Device
+	if mobile, ok := parseBoolean(hints.Mobile); ok {
+		record.UAMobile = mobile
+	}
+	if model := unquote(hints.Model); model != "" && model != record.UADeviceModel {
+		record.UADeviceFamily = model
+		record.UADeviceModel = model
+		record.UADeviceSource = SourceClientHints
+	}
+}
+
+// brand is an entry of a Sec-CH-UA style brand list.
+type brand struct {
+	name    string
+	version string
+}
+
+// pickBrand picks the most specific brand from a Sec-CH-UA style brand list,
+// e.g. `"Chromium";v="110", "Not A(Brand";v="24", "Google Chrome";v="110"`.
+// GREASE brands are skipped. A known browser wins over an unknown brand, and
+// Chromium only wins when nothing else is listed.
+func pickBrand(list string) (string, string, bool) {
+	var best brand
+	bestRank := 0
+	for _, b := range parseBrandList(list) {
+		if b.name == "" || isGrease(b.name) {
+			continue
+		}
+		rank := 2
+		switch family, known := brandFamilies[b.name]; {
+		case family == "Chromium":
+			rank = 1
+		case known:
+			rank = 3
+		}
+		if rank > bestRank {
+			best, bestRank = b, rank
+		}
+	}
+	return best.name, best.version, bestRank > 0
+}
+
+// parseBrandList parses a brand list as an RFC 8941 structured field list of
+// strings with parameters, keeping the v parameter of each. Commas, semicolons
+// and equals signs inside quoted strings, as in GREASE brands, are part of the
+// string. A malformed member is skipped up to the next comma.
+func parseBrandList(list string) []brand {
+	var brands []brand
+	p := &sfParser{s: list}
+	for {
+		p.skipSpace()
+		if p.done() {
+			return brands
+		}
+		b := brand{}
+		name, ok := p.bareItem()
+		for ok && p.peek() == ';' {
+			p.i++
+			p.skipSpace()
+			key := p.key()
+			value := "?1"
+			if p.peek() == '=' {
+				p.i++
+				value, ok = p.bareItem()
+			}
+			if key == "v" {
+				b.version = value
+			}
+		}
+		p.skipSpace()
+		if ok && (p.done() || p.peek() == ',') {
+			b.name = name
+			brands = append(brands, b)
+		} else {
+			p.skipMember()
+		}
+		if p.peek() == ',' {
+			p.i++
+		}
+	}
+}
+
+// sfParser reads structured field values.
+type sfParser struct {
+	s string
+	i int
+}
+
+func (p *sfParser) done() bool {
+	return p.i >= len(p.s)
+}
+
+// peek returns the next byte, or 0 at the end.
+func (p *sfParser) peek() byte {
+	if p.done() {
+		return 0
+	}
+	return p.s[p.i]
+}
+
+func (p *sfParser) skipSpace() {
+	for !p.done() && (p.s[p.i] == ' ' || p.s[p.i] == '\t') {
+		p.i++
+	}
+}
+
+// skipMember skips to the comma ending the current list member, stepping over quoted strings.
+func (p *sfParser) skipMember() {
+	for !p.done() && p.s[p.i] != ',' {
+		if p.s[p.i] == '"' {
+			p.str()
+			continue
+		}
+		p.i++
+	}
+}
+
+// key reads a parameter key.
+func (p *sfParser) key() string {
+	start := p.i
+	for !p.done() && strings.IndexByte("abcdefghijklmnopqrstuvwxyz0123456789_-.*", p.s[p.i]) >= 0 {
+		p.i++
+	}
+	return p.s[start:p.i]
+}
+
+// bareItem reads a string, or a token, number or boolean as its text.
+func (p *sfParser) bareItem() (string, bool) {
+	if p.peek() == '"' {
+		return p.str()
+	}
+	start := p.i
+	for !p.done() && strings.IndexByte(",; \t=", p.s[p.i]) < 0 {
+		p.i++
+	}
+	return p.s[start:p.i], p.i > start
+}
+
+// str reads a quoted string, unescaping \" and \\.
+func (p *sfParser) str() (string, bool) {
+	var b strings.Builder
+	for p.i++; !p.done(); p.i++ {
+		switch c := p.s[p.i]; c {
+		case '\\':
+			p.i++
+			if p.done() {
+				return "", false
+			}
+			b.WriteByte(p.s[p.i])
+		case '"':
+			p.i++
+			return b.String(), true
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", false
+}
+
+// isGrease reports whether a brand is a GREASE value. Chromium builds them from
+// the words "Not", "A" and "Brand" joined by arbitrary punctuation, e.g.
+// "Not A(Brand", "Not)A;Brand" or "Not;A=Brand".
+func isGrease(brand string) bool {
+	words := strings.FieldsFunc(brand, func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+	return len(words) == 3 && words[0] == "Not" && words[1] == "A" && words[2] == "Brand"
+}
+
+// windowsVersion maps a Sec-CH-UA-Platform-Version major version to the Windows release.
+// Versions 13 and up are Windows 11; 1 through 10 are Windows 10.
+func windowsVersion(major string) string {
+	v, err := strconv.Atoi(major)
+	switch {
+	case err != nil:
+		return major
+	case v >= 13:
+		return "11"
+	case v >= 1:
+		return "10"
+	default:
+		// Windows 7, 8 or 8.1; not distinguishable from the hint
+		return ""
+	}
+}
+
+// splitVersion splits "15.0.1" into its major, minor and patch parts.
+func splitVersion(version string) (string, string, string) {
+	parts := strings.SplitN(version, ".", 4)
+	for len(parts) < 3 {
+		parts = append(parts, "")
+	}
+	return parts[0], parts[1], parts[2]
+}
+
+// unquote strips the quotes from a structured header string.
+func unquote(value string) string {
+	value = strings.TrimSpace(value)
+	if s, err := strconv.Unquote(value); err == nil {
+		return s
+	}
+	return strings.Trim(value, `"`)
+}
+
+// parseBoolean parses a structured header boolean, "?1" or "?0".
+func parseBoolean(value string) (bool, bool) {
+	switch strings.TrimSpace(value) {
+	case "?1":
+		return true, true
+	case "?0":
+		return false, true
+	default:
+		return false, false
+	}
+}
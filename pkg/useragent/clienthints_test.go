@@ -0,0 +1,94 @@
+package useragent
+
+import "testing"
+
+func TestPickBrand(t *testing.T) {
+	tests := []struct {
+		name    string
+		list    string
+		brand   string
+		version string
+	}{
+		{"chrome 127", `"Not)A;Brand";v="99", "Google Chrome";v="127", "Chromium";v="127"`, "Google Chrome", "127"},
+		{"chrome 128", `"Chromium";v="128", "Not;A=Brand";v="24", "Google Chrome";v="128"`, "Google Chrome", "128"},
+		{"chrome 126", `"Not/A)Brand";v="8", "Chromium";v="126", "Google Chrome";v="126"`, "Google Chrome", "126"},
+		{"chrome 124", `"Chromium";v="124", "Google Chrome";v="124", "Not-A.Brand";v="99"`, "Google Chrome", "124"},
+		{"chrome 98", `" Not A;Brand";v="99", "Chromium";v="98", "Google Chrome";v="98"`, "Google Chrome", "98"},
+		{"edge 127", `"Not)A;Brand";v="99", "Microsoft Edge";v="127", "Chromium";v="127"`, "Microsoft Edge", "127"},
+		{"opera 112", `"Not/A)Brand";v="8", "Chromium";v="126", "Opera";v="112"`, "Opera", "112"},
+		{"chromium only", `"Chromium";v="127", "Not)A;Brand";v="99"`, "Chromium", "127"},
+		{"unknown over chromium", `"Whale";v="3", "Not-A.Brand";v="8", "Chromium";v="120"`, "Whale", "3"},
+		{"known over unknown", `"Whale";v="3", "Google Chrome";v="127", "Chromium";v="127"`, "Google Chrome", "127"},
+		{"full version list", `"Not)A;Brand";v="99.0.0.0", "Google Chrome";v="127.0.6533.89", "Chromium";v="127.0.6533.89"`, "Google Chrome", "127.0.6533.89"},
+		{"escaped quote", `"Odd\"Brand";v="1"`, `Odd"Brand`, "1"},
+		{"malformed member skipped", `"Google Chrome" junk;v="1", "Chromium";v="127"`, "Chromium", "127"},
+		{"grease only", `"Not_A Brand";v="8"`, "", ""},
+		{"empty", ``, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			brand, version, ok := pickBrand(tt.list)
+			if brand != tt.brand || version != tt.version || ok != (tt.brand != "") {
+				t.Errorf("pickBrand(%s) = %q, %q, %v; want %q, %q", tt.list, brand, version, ok, tt.brand, tt.version)
+			}
+		})
+	}
+}
+
+func TestIsGrease(t *testing.T) {
+	for _, brand := range []string{"Not A(Brand", "Not)A;Brand", "Not;A=Brand", "Not/A)Brand", "Not-A.Brand", "Not_A Brand", " Not A;Brand", "Not?A_Brand", "Not.A/Brand"} {
+		if !isGrease(brand) {
+			t.Errorf("isGrease(%q) = false", brand)
+		}
+	}
+	for _, brand := range []string{"Google Chrome", "Chromium", "Microsoft Edge", "Not A Browser", "Brand", "NotA Brand"} {
+		if isGrease(brand) {
+			t.Errorf("isGrease(%q) = true", brand)
+		}
+	}
+}
+
+func TestParseWithHints(t *testing.T) {
+	const reduced = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/127.0.0.0 Safari/537.36"
+	tests := []struct {
+		name   string
+		ua     string
+		hints  ClientHints
+		family string
+		major  string
+		minor  string
+		source string
+	}{
+		{
+			name:   "grease first",
+			ua:     reduced,
+			hints:  ClientHints{UA: `"Not)A;Brand";v="99", "Google Chrome";v="127", "Chromium";v="127"`},
+			family: "Chrome", major: "127", minor: "0", source: SourceUserAgent,
+		},
+		{
+			name: "full version list",
+			ua:   reduced,
+			hints: ClientHints{
+				UA:              `"Chromium";v="127", "Not;A=Brand";v="24", "Google Chrome";v="127"`,
+				FullVersionList: `"Chromium";v="127.0.6533.89", "Not;A=Brand";v="24.0.0.0", "Google Chrome";v="127.0.6533.89"`,
+			},
+			family: "Chrome", major: "127", minor: "0", source: SourceClientHints,
+		},
+		{
+			name:   "edge",
+			ua:     reduced + " Edg/127.0.0.0",
+			hints:  ClientHints{UA: `"Not)A;Brand";v="99", "Microsoft Edge";v="127", "Chromium";v="127"`},
+			family: "Edge", major: "127", minor: "0", source: SourceUserAgent,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record := ParseWithHints(tt.ua, tt.hints)
+			if record.UAFamily != tt.family || record.UAMajor != tt.major || record.UAMinor != tt.minor || record.UASource != tt.source {
+				t.Errorf("got %s %s.%s from %s; want %s %s.%s from %s",
+					record.UAFamily, record.UAMajor, record.UAMinor, record.UASource,
+					tt.family, tt.major, tt.minor, tt.source)
+			}
+		})
+	}
+}
@@ -0,0 +1,233 @@
+package useragent
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/sirupsen/logrus"
+	"github.com/ua-parser/uap-go/uaparser"
+)
+
+// reloadDelay lets a definitions file finish writing before it is reloaded.
+const reloadDelay = time.Second
+
+// Option configures the definitions installed by Load.
+type Option func(config *Config)
+
+// Config describes where the user-agent regex definitions come from.
+type Config struct {
+	regexesFile   string
+	overrideFiles []string
+	overrides     [][]byte
+	watch         bool
+	log           *logrus.Logger
+
+	watcher  *fsnotify.Watcher
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// Load builds a parser from the configured definitions and installs it as the
+// parser used by Parse. Override rules are checked ahead of the base definitions,
+// which default to the compiled-in uaparser.DefinitionYaml.
+func Load(opts ...Option) (*Config, error) {
+	config := &Config{}
+
+	// apply the list of options to Config
+	for _, opt := range opts {
+		opt(config)
+	}
+
+	if config.log == nil {
+		config.log = logrus.New()
+	}
+
+	if err := config.Reload(); err != nil {
+		return nil, err
+	}
+
+	if config.watch {
+		if err := config.startWatcher(); err != nil {
+			return nil, err
+		}
+	}
+	return config, nil
+}
+
+// SetRegexesFile replaces the compiled-in definitions with a regexes.yaml file.
+func SetRegexesFile(path string) Option {
+	return func(config *Config) {
+		config.regexesFile = path
+	}
+}
+
+// SetOverridesFile adds a regexes.yaml formatted file of rules checked ahead of the base definitions.
+// Files added first are checked first.
+func SetOverridesFile(path string) Option {
+	return func(config *Config) {
+		config.overrideFiles = append(config.overrideFiles, path)
+	}
+}
+
+// SetOverrides adds regexes.yaml formatted rules checked ahead of the base definitions
+// and any override files.
+func SetOverrides(definitions []byte) Option {
+	return func(config *Config) {
+		config.overrides = append(config.overrides, definitions)
+	}
+}
+
+// SetWatch reloads the definitions whenever the regexes or override files change on disk.
+func SetWatch(watch bool) Option {
+	return func(config *Config) {
+		config.watch = watch
+	}
+}
+
+// SetLogger sets the logger used to report reloads.
+func SetLogger(log *logrus.Logger) Option {
+	return func(config *Config) {
+		config.log = log
+	}
+}
+
+// Reload rebuilds the parser from the configured definitions and swaps it in.
+// Parsed user-agents held by a Cache are invalidated.
+func (config *Config) Reload() error {
+	base := uaparser.DefinitionYaml
+	if config.regexesFile != "" {
+		var err error
+		if base, err = os.ReadFile(config.regexesFile); err != nil {
+			return err
+		}
+	}
+
+	merged, err := uaparser.NewFromBytes(base)
+	if err != nil {
+		return fmt.Errorf("regexes: %w", err)
+	}
+
+	// Build the overrides in reverse so earlier ones end up in front
+	overrides := append([][]byte{}, config.overrides...)
+	for _, path := range config.overrideFiles {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		overrides = append(overrides, data)
+	}
+	for i := len(overrides) - 1; i >= 0; i-- {
+		override, err := uaparser.NewFromBytes(overrides[i])
+		if err != nil {
+			return fmt.Errorf("overrides: %w", err)
+		}
+		merged.UA = append(override.UA, merged.UA...)
+		merged.OS = append(override.OS, merged.OS...)
+		merged.Device = append(override.Device, merged.Device...)
+	}
+
+	parser.Store(merged)
+	generation.Add(1)
+	return nil
+}
+
+// Close stops watching the definitions. The installed parser stays in use.
+// It is safe to call repeatedly and concurrently.
+func (config *Config) Close() error {
+	var err error
+	config.stopOnce.Do(func() {
+		if config.watcher == nil {
+			return
+		}
+		close(config.done)
+		err = config.watcher.Close()
+	})
+	return err
+}
+
+// startWatcher watches the directories of the definitions files for changes.
+func (config *Config) startWatcher() error {
+	files := append([]string{}, config.overrideFiles...)
+	if config.regexesFile != "" {
+		files = append(files, config.regexesFile)
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+
+	paths := make(map[string]bool, len(files))
+	dirs := make(map[string]bool)
+	for _, file := range files {
+		abs, err := filepath.Abs(file)
+		if err != nil {
+			watcher.Close()
+			return err
+		}
+		paths[abs] = true
+
+		dir := filepath.Dir(abs)
+		if dirs[dir] {
+			continue
+		}
+		if err := watcher.Add(dir); err != nil {
+			watcher.Close()
+			return err
+		}
+		dirs[dir] = true
+	}
+
+	config.watcher = watcher
+	config.done = make(chan struct{})
+	go config.watchLoop(watcher, paths)
+	return nil
+}
+
+// watchLoop reloads the definitions as their files change until Close is called.
+func (config *Config) watchLoop(watcher *fsnotify.Watcher, paths map[string]bool) {
+	timer := time.NewTimer(reloadDelay)
+	timer.Stop()
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-config.done:
+			return
+
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			if !event.Has(fsnotify.Write) && !event.Has(fsnotify.Create) {
+				continue
+			}
+			if abs, err := filepath.Abs(event.Name); err != nil || !paths[abs] {
+				continue
+			}
+			// Debounce: editors often write a file several times
+			timer.Reset(reloadDelay)
+
+		case <-timer.C:
+			if err := config.Reload(); err != nil {
+				config.log.WithFields(logrus.Fields{
+					"error": err,
+				}).Error("useragent definitions reload failed")
+				continue
+			}
+			config.log.Info("useragent definitions reloaded")
+
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			config.log.WithFields(logrus.Fields{
+				"error": err,
+			}).Error("useragent definitions watcher failed")
+		}
+	}
+}
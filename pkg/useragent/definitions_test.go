@@ -0,0 +1,124 @@
+package useragent
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+const chromeUA = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/118.0.0.0 Safari/537.36"
+
+// writeOverride writes a rule renaming the Chrome family to family and returns its path.
+func writeOverride(t *testing.T, path, family string) string {
+	t.Helper()
+	rules := "user_agent_parsers:\n  - regex: '(Chrome)/(\\d+)\\.(\\d+)'\n    family_replacement: '" + family + "'\n"
+	if err := os.WriteFile(path, []byte(rules), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// load installs definitions built with opts, restoring the compiled-in ones after the test.
+func load(t *testing.T, opts ...Option) *Config {
+	t.Helper()
+	t.Cleanup(func() {
+		if _, err := Load(); err != nil {
+			t.Fatal(err)
+		}
+	})
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	config, err := Load(append([]Option{SetLogger(log)}, opts...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return config
+}
+
+func TestLoadOverrides(t *testing.T) {
+	if family := Parse(chromeUA).UAFamily; family != "Chrome" {
+		t.Fatalf("base family %s; want Chrome", family)
+	}
+
+	dir := t.TempDir()
+	first := writeOverride(t, filepath.Join(dir, "first.yaml"), "First")
+	second := writeOverride(t, filepath.Join(dir, "second.yaml"), "Second")
+	load(t, SetOverridesFile(first), SetOverridesFile(second))
+	if record := Parse(chromeUA); record.UAFamily != "First" || record.UAMajor != "118" {
+		t.Errorf("family %s %s; want the first override, First 118", record.UAFamily, record.UAMajor)
+	}
+
+	load(t, SetOverridesFile(second), SetOverrides([]byte("user_agent_parsers:\n  - regex: '(Chrome)/(\\d+)'\n    family_replacement: 'Inline'\n")))
+	if family := Parse(chromeUA).UAFamily; family != "Inline" {
+		t.Errorf("family %s; want Inline, checked ahead of override files", family)
+	}
+
+	if _, err := Load(SetOverridesFile(filepath.Join(dir, "missing.yaml"))); err == nil {
+		t.Error("Load of a missing override file succeeded")
+	}
+}
+
+func TestReload(t *testing.T) {
+	path := writeOverride(t, filepath.Join(t.TempDir(), "overrides.yaml"), "Before")
+	config := load(t, SetOverridesFile(path))
+	cache := NewCache(10)
+	if family := cache.Parse(chromeUA).UAFamily; family != "Before" {
+		t.Fatalf("family %s; want Before", family)
+	}
+
+	writeOverride(t, path, "After")
+	gen := generation.Load()
+	if err := config.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if generation.Load() != gen+1 {
+		t.Errorf("generation %d after Reload; want %d", generation.Load(), gen+1)
+	}
+	if family := cache.Parse(chromeUA).UAFamily; family != "After" {
+		t.Errorf("cached family %s after Reload; want After", family)
+	}
+
+	// A bad file leaves the current parser in place
+	if err := os.WriteFile(path, []byte("user_agent_parsers: [unterminated\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := config.Reload(); err == nil {
+		t.Fatal("Reload of a malformed file succeeded")
+	}
+	if family := Parse(chromeUA).UAFamily; family != "After" {
+		t.Errorf("family %s after a failed Reload; want After", family)
+	}
+}
+
+func TestCloseTwice(t *testing.T) {
+	path := writeOverride(t, filepath.Join(t.TempDir(), "overrides.yaml"), "Watched")
+	config := load(t, SetOverridesFile(path), SetWatch(true))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := config.Close(); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if err := config.Close(); err != nil {
+		t.Errorf("second Close: %s", err)
+	}
+
+	// Closing without a watcher is fine too
+	unwatched := load(t)
+	if err := unwatched.Close(); err != nil {
+		t.Error(err)
+	}
+	if err := unwatched.Close(); err != nil {
+		t.Error(err)
+	}
+}
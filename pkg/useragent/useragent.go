@@ -2,12 +2,17 @@ package useragent
 
 import (
 	"log"
+	"sync/atomic"
 
 	"github.com/ua-parser/uap-go/uaparser"
 )
 
 var (
-	parser *uaparser.Parser
+	// parser is swapped by Load and Config.Reload
+	parser atomic.Pointer[uaparser.Parser]
+
+	// generation is incremented on every parser swap
+	generation atomic.Uint64
 )
 
 // Record is the data returned from the useragent package.
@@ -25,20 +30,29 @@ type Record struct {
 	UADeviceFamily string `json:"ua_device_family"`
 	UADeviceBrand  string `json:"ua_device_brand"`
 	UADeviceModel  string `json:"ua_device_model"`
+	IsBot          bool   `json:"is_bot"`
+	BotCategory    string `json:"bot_category"`
+	BotName        string `json:"bot_name"`
+	BotOperator    string `json:"bot_operator"`
+	UAMobile       bool   `json:"ua_mobile"`        // from Sec-CH-UA-Mobile only
+	UASource       string `json:"ua_source"`        // user-agent or client-hints
+	UAOSSource     string `json:"ua_os_source"`     // user-agent or client-hints
+	UADeviceSource string `json:"ua_device_source"` // user-agent or client-hints
 }
 
 // init sets up the parser.
 func init() {
-	var err error
-	parser, err = uaparser.NewFromBytes(uaparser.DefinitionYaml)
+	p, err := uaparser.NewFromBytes(uaparser.DefinitionYaml)
 	if err != nil {
 		log.Fatal(err)
 	}
+	parser.Store(p)
 }
 
 // ParseFile parses the given file.
 func Parse(line string) *Record {
-	client := parser.Parse(line)
+	client := parser.Load().Parse(line)
+	bot := Classify(line)
 	return &Record{
 		Raw:            line,
 		UAFamily:       client.UserAgent.Family,
@@ -53,5 +67,12 @@ func Parse(line string) *Record {
 		UADeviceFamily: client.Device.Family,
 		UADeviceBrand:  client.Device.Brand,
 		UADeviceModel:  client.Device.Model,
+		IsBot:          bot.IsBot,
+		BotCategory:    bot.Category,
+		BotName:        bot.Name,
+		BotOperator:    bot.Operator,
+		UASource:       SourceUserAgent,
+		UAOSSource:     SourceUserAgent,
+		UADeviceSource: SourceUserAgent,
 	}
 }
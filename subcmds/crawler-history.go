@@ -0,0 +1,58 @@
+package subcmds
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+// historyLimit is the number of crawls to show
+var historyLimit int
+
+// crawlerHistory represents the history command
+var crawlerHistory = &cobra.Command{
+	Use:   "history",
+	Short: "Show recent crawls",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		asJSON, err := jsonOutput()
+		if err != nil {
+			return err
+		}
+
+		crawler, err := newCrawler(true)
+		if err != nil {
+			return err
+		}
+
+		runs, err := crawler.GetCrawlerHistory(cmd.Context(), historyLimit)
+		if err != nil {
+			return err
+		}
+
+		if asJSON {
+			return printJSON(runs)
+		}
+		w := newTable()
+		fmt.Fprintln(w, "ID\tSTATE\tSTART\tEND\tDPU HOURS\tSUMMARY")
+		for _, run := range runs {
+			summary := run.Summary
+			if run.ErrorMessage != "" {
+				summary = run.ErrorMessage
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%.2f\t%s\n",
+				run.ID,
+				run.State,
+				formatTime(run.StartTime),
+				formatTime(run.EndTime),
+				run.DPUHour,
+				summary)
+		}
+		return w.Flush()
+	},
+}
+
+func init() {
+	crawlerCmd.AddCommand(crawlerHistory)
+
+	crawlerHistory.Flags().IntVar(&historyLimit, "limit", 10, "Number of crawls to show (0 for all)")
+}
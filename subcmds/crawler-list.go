@@ -0,0 +1,43 @@
+package subcmds
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+// listCrawlers represents the list command
+var listCrawlers = &cobra.Command{
+	Use:   "list",
+	Short: "List crawlers",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		asJSON, err := jsonOutput()
+		if err != nil {
+			return err
+		}
+
+		crawler, err := newCrawler(false)
+		if err != nil {
+			return err
+		}
+
+		names, err := crawler.ListCrawlers(cmd.Context())
+		if err != nil {
+			return err
+		}
+
+		if asJSON {
+			return printJSON(names)
+		}
+		w := newTable()
+		fmt.Fprintln(w, "NAME")
+		for _, name := range names {
+			fmt.Fprintln(w, name)
+		}
+		return w.Flush()
+	},
+}
+
+func init() {
+	crawlerCmd.AddCommand(listCrawlers)
+}
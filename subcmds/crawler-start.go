@@ -0,0 +1,71 @@
+package subcmds
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/rmrfslashbin/aws-cf-rtl/pkg/glue"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// startWait blocks until the crawl finishes
+	startWait bool
+	// startTimeout bounds the wait
+	startTimeout time.Duration
+	// startInterval is the polling interval while waiting
+	startInterval time.Duration
+)
+
+// startCrawler represents the start command
+var startCrawler = &cobra.Command{
+	Use:   "start",
+	Short: "Start the crawler",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		asJSON, err := jsonOutput()
+		if err != nil {
+			return err
+		}
+
+		crawler, err := newCrawler(true)
+		if err != nil {
+			return err
+		}
+
+		if !startWait {
+			if err := crawler.StartCrawler(cmd.Context()); err != nil {
+				return err
+			}
+			fmt.Println("crawler started")
+			return nil
+		}
+
+		ctx := cmd.Context()
+		if startTimeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, startTimeout)
+			defer cancel()
+		}
+
+		status, err := crawler.StartCrawlerAndWait(ctx, startInterval)
+		if status != nil {
+			if asJSON {
+				if jerr := printJSON(status); jerr != nil {
+					return jerr
+				}
+			} else {
+				printCrawlerStatus(status)
+			}
+		}
+		return err
+	},
+}
+
+func init() {
+	crawlerCmd.AddCommand(startCrawler)
+
+	startCrawler.Flags().BoolVar(&startWait, "wait", false, "Wait for the crawl to finish")
+	startCrawler.Flags().DurationVar(&startTimeout, "timeout", 0, "Give up waiting after this long (default no limit)")
+	startCrawler.Flags().DurationVar(&startInterval, "interval", glue.DefaultPollInterval, "Polling interval while waiting")
+}
@@ -7,7 +7,7 @@ package subcmds
 import (
 	"fmt"
 
-	"github.com/sirupsen/logrus"
+	"github.com/rmrfslashbin/aws-cf-rtl/pkg/glue"
 	"github.com/spf13/cobra"
 )
 
@@ -16,28 +16,63 @@ var getCrawlerStatus = &cobra.Command{
 	Use:   "status",
 	Short: "Status the crawler",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		if crawlerName == "" {
-			fmt.Println("crawler name is required")
-			return fmt.Errorf("crawler name not specified")
+		asJSON, err := jsonOutput()
+		if err != nil {
+			return err
 		}
-		return nil
-	},
-	Run: func(cmd *cobra.Command, args []string) {
-		fmt.Printf("Running crawler %s\n", crawlerName)
+
+		crawler, err := newCrawler(true)
+		if err != nil {
+			return err
+		}
+
+		status, err := crawler.GetCrawlerStatus(cmd.Context())
+		if err != nil {
+			return err
+		}
+		metrics, err := crawler.GetCrawlerMetrics(cmd.Context())
+		if err != nil {
+			return err
+		}
+
+		if asJSON {
+			return printJSON(struct {
+				Status  *glue.CrawlerStatus  `json:"status"`
+				Metrics *glue.CrawlerMetrics `json:"metrics"`
+			}{status, metrics})
+		}
+		printCrawlerStatus(status)
+
+		w := newTable()
+		fmt.Fprintf(w, "Tables created:\t%d\n", metrics.TablesCreated)
+		fmt.Fprintf(w, "Tables updated:\t%d\n", metrics.TablesUpdated)
+		fmt.Fprintf(w, "Tables deleted:\t%d\n", metrics.TablesDeleted)
+		fmt.Fprintf(w, "Last runtime:\t%s\n", metrics.LastRuntime)
+		fmt.Fprintf(w, "Median runtime:\t%s\n", metrics.MedianRuntime)
+		return w.Flush()
 	},
 }
 
 func init() {
-	logrus.Info("status init")
 	crawlerCmd.AddCommand(getCrawlerStatus)
+}
 
-	// Here you will define your flags and configuration settings.
-
-	// Cobra supports Persistent Flags which will work for this command
-	// and all subcommands, e.g.:
-	// getCmd.PersistentFlags().String("foo", "", "A help for foo")
-
-	// Cobra supports local flags which will only run when this command
-	// is called directly, e.g.:
-	// getCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+// printCrawlerStatus prints a crawler status as a table.
+func printCrawlerStatus(status *glue.CrawlerStatus) {
+	w := newTable()
+	fmt.Fprintf(w, "Name:\t%s\n", status.Name)
+	fmt.Fprintf(w, "State:\t%s\n", status.State)
+	fmt.Fprintf(w, "Database:\t%s\n", status.DatabaseName)
+	if status.State != glue.StateReady {
+		fmt.Fprintf(w, "Elapsed:\t%s\n", status.Elapsed)
+	}
+	fmt.Fprintf(w, "Last crawl:\t%s\n", status.LastCrawlStatus)
+	fmt.Fprintf(w, "Last crawl start:\t%s\n", formatTime(status.LastCrawlStart))
+	if status.LastCrawlError != "" {
+		fmt.Fprintf(w, "Last crawl error:\t%s\n", status.LastCrawlError)
+	}
+	if status.Schedule != "" {
+		fmt.Fprintf(w, "Schedule:\t%s (%s)\n", status.Schedule, status.ScheduleState)
+	}
+	w.Flush()
 }
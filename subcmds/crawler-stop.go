@@ -0,0 +1,29 @@
+package subcmds
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+// stopCrawler represents the stop command
+var stopCrawler = &cobra.Command{
+	Use:   "stop",
+	Short: "Stop the crawler",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		crawler, err := newCrawler(true)
+		if err != nil {
+			return err
+		}
+
+		if err := crawler.StopCrawler(cmd.Context()); err != nil {
+			return err
+		}
+		fmt.Println("crawler stopping")
+		return nil
+	},
+}
+
+func init() {
+	crawlerCmd.AddCommand(stopCrawler)
+}
@@ -5,27 +5,38 @@ Copyright © 2022 NAME HERE <EMAIL ADDRESS>
 package subcmds
 
 import (
+	"fmt"
+
+	"github.com/rmrfslashbin/aws-cf-rtl/pkg/glue"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
 // crawlerCmd represents the crawler command
-var (
-	// crawlerName is the name of the crawler to act on
-	crawlerName string
-	crawlerCmd  = &cobra.Command{
-		Use:   "crawler",
-		Short: "Crawler related commands",
-	}
-)
+var crawlerCmd = &cobra.Command{
+	Use:   "crawler",
+	Short: "Crawler related commands",
+}
 
 func init() {
-	logrus.Info("crawler init")
 	rootCmd.AddCommand(crawlerCmd)
 
-	crawlerCmd.PersistentFlags().StringVar(&crawlerName, "cname", "", "Name of the crawler")
-	//viper.BindPFlag("crawler.name", crawlerCmd.PersistentFlags().Lookup("cname"))
-	logrus.WithFields(logrus.Fields{
-		"crawlerName": crawlerName,
-	}).Info("crawlerName")
+	crawlerCmd.PersistentFlags().String("cname", "", "Name of the crawler")
+	viper.BindPFlag("crawler.name", crawlerCmd.PersistentFlags().Lookup("cname"))
+}
+
+// newCrawler returns a glue client configured from flags and the config file.
+func newCrawler(requireName bool) (*glue.Config, error) {
+	name := viper.GetString("crawler.name")
+	if requireName && name == "" {
+		return nil, fmt.Errorf("crawler name not specified")
+	}
+
+	return glue.NewCrawler(
+		glue.SetProfile(viper.GetString("profile")),
+		glue.SetRegion(viper.GetString("region")),
+		glue.SetLogger(logrus.StandardLogger()),
+		glue.SetCrawlerName(name),
+	)
 }
@@ -0,0 +1,209 @@
+package subcmds
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rmrfslashbin/aws-cf-rtl/pkg/generate"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// generateCount is the number of lines written
+	generateCount int
+	// generateSeed seeds the random source; the same seed gives the same lines
+	generateSeed int64
+	// generateStart is the timestamp of the first line, defaulting to generate.DefaultStart
+	generateStart string
+	// generateRate is the average requests per second the timestamps advance by
+	generateRate float64
+	// generateStatuses, generateEdgeLocations, generateCountries, generateCacheResults
+	// and generateHosts are value=weight distributions
+	generateStatuses      string
+	generateEdgeLocations string
+	generateCountries     string
+	generateCacheResults  string
+	generateHosts         string
+	// generateUserAgents is a file of weight<TAB>user-agent lines
+	generateUserAgents string
+	// generateBotShare is the share of bot user-agents; negative keeps the weights
+	generateBotShare float64
+	// generateIPv6Share is the share of IPv6 clients
+	generateIPv6Share float64
+	// generateLatency and generateLatencySigma shape the time taken
+	generateLatency      time.Duration
+	generateLatencySigma float64
+	// generateClientCIDRs are the blocks client addresses are drawn from
+	generateClientCIDRs []string
+	// generateOut is the output file; empty or "-" is stdout
+	generateOut string
+	// generateGzip compresses the output
+	generateGzip bool
+)
+
+// generateCmd represents the generate command
+var generateCmd = &cobra.Command{
+	Use:   "generate",
+	Short: "Generate realistic synthetic log lines",
+	Long: `Writes synthetic Cloudfront real-time log lines with the --fields list, for
+load testing the Lambda and the Kinesis stream, or for demoing dashboards without
+real customer data. Client addresses are drawn from the documentation ranges
+unless --client-cidr is given.
+
+Distributions are comma separated value=weight pairs, e.g. --status
+200=90,404=8,503=2. The defaults describe a healthy site. --user-agents reads a
+file of weight<TAB>user-agent lines, and --bot-share rescales the user-agents
+so that bots make up that share of the requests.
+
+Timestamps start at --start and advance by random intervals averaging
+1/--rate seconds. Time taken is log-normal around --latency-median for cache
+hits; misses and errors take several times longer.
+
+The same --seed and --start always give the same lines.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		opts := []generate.Option{
+			generate.SetSeed(generateSeed),
+			generate.SetFields(logFields()),
+			generate.SetRate(generateRate),
+			generate.SetBotShare(generateBotShare),
+			generate.SetIPv6Share(generateIPv6Share),
+			generate.SetLatency(generateLatency, generateLatencySigma),
+		}
+
+		start := generate.DefaultStart
+		if generateStart != "" {
+			var err error
+			if start, err = parseTime(generateStart); err != nil {
+				return fmt.Errorf("start: %w", err)
+			}
+		}
+		opts = append(opts, generate.SetStart(start))
+
+		for _, dist := range []struct {
+			flag  string
+			value string
+			set   func(generate.Weights) generate.Option
+		}{
+			{"status", generateStatuses, generate.SetStatuses},
+			{"edge-location", generateEdgeLocations, generate.SetEdgeLocations},
+			{"country", generateCountries, generate.SetCountries},
+			{"cache-result", generateCacheResults, generate.SetCacheResults},
+			{"host", generateHosts, generate.SetHosts},
+		} {
+			if dist.value == "" {
+				continue
+			}
+			weights, err := generate.ParseWeights(dist.value)
+			if err != nil {
+				return fmt.Errorf("%s: %w", dist.flag, err)
+			}
+			opts = append(opts, dist.set(weights))
+		}
+		if generateUserAgents != "" {
+			weights, err := readUserAgents(generateUserAgents)
+			if err != nil {
+				return fmt.Errorf("user-agents: %w", err)
+			}
+			opts = append(opts, generate.SetUserAgents(weights))
+		}
+		if len(generateClientCIDRs) > 0 {
+			opts = append(opts, generate.SetClientCIDRs(generateClientCIDRs))
+		}
+
+		g, err := generate.New(opts...)
+		if err != nil {
+			return err
+		}
+
+		var w io.Writer = os.Stdout
+		if generateOut != "" && generateOut != "-" {
+			f, err := os.Create(generateOut)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			w = f
+		}
+		if generateGzip {
+			gz := gzip.NewWriter(w)
+			defer gz.Close()
+			w = gz
+		}
+		if err := g.Write(w, generateCount); err != nil {
+			return err
+		}
+		if gz, ok := w.(*gzip.Writer); ok {
+			if err := gz.Close(); err != nil {
+				return err
+			}
+		}
+
+		logrus.WithFields(logrus.Fields{
+			"lines": generateCount,
+			"seed":  generateSeed,
+			"start": start.UTC().Format(time.RFC3339),
+		}).Debug("generated")
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(generateCmd)
+
+	generateCmd.Flags().IntVarP(&generateCount, "count", "n", 1000, "Number of lines to generate")
+	generateCmd.Flags().Int64Var(&generateSeed, "seed", generate.DefaultSeed, "Seed of the random source")
+	generateCmd.Flags().StringVar(&generateStart, "start", generate.DefaultStart.Format(time.RFC3339), "Timestamp of the first line (RFC 3339)")
+	generateCmd.Flags().Float64Var(&generateRate, "rate", generate.DefaultRate, "Average requests per second of log time")
+	generateCmd.Flags().StringVar(&generateStatuses, "status", "", "Status code distribution, e.g. 200=90,404=8,503=2")
+	generateCmd.Flags().StringVar(&generateEdgeLocations, "edge-location", "", "Edge location distribution, e.g. IAD89-C1=3,LHR61-C2=1")
+	generateCmd.Flags().StringVar(&generateCountries, "country", "", "Client country distribution, e.g. US=5,DE=1")
+	generateCmd.Flags().StringVar(&generateCacheResults, "cache-result", "", "Cache result distribution of successful responses, e.g. Hit=80,Miss=20")
+	generateCmd.Flags().StringVar(&generateHosts, "host", "", "Host header distribution, e.g. www.example.com=9,api.example.com=1")
+	generateCmd.Flags().StringVar(&generateUserAgents, "user-agents", "", "File of weight<TAB>user-agent lines")
+	generateCmd.Flags().Float64Var(&generateBotShare, "bot-share", -1, "Share of requests from bots, 0 to 1; default keeps the user-agent weights")
+	generateCmd.Flags().Float64Var(&generateIPv6Share, "ipv6", generate.DefaultIPv6Share, "Share of requests from IPv6 clients, 0 to 1")
+	generateCmd.Flags().DurationVar(&generateLatency, "latency-median", generate.DefaultLatency, "Median time taken by cache hits")
+	generateCmd.Flags().Float64Var(&generateLatencySigma, "latency-sigma", generate.DefaultLatencySigma, "Spread of the log-normal time taken")
+	generateCmd.Flags().StringSliceVar(&generateClientCIDRs, "client-cidr", nil, "CIDR block client addresses are drawn from; repeatable")
+	generateCmd.Flags().StringVar(&generateOut, "out", "", "File to write; default stdout")
+	generateCmd.Flags().BoolVar(&generateGzip, "gzip", false, "Gzip compress the output")
+}
+
+// readUserAgents reads a user-agent distribution of weight<TAB>user-agent
+// lines. Blank lines and lines starting with # are skipped.
+func readUserAgents(name string) (generate.Weights, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	weights := generate.Weights{}
+	scanner := bufio.NewScanner(f)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		weight, ua, ok := strings.Cut(line, "\t")
+		w, err := strconv.ParseFloat(strings.TrimSpace(weight), 64)
+		if !ok || err != nil || w < 0 {
+			return nil, fmt.Errorf("line %d: want weight<TAB>user-agent", lineNo)
+		}
+		weights[strings.TrimSpace(ua)] += w
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(weights) == 0 {
+		return nil, fmt.Errorf("no user-agents in %s", name)
+	}
+	return weights, nil
+}
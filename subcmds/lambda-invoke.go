@@ -0,0 +1,208 @@
+package subcmds
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/rmrfslashbin/aws-cf-rtl/pkg/transform/transformtest"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// invokeBatchSize is the number of records per Firehose event
+	invokeBatchSize int
+	// invokeRecords lists every record in the output
+	invokeRecords bool
+	// invokeRequireOk fails the command when any record is not Ok
+	invokeRequireOk bool
+)
+
+// invokeRecord is a record of the response as listed by --records.
+type invokeRecord struct {
+	RecordID  string            `json:"record_id"`
+	Result    string            `json:"result"`
+	Partition map[string]string `json:"partition_keys,omitempty"`
+	Data      string            `json:"data"`
+}
+
+// invokeSummary is the combined result of every event.
+type invokeSummary struct {
+	Events     int                     `json:"events"`
+	Records    int                     `json:"records"`
+	Results    map[string]int          `json:"results"`
+	Partitions map[string]int          `json:"partitions"`
+	Reasons    map[string]int          `json:"reasons"`
+	Problems   []transformtest.Problem `json:"problems"`
+	Responses  []invokeRecord          `json:"responses,omitempty"`
+}
+
+// lambdaInvoke represents the invoke command
+var lambdaInvoke = &cobra.Command{
+	Use:   "invoke [file...]",
+	Short: "Invoke the Lambda handler in-process with Firehose events",
+	Long: `Builds Firehose events from raw log lines, one record per line, from the files
+given, plain or gzip compressed, or from stdin. Each event is encoded to JSON with
+the record data base64 encoded, as Firehose sends it, and handed to the Lambda
+handler in-process, set up from the same flags as the process command.
+
+The responses are checked as Firehose reads them: every record returned exactly
+once with a valid result, Ok records carrying year/month/day partition keys that
+match their timestamp, and failed records carrying a reason and the original data.
+The command fails on any problem, and with --require-ok on any record not Ok.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		asJSON, err := jsonOutput()
+		if err != nil {
+			return err
+		}
+		if invokeBatchSize < 1 {
+			return fmt.Errorf("batch size must be at least 1")
+		}
+
+		processor, closeGeo, err := newProcessor()
+		if err != nil {
+			return err
+		}
+		defer closeGeo()
+
+		summary := &invokeSummary{
+			Results:    map[string]int{},
+			Partitions: map[string]int{},
+			Reasons:    map[string]int{},
+			Problems:   []transformtest.Problem{},
+		}
+		var batch [][]byte
+		invoke := func() error {
+			if len(batch) == 0 {
+				return nil
+			}
+			result, err := transformtest.Invoke(cmd.Context(), processor.Handler, transformtest.NewEvent(batch))
+			if err != nil {
+				return err
+			}
+			batch = nil
+			summary.add(result)
+			return nil
+		}
+
+		if len(args) == 0 {
+			args = []string{"-"}
+		}
+		for _, name := range args {
+			err := eachLine(name, func(lineNo int, line string) error {
+				batch = append(batch, []byte(line+"\n"))
+				if len(batch) < invokeBatchSize {
+					return nil
+				}
+				return invoke()
+			})
+			if err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+		}
+		if err := invoke(); err != nil {
+			return err
+		}
+
+		if asJSON {
+			if err := printJSON(summary); err != nil {
+				return err
+			}
+		} else {
+			printInvokeSummary(summary)
+		}
+
+		if len(summary.Problems) > 0 {
+			return fmt.Errorf("%d problems with the responses", len(summary.Problems))
+		}
+		if notOk := summary.Records - summary.Results[events.KinesisFirehoseTransformedStateOk]; invokeRequireOk && notOk > 0 {
+			return fmt.Errorf("%d of %d records not Ok", notOk, summary.Records)
+		}
+		return nil
+	},
+}
+
+func init() {
+	lambdaCmd.AddCommand(lambdaInvoke)
+
+	lambdaInvoke.Flags().IntVar(&invokeBatchSize, "batch-size", 500, "Records per Firehose event")
+	lambdaInvoke.Flags().BoolVar(&invokeRecords, "records", false, "List every record of the responses")
+	lambdaInvoke.Flags().BoolVar(&invokeRequireOk, "require-ok", false, "Fail unless every record is Ok")
+	addTransformFlags(lambdaInvoke)
+}
+
+// add folds the result of an invocation into the summary.
+func (summary *invokeSummary) add(result *transformtest.Result) {
+	summary.Events++
+	summary.Records += len(result.Event.Records)
+	for state, n := range result.Results {
+		summary.Results[state] += n
+	}
+	for partition, n := range result.Partitions {
+		summary.Partitions[partition] += n
+	}
+	for reason, n := range result.Reasons {
+		summary.Reasons[reason] += n
+	}
+	summary.Problems = append(summary.Problems, result.Problems...)
+	if invokeRecords {
+		for _, record := range result.Response.Records {
+			summary.Responses = append(summary.Responses, invokeRecord{
+				RecordID:  record.RecordID,
+				Result:    record.Result,
+				Partition: record.Metadata.PartitionKeys,
+				Data:      string(record.Data),
+			})
+		}
+	}
+}
+
+// printInvokeSummary prints the invocation summary as tables.
+func printInvokeSummary(summary *invokeSummary) {
+	if len(summary.Responses) > 0 {
+		w := newTable()
+		fmt.Fprintln(w, "RECORD ID\tRESULT\tPARTITION\tDATA")
+		for _, record := range summary.Responses {
+			partition := "-"
+			if record.Partition != nil {
+				partition = fmt.Sprintf("%s/%s/%s", record.Partition["year"], record.Partition["month"], record.Partition["day"])
+			}
+			data := record.Data
+			if len(data) > 80 {
+				data = data[:77] + "..."
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", record.RecordID, record.Result, partition, data)
+		}
+		w.Flush()
+		fmt.Println()
+	}
+
+	w := newTable()
+	fmt.Fprintf(w, "Events:\t%d\n", summary.Events)
+	fmt.Fprintf(w, "Records:\t%d\n", summary.Records)
+	for _, state := range sortedKeys(summary.Results) {
+		fmt.Fprintf(w, "%s:\t%d\n", state, summary.Results[state])
+	}
+	for _, partition := range sortedKeys(summary.Partitions) {
+		fmt.Fprintf(w, "Partition %s:\t%d\n", partition, summary.Partitions[partition])
+	}
+	for _, reason := range sortedKeys(summary.Reasons) {
+		fmt.Fprintf(w, "Failed %s:\t%d\n", reason, summary.Reasons[reason])
+	}
+	fmt.Fprintf(w, "Problems:\t%d\n", len(summary.Problems))
+	w.Flush()
+
+	for _, problem := range summary.Problems {
+		fmt.Println(problem)
+	}
+}
+
+// sortedKeys returns the keys of m in order.
+func sortedKeys(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,15 @@
+package subcmds
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// lambdaCmd represents the lambda command
+var lambdaCmd = &cobra.Command{
+	Use:   "lambda",
+	Short: "Lambda related commands",
+}
+
+func init() {
+	rootCmd.AddCommand(lambdaCmd)
+}
@@ -0,0 +1,107 @@
+package subcmds
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	// syncDryRun reports missing partitions without creating them
+	syncDryRun bool
+	// syncProjection prints partition projection properties instead of syncing
+	syncProjection bool
+	// projectionFirstYear and projectionLastYear bound the projected years
+	projectionFirstYear int
+	projectionLastYear  int
+)
+
+// syncPartitions represents the sync command
+var syncPartitions = &cobra.Command{
+	Use:   "sync",
+	Short: "Register partitions found in S3 that are missing from the Glue table",
+	Long: `Lists the year=/month=/day= prefixes under the table location and registers
+any missing from the catalog with BatchCreatePartition. Each partition is
+described by the format of its files (ORC or Parquet from the Firehose
+conversion), falling back to the table's SerDe.
+
+With --projection, prints Athena partition projection table properties instead.
+Add them to the table parameters and Athena computes partitions from the
+query, so neither sync nor the crawler is needed.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		asJSON, err := jsonOutput()
+		if err != nil {
+			return err
+		}
+
+		catalog, err := newCatalog()
+		if err != nil {
+			return err
+		}
+
+		if syncProjection {
+			properties, err := catalog.PartitionProjection(cmd.Context(), projectionFirstYear, projectionLastYear)
+			if err != nil {
+				return err
+			}
+			if asJSON {
+				return printJSON(properties)
+			}
+			keys := make([]string, 0, len(properties))
+			for key := range properties {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			for _, key := range keys {
+				fmt.Printf("%s: %q\n", key, properties[key])
+			}
+			return nil
+		}
+
+		result, err := catalog.SyncPartitions(cmd.Context(), syncDryRun)
+		if err != nil {
+			return err
+		}
+
+		if asJSON {
+			if err := printJSON(result); err != nil {
+				return err
+			}
+		} else {
+			w := newTable()
+			fmt.Fprintf(w, "Found in S3:\t%d\n", result.Found)
+			fmt.Fprintf(w, "Registered:\t%d\n", result.Existing)
+			fmt.Fprintf(w, "Missing:\t%d\n", len(result.Missing))
+			if !syncDryRun {
+				fmt.Fprintf(w, "Created:\t%d\n", result.Created)
+			}
+			w.Flush()
+
+			if syncDryRun {
+				for _, partition := range result.Missing {
+					fmt.Printf("%s\t%s\n", strings.Join(partition.Values, "/"), partition.Location)
+				}
+			}
+			for _, message := range result.Errors {
+				fmt.Println(message)
+			}
+		}
+
+		if len(result.Errors) > 0 {
+			return fmt.Errorf("%d partitions could not be created", len(result.Errors))
+		}
+		return nil
+	},
+}
+
+func init() {
+	partitionsCmd.AddCommand(syncPartitions)
+
+	syncPartitions.Flags().BoolVar(&syncDryRun, "dry-run", false, "List missing partitions without creating them")
+	syncPartitions.Flags().BoolVar(&syncProjection, "projection", false, "Print Athena partition projection properties instead of syncing")
+	syncPartitions.Flags().IntVar(&projectionFirstYear, "first-year", 2022, "First projected year")
+	syncPartitions.Flags().IntVar(&projectionLastYear, "last-year", time.Now().Year()+5, "Last projected year")
+}
@@ -0,0 +1,37 @@
+package subcmds
+
+import (
+	"fmt"
+
+	"github.com/rmrfslashbin/aws-cf-rtl/pkg/glue"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// partitionsCmd represents the partitions command
+var partitionsCmd = &cobra.Command{
+	Use:   "partitions",
+	Short: "Glue table partition commands",
+}
+
+func init() {
+	rootCmd.AddCommand(partitionsCmd)
+}
+
+// newCatalog returns a glue client for the table configured from flags and the config file.
+func newCatalog() (*glue.Config, error) {
+	database := viper.GetString("glue.database")
+	table := viper.GetString("glue.table")
+	if database == "" || table == "" {
+		return nil, fmt.Errorf("database and table must be specified")
+	}
+
+	return glue.NewCrawler(
+		glue.SetProfile(viper.GetString("profile")),
+		glue.SetRegion(viper.GetString("region")),
+		glue.SetLogger(logrus.StandardLogger()),
+		glue.SetDatabase(database),
+		glue.SetTable(table),
+	)
+}
@@ -0,0 +1,161 @@
+package subcmds
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/rmrfslashbin/aws-cf-rtl/pkg/transform"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// processOutDir is the root of the year=/month=/day= layout; empty writes to stdout
+	processOutDir string
+	// processErrors is the file failed lines are appended to
+	processErrors string
+)
+
+// processCmd represents the process command
+var processCmd = &cobra.Command{
+	Use:   "process [file...]",
+	Short: "Run the Lambda transform over raw log lines locally",
+	Long: `Parses and enriches raw Cloudfront real-time log lines exactly as the Lambda
+does, without Kinesis or Firehose. Lines are read from the files given, plain or
+gzip compressed, or from stdin when there are none or the file is "-".
+
+Each record is written as a line of the JSON the Lambda returns to Firehose. With
+--out-dir the records are laid out like the Firehose output, under
+year=/month=/day=/ directories named from the record timestamps, in a file
+named after the input, so the inputs must have distinct names. Otherwise they
+are written to stdout.
+
+Lines that fail are logged and, with --errors, appended to a file in the format
+Firehose writes to the error output prefix.
+
+--fields, --parse-mode and --field-mode match the RTL_FIELDS, RTL_PARSE_MODE and
+RTL_FIELD_MODES Lambda settings; --ua-overrides, --ua-regexes, --geoip-db and
+--geoip-build match RTL_UA_OVERRIDES, RTL_UA_REGEXES, RTL_GEOIP_DB and
+RTL_GEOIP_BUILD. The user-agent overrides bundled with the Lambda always apply.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		processor, closeGeo, err := newProcessor()
+		if err != nil {
+			return err
+		}
+		defer closeGeo()
+
+		var errs io.Writer
+		if processErrors != "" {
+			f, err := os.OpenFile(processErrors, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			errs = f
+		}
+
+		if len(args) == 0 {
+			args = []string{"-"}
+		}
+		if processOutDir != "" {
+			// Inputs sharing a base name would append to the same partition files
+			seen := make(map[string]string, len(args))
+			for _, name := range args {
+				out := processOutputName(name)
+				if prev, ok := seen[out]; ok {
+					return fmt.Errorf("%s and %s both write %s; rename one or process them separately", prev, name, out)
+				}
+				seen[out] = name
+			}
+		}
+		stats := &processStats{}
+		for _, name := range args {
+			if err := processFile(processor, name, errs, stats); err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+		}
+
+		logrus.WithFields(logrus.Fields{
+			"files":   len(args),
+			"lines":   stats.lines,
+			"records": stats.records,
+			"failed":  stats.failed,
+		}).Info("processed")
+		if stats.failed > 0 {
+			return fmt.Errorf("%d of %d lines failed", stats.failed, stats.lines)
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(processCmd)
+
+	processCmd.Flags().StringVar(&processOutDir, "out-dir", "", "Write records under year=/month=/day=/ directories here instead of stdout")
+	processCmd.Flags().StringVar(&processErrors, "errors", "", "Append failed lines, with the reason, to this file")
+	addTransformFlags(processCmd)
+}
+
+// processStats counts the lines processed.
+type processStats struct {
+	lines   int
+	records int
+	failed  int
+}
+
+// processOutputName is the name of the partition files written for the named input.
+func processOutputName(name string) string {
+	base := "stdin"
+	if name != "-" {
+		base = filepath.Base(name)
+	}
+	return strings.TrimSuffix(base, ".gz") + ".json"
+}
+
+// processFile transforms every line of the named file, or stdin for "-".
+func processFile(processor *transform.Config, name string, errs io.Writer, stats *processStats) error {
+	out := transform.NewPartitionWriter(processOutDir, processOutputName(name), os.Stdout)
+	defer out.Close()
+
+	err := eachLine(name, func(lineNo int, line string) error {
+		stats.lines++
+
+		record, perr := processor.Parse(line)
+		if perr == nil {
+			data, err := json.Marshal(record)
+			if err != nil {
+				perr = &transform.ParseError{Reason: transform.ReasonEncode, Err: err.Error()}
+			} else if err := out.Write(transform.PartitionKeys(record), data); err != nil {
+				return err
+			}
+		}
+		if perr != nil {
+			stats.failed++
+			logrus.WithFields(logrus.Fields{
+				"file":  name,
+				"line":  lineNo,
+				"error": perr,
+			}).Warn("line failed")
+			if errs != nil {
+				data, err := json.Marshal(&transform.FailedRecord{ParseError: perr, RawData: line + "\n"})
+				if err != nil {
+					return err
+				}
+				if _, err := errs.Write(append(data, '\n')); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		stats.records++
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return out.Close()
+}
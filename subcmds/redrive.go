@@ -0,0 +1,253 @@
+package subcmds
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rmrfslashbin/aws-cf-rtl/pkg/redrive"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	// redriveSource is the backup file, directory or S3 prefix to replay
+	redriveSource string
+	// redriveStart and redriveEnd bound the log timestamps replayed
+	redriveStart string
+	redriveEnd   string
+	// redriveMaxRetries bounds the retries of rejected records
+	redriveMaxRetries int
+	// redriveCheckpoint is the checkpoint file; redriveResume picks up from it
+	redriveCheckpoint string
+	redriveResume     bool
+	// redriveConcurrency is how many files are replayed at once
+	redriveConcurrency int
+	// redriveRecordsPerSec and redriveMBPerSec cap the send rate; zero is no limit
+	redriveRecordsPerSec float64
+	redriveMBPerSec      float64
+	// redriveProgress is how often progress is written to stderr; zero is never
+	redriveProgress time.Duration
+	// redriveHosts, redriveStatus, redriveEdgeLocations and redriveClientCIDRs filter the lines replayed
+	redriveHosts         []string
+	redriveStatus        string
+	redriveEdgeLocations []string
+	redriveClientCIDRs   []string
+	// redriveDryRun counts what the filters pass without sending
+	redriveDryRun bool
+	// redriveRejectFile collects lines that cannot be parsed
+	redriveRejectFile string
+)
+
+// redriveCmd represents the redrive command
+var redriveCmd = &cobra.Command{
+	Use:   "redrive",
+	Short: "Replay backed up log lines into the Kinesis stream",
+	Long: `Replays Cloudfront real-time log lines, such as the Firehose source record
+backups under backup/rtl/, into a Kinesis data stream. The source may be a local
+file or directory, or an S3 prefix such as s3://bucket/backup/rtl/2022/11/.
+Gzip compressed files are decompressed, and --start and --end skip files whose
+Firehose key timestamp is out of range before any line is read.
+
+Lines are sent with PutRecords in batches of up to 500, partitioned on the edge
+request ID. Records Kinesis rejects are retried with backoff; any still rejected
+after --max-retries fail the redrive, and a resume sends them again.
+
+--concurrency files are replayed at once. --records-per-sec and --mb-per-sec
+cap the combined send rate, leaving room on the stream for live traffic. When
+Kinesis throttles, every worker pauses before its next request; the pause
+doubles while throttling continues and shrinks again once requests go through.
+Progress is written to stderr every --progress interval.
+
+Progress is written to the --checkpoint file after every batch. If a redrive
+stops part way, rerun it with --resume to skip what was already sent. The file
+is removed once a redrive has sent everything.
+
+Only lines passing every filter are sent: --start and --end, --host (wildcards
+allowed, e.g. '*.example.com'), --status (e.g. 404, 500-599 or 5xx),
+--edge-location (a prefix such as IAD or IAD89-C1) and --client-cidr. Repeat
+--host, --edge-location or --client-cidr to allow several. With --dry-run the
+lines are read and filtered but nothing is sent, and the summary counts the
+lines each filter turned away.
+
+Lines with the wrong field count are appended to --reject-file and the redrive
+carries on.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		asJSON, err := jsonOutput()
+		if err != nil {
+			return err
+		}
+
+		if redriveSource == "" {
+			return fmt.Errorf("source not specified")
+		}
+		start, err := parseTime(redriveStart)
+		if err != nil {
+			return fmt.Errorf("start: %w", err)
+		}
+		end, err := parseTime(redriveEnd)
+		if err != nil {
+			return fmt.Errorf("end: %w", err)
+		}
+		minStatus, maxStatus, err := parseStatusRange(redriveStatus)
+		if err != nil {
+			return fmt.Errorf("status: %w", err)
+		}
+
+		r, err := redrive.New(
+			redrive.SetProfile(viper.GetString("profile")),
+			redrive.SetRegion(viper.GetString("region")),
+			redrive.SetLogger(logrus.StandardLogger()),
+			redrive.SetStream(viper.GetString("redrive.stream")),
+			redrive.SetEndpoint(viper.GetString("redrive.endpoint")),
+			redrive.SetFields(logFields()),
+			redrive.SetTimeRange(start, end),
+			redrive.SetMaxRetries(redriveMaxRetries),
+			redrive.SetCheckpoint(redriveCheckpoint, redriveResume),
+			redrive.SetConcurrency(redriveConcurrency),
+			redrive.SetRateLimit(redriveRecordsPerSec, redriveMBPerSec*1e6),
+			redrive.SetProgress(redriveProgress, printRedriveProgress),
+			redrive.SetHosts(redriveHosts...),
+			redrive.SetStatusRange(minStatus, maxStatus),
+			redrive.SetEdgeLocations(redriveEdgeLocations...),
+			redrive.SetClientCIDRs(redriveClientCIDRs...),
+			redrive.SetDryRun(redriveDryRun),
+			redrive.SetRejectFile(redriveRejectFile),
+		)
+		if err != nil {
+			return err
+		}
+
+		source, err := r.NewSource(redriveSource)
+		if err != nil {
+			return err
+		}
+
+		stats, err := r.Run(cmd.Context(), source)
+		if stats != nil {
+			if asJSON {
+				if jerr := printJSON(stats); jerr != nil {
+					return jerr
+				}
+			} else {
+				printRedriveStats(stats)
+			}
+		}
+		return err
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(redriveCmd)
+
+	redriveCmd.Flags().String("stream", "", "Name of the Kinesis stream")
+	viper.BindPFlag("redrive.stream", redriveCmd.Flags().Lookup("stream"))
+	redriveCmd.Flags().String("endpoint-url", "", "Send Kinesis requests to this URL instead of the regional endpoint")
+	viper.BindPFlag("redrive.endpoint", redriveCmd.Flags().Lookup("endpoint-url"))
+
+	redriveCmd.Flags().StringVar(&redriveSource, "source", "", "Backup file, directory or s3://bucket/prefix to replay")
+	redriveCmd.Flags().StringVar(&redriveStart, "start", "", "Only replay lines logged at or after this time (RFC 3339)")
+	redriveCmd.Flags().StringVar(&redriveEnd, "end", "", "Only replay lines logged before this time (RFC 3339)")
+	redriveCmd.Flags().IntVar(&redriveMaxRetries, "max-retries", redrive.DefaultMaxRetries, "Retries of records rejected by Kinesis")
+	redriveCmd.Flags().StringVar(&redriveCheckpoint, "checkpoint", "redrive-checkpoint.json", "Checkpoint journal appended to after every batch; empty to disable")
+	redriveCmd.Flags().BoolVar(&redriveResume, "resume", false, "Resume from the checkpoint file")
+	redriveCmd.Flags().IntVar(&redriveConcurrency, "concurrency", redrive.DefaultConcurrency, "Files replayed at once")
+	redriveCmd.Flags().Float64Var(&redriveRecordsPerSec, "records-per-sec", 0, "Most records sent per second; 0 for no limit")
+	redriveCmd.Flags().Float64Var(&redriveMBPerSec, "mb-per-sec", 0, "Most megabytes sent per second; 0 for no limit")
+	redriveCmd.Flags().StringSliceVar(&redriveHosts, "host", nil, "Only replay requests for this host; repeatable, wildcards allowed")
+	redriveCmd.Flags().StringVar(&redriveStatus, "status", "", "Only replay responses with this status: 404, 500-599 or 5xx")
+	redriveCmd.Flags().StringSliceVar(&redriveEdgeLocations, "edge-location", nil, "Only replay requests served by edge locations with this prefix; repeatable")
+	redriveCmd.Flags().StringSliceVar(&redriveClientCIDRs, "client-cidr", nil, "Only replay requests from clients in this CIDR block; repeatable")
+	redriveCmd.Flags().BoolVar(&redriveDryRun, "dry-run", false, "Read and filter the lines, counting them, without sending")
+	redriveCmd.Flags().StringVar(&redriveRejectFile, "reject-file", "redrive-rejects.tsv", "File lines with the wrong field count are appended to; empty to only log them")
+	redriveCmd.Flags().DurationVar(&redriveProgress, "progress", 5*time.Second, "Interval between progress lines on stderr; 0 to disable")
+}
+
+// printRedriveStats prints the redrive summary as a table.
+func printRedriveStats(stats *redrive.Stats) {
+	w := newTable()
+	fmt.Fprintf(w, "Files:\t%d\n", stats.Files)
+	if stats.FilesDone > 0 || stats.FilesResumed > 0 {
+		fmt.Fprintf(w, "Files already done:\t%d\n", stats.FilesDone)
+		fmt.Fprintf(w, "Files resumed:\t%d\n", stats.FilesResumed)
+	}
+	fmt.Fprintf(w, "Lines:\t%d\n", stats.Lines)
+	fmt.Fprintf(w, "Matched:\t%d\n", stats.Matched)
+	fmt.Fprintf(w, "Filtered out:\t%d\n", stats.Skipped)
+	names := make([]string, 0, len(stats.Filtered))
+	for name := range stats.Filtered {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "  by %s:\t%d\n", name, stats.Filtered[name])
+	}
+	fmt.Fprintf(w, "Records sent:\t%d\n", stats.Records)
+	fmt.Fprintf(w, "Bytes sent:\t%d\n", stats.Bytes)
+	fmt.Fprintf(w, "Invalid:\t%d\n", stats.Invalid)
+	fmt.Fprintf(w, "Failed:\t%d\n", stats.Failed)
+	fmt.Fprintf(w, "Retries:\t%d\n", stats.Retries)
+	fmt.Fprintf(w, "Throttled:\t%d\n", stats.Throttled)
+	fmt.Fprintf(w, "Elapsed:\t%s\n", stats.Elapsed.Round(time.Millisecond))
+	fmt.Fprintf(w, "Rate:\t%.1f records/s, %.2f MB/s\n", stats.RecordsPerSecond(), stats.MBPerSecond())
+	w.Flush()
+}
+
+// printRedriveProgress writes a one line progress report to stderr.
+func printRedriveProgress(stats redrive.Stats) {
+	fmt.Fprintf(os.Stderr, "%s files=%d records=%d failed=%d throttled=%d %.2fMB %.1f rec/s %.2f MB/s\n",
+		stats.Elapsed.Round(time.Second),
+		stats.Files,
+		stats.Records,
+		stats.Failed,
+		stats.Throttled,
+		float64(stats.Bytes)/1e6,
+		stats.RecordsPerSecond(),
+		stats.MBPerSecond(),
+	)
+}
+
+// parseStatusRange parses a status code filter: a code, an inclusive range such
+// as 500-599, or a class such as 5xx. Empty is no filter.
+func parseStatusRange(value string) (int, int, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if value == "" {
+		return 0, 0, nil
+	}
+	if len(value) == 3 && strings.HasSuffix(value, "xx") {
+		class, err := strconv.Atoi(value[:1])
+		if err != nil || class < 1 {
+			return 0, 0, fmt.Errorf("invalid status class %q", value)
+		}
+		return class * 100, class*100 + 99, nil
+	}
+	low, high, isRange := strings.Cut(value, "-")
+	min, err := strconv.Atoi(low)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid status %q", value)
+	}
+	if !isRange {
+		return min, min, nil
+	}
+	max, err := strconv.Atoi(high)
+	if err != nil || max < min {
+		return 0, 0, fmt.Errorf("invalid status range %q", value)
+	}
+	return min, max, nil
+}
+
+// parseTime parses an RFC 3339 time or date. Empty is the zero time.
+func parseTime(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", value)
+}
@@ -25,7 +25,9 @@ import (
 	"fmt"
 	"os"
 	"path"
+	"strings"
 
+	"github.com/rmrfslashbin/aws-cf-rtl/pkg/rtl"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -55,6 +57,24 @@ func init() {
 
 	userDir, _ := getConfigDir()
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", fmt.Sprintf("config file (default is %s/confg.yaml)", userDir))
+
+	// AWS settings and output format, also settable in the config file
+	rootCmd.PersistentFlags().String("profile", "", "AWS profile")
+	rootCmd.PersistentFlags().String("region", "", "AWS region (default is $AWS_REGION)")
+	rootCmd.PersistentFlags().StringP("output", "o", "table", "Output format: table or json")
+	viper.BindPFlag("profile", rootCmd.PersistentFlags().Lookup("profile"))
+	viper.BindPFlag("region", rootCmd.PersistentFlags().Lookup("region"))
+	viper.BindPFlag("output", rootCmd.PersistentFlags().Lookup("output"))
+
+	// Glue table used by the partitions and schema commands
+	rootCmd.PersistentFlags().String("database", "cfrtl", "Glue database name")
+	rootCmd.PersistentFlags().String("table", "rtl", "Glue table name")
+	viper.BindPFlag("glue.database", rootCmd.PersistentFlags().Lookup("database"))
+	viper.BindPFlag("glue.table", rootCmd.PersistentFlags().Lookup("table"))
+
+	// Log line fields used by the redrive and process commands
+	rootCmd.PersistentFlags().StringSlice("fields", rtl.DefaultFields, "Ordered log fields of the lines")
+	viper.BindPFlag("fields", rootCmd.PersistentFlags().Lookup("fields"))
 }
 
 // initConfig reads in config file and ENV variables if set.
@@ -74,12 +94,30 @@ func initConfig() {
 		viper.SetConfigName("config")
 	}
 
+	// Settings may come from RTL_ environment variables, e.g. RTL_REGION or
+	// RTL_GLUE_DATABASE, but never from bare ones such as REGION or FIELDS.
+	viper.SetEnvPrefix("rtl")
+	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
 	viper.AutomaticEnv() // read in environment variables that match
 
 	// If a config file is found, read it in.
 	viper.ReadInConfig()
 }
 
+// logFields returns the ordered log fields. RTL_FIELDS holds a comma separated
+// list as it does for the Lambda, which viper alone would split on spaces.
+func logFields() []string {
+	fields := []string{}
+	for _, value := range viper.GetStringSlice("fields") {
+		for _, field := range strings.Split(value, ",") {
+			if field = strings.TrimSpace(field); field != "" {
+				fields = append(fields, field)
+			}
+		}
+	}
+	return fields
+}
+
 func getConfigDir() (string, error) {
 	userConfigDir, err := os.UserConfigDir()
 	if err != nil {
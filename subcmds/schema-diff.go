@@ -0,0 +1,88 @@
+package subcmds
+
+import (
+	"fmt"
+
+	"github.com/rmrfslashbin/aws-cf-rtl/pkg/rtl"
+	"github.com/spf13/cobra"
+)
+
+// diffSchema represents the diff command
+var diffSchema = &cobra.Command{
+	Use:   "diff",
+	Short: "Compare the generated schema with the live Glue table",
+	Long: `Compares the columns and SerDe paths generated from the Record type with the
+live Glue table. Exits non-zero when they differ, since a missing or mistyped
+column silently comes back null from Athena.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		asJSON, err := jsonOutput()
+		if err != nil {
+			return err
+		}
+
+		catalog, err := newCatalog()
+		if err != nil {
+			return err
+		}
+
+		table, err := catalog.GetTableSchema(cmd.Context())
+		if err != nil {
+			return err
+		}
+
+		live := make([]rtl.Column, len(table.Columns))
+		for i, column := range table.Columns {
+			live[i] = rtl.Column{Name: column.Name, Type: column.Type}
+		}
+		diffs := rtl.Diff(live)
+
+		// The paths parameter only matters to the JSON SerDe
+		missingPaths, extraPaths := []string{}, []string{}
+		if table.SerDeLibrary == rtl.SerDeLibrary {
+			missingPaths, extraPaths = rtl.DiffPaths(table.SerDeParams["paths"])
+		}
+
+		if asJSON {
+			if err := printJSON(struct {
+				Columns      []rtl.ColumnDiff `json:"columns"`
+				MissingPaths []string         `json:"missing_paths"`
+				ExtraPaths   []string         `json:"extra_paths"`
+			}{diffs, missingPaths, extraPaths}); err != nil {
+				return err
+			}
+		} else {
+			w := newTable()
+			fmt.Fprintln(w, "DIFF\tCOLUMN\tRECORD\tTABLE")
+			for _, diff := range diffs {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", diff.Kind, diff.Name, dash(diff.RecordType), dash(diff.TableType))
+			}
+			for _, path := range missingPaths {
+				fmt.Fprintf(w, "missing path\t%s\t\t\n", path)
+			}
+			for _, path := range extraPaths {
+				fmt.Fprintf(w, "extra path\t%s\t\t\n", path)
+			}
+			w.Flush()
+		}
+
+		if n := len(diffs) + len(missingPaths) + len(extraPaths); n > 0 {
+			return fmt.Errorf("%d schema differences", n)
+		}
+		if !asJSON {
+			fmt.Println("schema matches")
+		}
+		return nil
+	},
+}
+
+func init() {
+	schemaCmd.AddCommand(diffSchema)
+}
+
+// dash shows empty table cells as "-".
+func dash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
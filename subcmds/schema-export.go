@@ -0,0 +1,85 @@
+package subcmds
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/rmrfslashbin/aws-cf-rtl/pkg/rtl"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	// exportFormat selects what schema export prints
+	exportFormat string
+	// exportLocation is the table location used in the DDL
+	exportLocation string
+	// exportORC describes ORC files in the DDL rather than JSON
+	exportORC bool
+)
+
+// exportSchema represents the export command
+var exportSchema = &cobra.Command{
+	Use:   "export",
+	Short: "Print the table schema generated from the log record",
+	Long: `Prints the schema generated from the Record type in one of these formats:
+
+  columns      Glue StorageDescriptor.Columns, as CloudFormation YAML
+  paths        JSON SerDe paths parameter
+  ddl          Athena CREATE EXTERNAL TABLE statement
+  jsonschema   JSON Schema of the records written to Firehose
+
+The DDL uses the --location given, or else the location of the live Glue table.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		switch exportFormat {
+		case "columns":
+			if viper.GetString("output") == "json" {
+				return printJSON(rtl.Columns())
+			}
+			for _, column := range rtl.Columns() {
+				fmt.Printf("- Name: %s\n  Type: %s\n", column.Name, column.Type)
+			}
+		case "paths":
+			fmt.Println(rtl.SerDePaths())
+		case "ddl":
+			location, err := ddlLocation(cmd.Context())
+			if err != nil {
+				return err
+			}
+			fmt.Print(rtl.AthenaDDL(viper.GetString("glue.database"), viper.GetString("glue.table"), location, exportORC))
+		case "jsonschema":
+			return printJSON(rtl.JSONSchema())
+		default:
+			return fmt.Errorf("unknown format: %s", exportFormat)
+		}
+		return nil
+	},
+}
+
+func init() {
+	schemaCmd.AddCommand(exportSchema)
+
+	exportSchema.Flags().StringVar(&exportFormat, "format", "columns", "Output format: columns, paths, ddl or jsonschema")
+	exportSchema.Flags().StringVar(&exportLocation, "location", "", "Table location for the DDL (default: the location of the Glue table)")
+	exportSchema.Flags().BoolVar(&exportORC, "orc", true, "Describe the ORC files Firehose writes rather than JSON in the DDL")
+}
+
+// ddlLocation returns --location, or the location of the Glue table when the flag
+// is not set.
+func ddlLocation(ctx context.Context) (string, error) {
+	if exportLocation != "" {
+		return exportLocation, nil
+	}
+	catalog, err := newCatalog()
+	if err != nil {
+		return "", fmt.Errorf("reading the table location: %w (or set --location)", err)
+	}
+	table, err := catalog.GetTableSchema(ctx)
+	if err != nil {
+		return "", fmt.Errorf("reading the table location: %w (or set --location)", err)
+	}
+	if table.Location == "" {
+		return "", fmt.Errorf("table %s has no location; set --location", viper.GetString("glue.table"))
+	}
+	return table.Location, nil
+}
@@ -0,0 +1,15 @@
+package subcmds
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// schemaCmd represents the schema command
+var schemaCmd = &cobra.Command{
+	Use:   "schema",
+	Short: "Glue table schema generated from the log record",
+}
+
+func init() {
+	rootCmd.AddCommand(schemaCmd)
+}
@@ -0,0 +1,134 @@
+package subcmds
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/rmrfslashbin/aws-cf-rtl/pkg/geoip"
+	"github.com/rmrfslashbin/aws-cf-rtl/pkg/rtl"
+	"github.com/rmrfslashbin/aws-cf-rtl/pkg/transform"
+	"github.com/rmrfslashbin/aws-cf-rtl/pkg/useragent"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// transformUAOverrides and transformUARegexes are extra user-agent definitions
+	transformUAOverrides []string
+	transformUARegexes   string
+	// transformGeoIPDBs enable GeoIP enrichment; transformGeoIPBuild records the database builds
+	transformGeoIPDBs   []string
+	transformGeoIPBuild bool
+	// transformParseMode and transformFieldModes control which field parse errors fail a line
+	transformParseMode  string
+	transformFieldModes []string
+)
+
+// addTransformFlags adds the flags matching the Lambda's environment settings
+// to a command that runs the transform locally.
+func addTransformFlags(cmd *cobra.Command) {
+	cmd.Flags().StringSliceVar(&transformUAOverrides, "ua-overrides", nil, "Extra user-agent override files, checked ahead of the definitions")
+	cmd.Flags().StringVar(&transformUARegexes, "ua-regexes", "", "regexes.yaml replacing the compiled-in user-agent definitions")
+	cmd.Flags().StringSliceVar(&transformGeoIPDBs, "geoip-db", nil, "GeoIP databases to enrich records with")
+	cmd.Flags().BoolVar(&transformGeoIPBuild, "geoip-build", false, "Record the GeoIP database builds in each record")
+	cmd.Flags().StringVar(&transformParseMode, "parse-mode", "", "Parse mode of every field: strict or lenient")
+	cmd.Flags().StringSliceVar(&transformFieldModes, "field-mode", nil, "Parse mode of a field, e.g. c-ip=strict; repeatable")
+}
+
+// newProcessor sets up the transform as the Lambda init does, from the flags.
+// The returned func closes the GeoIP databases.
+func newProcessor() (*transform.Config, func(), error) {
+	schema, err := transform.NewSchema(logFields())
+	if err != nil {
+		return nil, nil, err
+	}
+	if transformParseMode != "" {
+		mode, err := transform.ParseModeFromString(transformParseMode)
+		if err != nil {
+			return nil, nil, err
+		}
+		schema.SetAllModes(mode)
+	}
+	for _, pair := range transformFieldModes {
+		name, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, nil, fmt.Errorf("malformed field mode: %q", pair)
+		}
+		mode, err := transform.ParseModeFromString(value)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := schema.SetMode(strings.TrimSpace(name), mode); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	uaOpts := []useragent.Option{
+		useragent.SetOverrides(transform.UAOverrides),
+		useragent.SetLogger(logrus.StandardLogger()),
+	}
+	for _, path := range transformUAOverrides {
+		uaOpts = append(uaOpts, useragent.SetOverridesFile(path))
+	}
+	if transformUARegexes != "" {
+		uaOpts = append(uaOpts, useragent.SetRegexesFile(transformUARegexes))
+	}
+	if _, err := useragent.Load(uaOpts...); err != nil {
+		return nil, nil, err
+	}
+
+	var geoReader *geoip.Reader
+	closeGeo := func() {}
+	if len(transformGeoIPDBs) > 0 {
+		if geoReader, err = geoip.OpenAll(transformGeoIPDBs, geoip.SetIncludeBuild(transformGeoIPBuild), geoip.SetLogger(logrus.StandardLogger())); err != nil {
+			return nil, nil, err
+		}
+		closeGeo = func() { geoReader.Close() }
+	}
+
+	processor, err := transform.New(
+		transform.SetLogger(logrus.StandardLogger()),
+		transform.SetSchema(schema),
+		transform.SetGeoIP(geoReader),
+	)
+	if err != nil {
+		closeGeo()
+		return nil, nil, err
+	}
+	return processor, closeGeo, nil
+}
+
+// eachLine calls fn with every non-empty line of the named file, or stdin for "-",
+// gunzipping it if compressed. Line numbers count from 1 and include empty lines.
+func eachLine(name string, fn func(lineNo int, line string) error) error {
+	var raw io.ReadCloser = os.Stdin
+	if name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		raw = f
+	}
+	rc, err := rtl.Decompress(raw)
+	if err != nil {
+		raw.Close()
+		return err
+	}
+	defer rc.Close()
+
+	scanner := bufio.NewScanner(rc)
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		if line := scanner.Text(); line != "" {
+			if err := fn(lineNo, line); err != nil {
+				return err
+			}
+		}
+	}
+	return scanner.Err()
+}